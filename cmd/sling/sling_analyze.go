@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/slingdata-io/sling-cli/core/sling"
+	"github.com/spf13/cast"
+)
+
+// getDataAnalyzer returns a data analyzer for the connection, with the schemas
+// determined from the `pattern` flag (schema.table glob)
+func getDataAnalyzer(c *g.CliSC, entries connection.ConnEntries, opts database.DataAnalyzerOptions) (da *database.DataAnalyzer, err error) {
+	name := cast.ToString(c.Vals["name"])
+	conn := entries.Get(name)
+	if conn.Name == "" {
+		return nil, g.Error("did not find connection %s", name)
+	}
+
+	env.SetTelVal("conn_type", conn.Connection.Type.String())
+	if !conn.Connection.Type.IsDb() {
+		return nil, g.Error("cannot analyze a non-database connection (%s)", conn.Connection.Type)
+	}
+
+	dbConn, err := conn.Connection.AsDatabase()
+	if err != nil {
+		return nil, g.Error(err, "cannot create database connection (%s)", conn.Connection.Type)
+	}
+
+	schema := ""
+	if pattern := cast.ToString(c.Vals["pattern"]); pattern != "" {
+		table, _ := database.ParseTableName(pattern, conn.Connection.Type)
+		if !strings.ContainsAny(table.Schema, "*?") {
+			schema = table.Schema
+		}
+		opts.Pattern = pattern
+	}
+	if val := cast.ToString(c.Vals["schema"]); val != "" {
+		schema = val
+	}
+	opts.SchemaNames = []string{schema}
+
+	return database.NewDataAnalyzer(dbConn, opts)
+}
+
+func connsProfile(c *g.CliSC, entries connection.ConnEntries) (err error) {
+	output := strings.ToLower(cast.ToString(c.Vals["output"]))
+	if output == "" {
+		output = lo.Ternary(os.Getenv("SLING_OUTPUT") == "json", "json", "text")
+	} else if !g.In(output, "text", "json") {
+		return g.Error("invalid output format: %s. Accepted values are 'text' and 'json'", output)
+	}
+
+	tgtConn := cast.ToString(c.Vals["tgt-conn"])
+	tgtObject := cast.ToString(c.Vals["tgt-object"])
+	if (tgtConn == "") != (tgtObject == "") {
+		return g.Error("need to provide both --tgt-conn and --tgt-object to write the profile")
+	}
+
+	sample := cast.ToInt(c.Vals["sample"])
+	if sample <= 0 {
+		sample = 10000
+	}
+
+	da, err := getDataAnalyzer(c, entries, database.DataAnalyzerOptions{
+		AllColumns: true,
+		ValueRange: cast.ToBool(c.Vals["values"]),
+	})
+	if err != nil {
+		return g.Error(err, "could not initialize analyzer")
+	}
+	defer da.Conn.Close()
+
+	err = da.AnalyzeColumns(sample, true)
+	if err != nil {
+		return g.Error(err, "could not profile columns")
+	}
+
+	data := da.ProfilesDataset()
+	if tgtConn != "" {
+		return writeDatasetToTarget(data, tgtConn, tgtObject)
+	}
+
+	if output == "json" {
+		fmt.Println(g.Marshal(da.Profiles()))
+	} else {
+		fmt.Println(g.PrettyTable(data.GetFields(), data.Rows))
+	}
+
+	return nil
+}
+
+func connsRelations(c *g.CliSC, entries connection.ConnEntries) (err error) {
+	output := strings.ToLower(cast.ToString(c.Vals["output"]))
+	if output == "" {
+		output = lo.Ternary(os.Getenv("SLING_OUTPUT") == "json", "json", "yaml")
+	} else if !g.In(output, "yaml", "json") {
+		return g.Error("invalid output format: %s. Accepted values are 'yaml' and 'json'", output)
+	}
+
+	diagramFormat := strings.ToLower(cast.ToString(c.Vals["diagram"]))
+	if diagramFormat != "" && !g.In(diagramFormat, "mermaid", "graphviz", "dot") {
+		return g.Error("invalid diagram format: %s. Accepted values are 'mermaid' and 'graphviz'", diagramFormat)
+	} else if diagramFormat == "" && cast.ToString(c.Vals["diagram-file"]) != "" {
+		return g.Error("need to provide --diagram with --diagram-file")
+	}
+
+	sample := cast.ToInt(c.Vals["sample"])
+	if sample <= 0 {
+		sample = 10000
+	}
+
+	da, err := getDataAnalyzer(c, entries, database.DataAnalyzerOptions{})
+	if err != nil {
+		return g.Error(err, "could not initialize analyzer")
+	}
+	defer da.Conn.Close()
+
+	err = da.AnalyzeColumns(sample, false)
+	if err != nil {
+		return g.Error(err, "could not analyze columns")
+	}
+
+	err = da.ProcessRelations()
+	if err != nil {
+		return g.Error(err, "could not process relations")
+	}
+
+	filePath := cast.ToString(c.Vals["file"])
+	if output == "yaml" && filePath != "" {
+		if err = da.WriteRelationsYaml(filePath); err != nil {
+			return g.Error(err, "could not write relation graph to %s", filePath)
+		}
+		g.Info("wrote %d relations to %s", len(da.RelationEdges()), filePath)
+	} else {
+		var graph []byte
+		if output == "json" {
+			graph = []byte(g.Pretty(da.RelationMap))
+		} else if graph, err = da.RelationsYaml(); err != nil {
+			return g.Error(err, "could not render relation graph")
+		}
+
+		if filePath != "" {
+			if err = os.WriteFile(filePath, graph, 0644); err != nil {
+				return g.Error(err, "could not write relation graph to %s", filePath)
+			}
+			g.Info("wrote %d relations to %s", len(da.RelationEdges()), filePath)
+		} else {
+			fmt.Println(strings.TrimSpace(string(graph)))
+		}
+	}
+
+	if diagramFormat == "" {
+		return nil
+	}
+
+	diagram, err := da.RelationsDiagram(diagramFormat)
+	if err != nil {
+		return g.Error(err, "could not render diagram")
+	}
+
+	if filePath := cast.ToString(c.Vals["diagram-file"]); filePath != "" {
+		if err = os.WriteFile(filePath, []byte(diagram), 0644); err != nil {
+			return g.Error(err, "could not write diagram to %s", filePath)
+		}
+		g.Info("wrote %s diagram to %s", diagramFormat, filePath)
+	} else {
+		fmt.Print(diagram)
+	}
+
+	return nil
+}
+
+// writeDatasetToTarget writes the dataset to a target connection by running a
+// full-refresh task from a temporary CSV file
+func writeDatasetToTarget(data iop.Dataset, tgtConn, tgtObject string) (err error) {
+	filePath := path.Join(env.GetTempFolder(), g.F("sling.%s.csv", g.RandSuffix("dataset_", 6)))
+	file, err := os.Create(filePath)
+	if err != nil {
+		return g.Error(err, "could not create temp file")
+	}
+	defer os.Remove(filePath)
+
+	_, err = data.WriteCsv(file)
+	file.Close()
+	if err != nil {
+		return g.Error(err, "could not write temp file")
+	}
+
+	fileURL := "file://" + filePath
+	cfg := &sling.Config{
+		Source: sling.Source{Conn: fileURL, Stream: fileURL},
+		Target: sling.Target{Conn: tgtConn, Object: tgtObject},
+		Mode:   sling.FullRefreshMode,
+	}
+
+	task := sling.NewTask("", cfg)
+	if task.Err != nil {
+		return g.Error(task.Err, "could not initialize task")
+	}
+
+	err = task.Execute()
+	if err != nil {
+		return g.Error(err, "could not write to %s", tgtObject)
+	}
+
+	g.Info("wrote %d rows to %s", len(data.Rows), tgtObject)
+	return nil
+}
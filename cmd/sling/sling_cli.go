@@ -198,6 +198,63 @@ var cliUpdate = &g.CliSC{
 	ExecProcess: updateCLI,
 }
 
+var cliDiff = &g.CliSC{
+	Name:                  "diff",
+	Description:           "Compare the rows of two tables, across connections",
+	AdditionalHelpPrepend: "\nSee more details at https://docs.slingdata.io/sling-cli/",
+	Flags: []g.Flag{
+		{
+			Name:        "src-conn",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The source database connection (name or URL).",
+		},
+		{
+			Name:        "src-stream",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The source table (schema.table).",
+		},
+		{
+			Name:        "tgt-conn",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The target database connection (name or URL).",
+		},
+		{
+			Name:        "tgt-object",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The target table (schema.table).",
+		},
+		{
+			Name:        "primary-key",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The primary key column(s) to match rows with, comma separated.",
+		},
+		{
+			Name:        "buckets",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The number of primary key buckets to compare. Defaults to 1 bucket per 1000 rows.",
+		},
+		{
+			Name:        "sample",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The maximum number of sample keys to report per difference type. Defaults to 10.",
+		},
+		{
+			Name:        "output",
+			ShortName:   "",
+			Type:        "string",
+			Description: "The output format: text or json. Defaults to text.",
+		},
+	},
+	ExecProcess: processDiff,
+}
+
 var cliConns = &g.CliSC{
 	Name:                  "conns",
 	Singular:              "local connection",
@@ -282,6 +339,112 @@ var cliConns = &g.CliSC{
 				},
 			},
 		},
+		{
+			Name:        "profile",
+			Description: "profile the columns of tables in a Database connection",
+			PosFlags: []g.Flag{
+				{
+					Name:        "name",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The name of the connection to profile",
+				},
+			},
+			Flags: []g.Flag{
+				{
+					Name:        "pattern",
+					ShortName:   "p",
+					Type:        "string",
+					Description: "filter tables by glob pattern (e.g. schema.*, schema.prefix_*)",
+				},
+				{
+					Name:        "sample",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The number of most recent rows to profile per table. Defaults to 10000.",
+				},
+				{
+					Name:        "values",
+					ShortName:   "",
+					Type:        "bool",
+					Description: "Include min / max values (pulls data values).",
+				},
+				{
+					Name:        "output",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The output format: text or json. Defaults to text.",
+				},
+				{
+					Name:        "tgt-conn",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The connection to write the profile to, as a table or file.",
+				},
+				{
+					Name:        "tgt-object",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The table or file path to write the profile to.",
+				},
+			},
+		},
+		{
+			Name:        "relations",
+			Description: "discover the relations between tables in a Database connection",
+			PosFlags: []g.Flag{
+				{
+					Name:        "name",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The name of the connection to analyze",
+				},
+			},
+			Flags: []g.Flag{
+				{
+					Name:        "schema",
+					ShortName:   "s",
+					Type:        "string",
+					Description: "The schema to analyze",
+				},
+				{
+					Name:        "pattern",
+					ShortName:   "p",
+					Type:        "string",
+					Description: "filter tables by glob pattern (e.g. schema.*, schema.prefix_*)",
+				},
+				{
+					Name:        "sample",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The number of most recent rows to analyze per table. Defaults to 10000.",
+				},
+				{
+					Name:        "output",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The relation graph format: yaml or json. Defaults to yaml.",
+				},
+				{
+					Name:        "file",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The file path to write the relation graph to. Prints to stdout if omitted.",
+				},
+				{
+					Name:        "diagram",
+					ShortName:   "",
+					Type:        "string",
+					Description: "Also render an ER diagram: mermaid or graphviz.",
+				},
+				{
+					Name:        "diagram-file",
+					ShortName:   "",
+					Type:        "string",
+					Description: "The file path to write the ER diagram to. Prints to stdout if omitted.",
+				},
+			},
+		},
 		{
 			Name:        "exec",
 			Description: "execute a SQL query on a Database connection",
@@ -351,6 +514,7 @@ func init() {
 
 	cliConns.Make().Add()
 	cliRun.Make().Add()
+	cliDiff.Make().Add()
 	cliUpdate.Make().Add()
 
 	if projectID == "" {
@@ -190,6 +190,14 @@ func processConns(c *g.CliSC) (ok bool, err error) {
 	case "discover":
 		return ok, connsDiscover(c)
 
+	case "profile":
+		env.SetTelVal("task", g.Marshal(g.M("type", sling.ConnProfile)))
+		return ok, connsProfile(c, entries)
+
+	case "relations":
+		env.SetTelVal("task", g.Marshal(g.M("type", sling.ConnRelations)))
+		return ok, connsRelations(c, entries)
+
 	case "check":
 		return ok, connsCheck(c)
 
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/integrii/flaggy"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/slingdata-io/sling-cli/core/sling"
+	"github.com/spf13/cast"
+)
+
+func processDiff(c *g.CliSC) (ok bool, err error) {
+	ok = true
+
+	if len(c.Vals) == 0 {
+		flaggy.ShowHelp("")
+		return ok, nil
+	}
+
+	env.SetTelVal("task", g.Marshal(g.M("type", sling.DbDiff)))
+	env.SetTelVal("task_start_time", time.Now())
+	defer func() {
+		env.SetTelVal("task_status", lo.Ternary(err != nil, "error", "success"))
+		env.SetTelVal("task_end_time", time.Now())
+	}()
+
+	output := strings.ToLower(cast.ToString(c.Vals["output"]))
+	if output == "" {
+		output = lo.Ternary(os.Getenv("SLING_OUTPUT") == "json", "json", "text")
+	} else if !g.In(output, "text", "json") {
+		return ok, g.Error("invalid output format: %s. Accepted values are 'text' and 'json'", output)
+	}
+
+	srcTable := cast.ToString(c.Vals["src-stream"])
+	tgtTable := cast.ToString(c.Vals["tgt-object"])
+	if srcTable == "" || tgtTable == "" {
+		return ok, g.Error("need to provide --src-stream and --tgt-object")
+	}
+
+	opts := database.DiffOptions{
+		Buckets:    cast.ToInt(c.Vals["buckets"]),
+		SampleSize: cast.ToInt(c.Vals["sample"]),
+	}
+	for _, pk := range strings.Split(cast.ToString(c.Vals["primary-key"]), ",") {
+		if pk = strings.TrimSpace(pk); pk != "" {
+			opts.PrimaryKey = append(opts.PrimaryKey, pk)
+		}
+	}
+	if len(opts.PrimaryKey) == 0 {
+		return ok, g.Error("need to provide --primary-key")
+	}
+
+	srcConn, err := getDiffConn(cast.ToString(c.Vals["src-conn"]))
+	if err != nil {
+		return ok, g.Error(err, "could not connect to source")
+	}
+	defer srcConn.Close()
+
+	tgtConn, err := getDiffConn(cast.ToString(c.Vals["tgt-conn"]))
+	if err != nil {
+		return ok, g.Error(err, "could not connect to target")
+	}
+	defer tgtConn.Close()
+
+	result, err := database.DiffTables(srcConn, srcTable, tgtConn, tgtTable, opts)
+	if err != nil {
+		return ok, g.Error(err, "could not diff tables")
+	}
+
+	if output == "json" {
+		fmt.Println(g.Marshal(result))
+	} else {
+		fmt.Println(result.Text())
+	}
+
+	if !result.Equal() {
+		// non-zero exit code, for use in CI checks
+		return ok, g.Error("found %d missing, %d extra and %d changed rows", result.Missing, result.Extra, result.Changed)
+	}
+
+	return ok, nil
+}
+
+// getDiffConn returns a connected database connection from a local connection name or URL
+func getDiffConn(name string) (dbConn database.Connection, err error) {
+	if name == "" {
+		return nil, g.Error("connection not provided")
+	}
+
+	conn := connection.GetLocalConns().Get(name).Connection
+	if conn.Name == "" {
+		if !strings.Contains(name, "://") {
+			return nil, g.Error("did not find connection %s", name)
+		}
+		conn, err = connection.NewConnectionFromURL(name, name)
+		if err != nil {
+			return nil, g.Error(err, "could not create connection from URL")
+		}
+	}
+
+	if !conn.Type.IsDb() {
+		return nil, g.Error("cannot diff a non-database connection (%s)", conn.Type)
+	}
+	env.SetTelVal("conn_type", conn.Type.String())
+
+	dbConn, err = conn.AsDatabase()
+	if err != nil {
+		return nil, g.Error(err, "cannot create database connection (%s)", conn.Type)
+	}
+
+	err = dbConn.Connect()
+	if err != nil {
+		return nil, g.Error(err, "cannot connect to database (%s)", conn.Type)
+	}
+
+	return
+}
@@ -423,7 +423,11 @@ func runTask(cfg *sling.Config, replication *sling.ReplicationConfig) (err error
 	if df := task.Df(); df != nil {
 		for _, col := range df.Columns {
 			if c := col.Constraint; c != nil && c.FailCnt > 0 {
-				g.Warn("column '%s' had %d constraint failures (%s) ", col.Name, c.FailCnt, c.Expression)
+				policy := string(c.OnFail)
+				if policy == "" {
+					policy = "warn"
+				}
+				g.Warn("stream '%s': column '%s' had %d constraint failures (%s) [on_fail: %s]", task.Config.StreamName, col.Name, c.FailCnt, c.Expression, policy)
 				constraintFails = constraintFails + c.FailCnt
 			}
 		}
@@ -2,7 +2,9 @@ package database
 
 import (
 	"context"
+	"math"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/flarco/g"
@@ -37,16 +39,39 @@ on each row, determine the unique ones and cross-reference on potential join mat
 type DataAnalyzerOptions struct {
 	DbName      string
 	SchemaNames []string
+	Pattern     string // glob pattern to filter tables with (schema.table)
+	AllColumns  bool   // analyze all column types, not only string and integer columns
+	ValueRange  bool   // collect the min / max values (pulls data values)
 }
 
 type DataAnalyzer struct {
 	Conn        Connection
 	Schemata    Schemata
 	ColumnMap   map[string]iop.Column
+	RangeMap    map[string][2]any                         // column > [min, max] values
 	RelationMap map[string]map[string]map[string]Relation // table > column A > column B > relation
 	Options     DataAnalyzerOptions
 }
 
+// ColumnProfile is the profile of a column, from AnalyzeColumns
+type ColumnProfile struct {
+	Schema      string  `json:"schema"`
+	Table       string  `json:"table"`
+	Column      string  `json:"column"`
+	Position    int     `json:"position"`
+	Type        string  `json:"type"`
+	DbType      string  `json:"db_type"`
+	TotalCnt    int64   `json:"total_cnt"`
+	NullCnt     int64   `json:"null_cnt"`
+	NullPct     float64 `json:"null_pct"`
+	DistinctCnt int64   `json:"distinct_cnt"`
+	DistinctPct float64 `json:"distinct_pct"`
+	MinLen      int     `json:"min_len"`
+	MaxLen      int     `json:"max_len"`
+	MinValue    any     `json:"min_value,omitempty"`
+	MaxValue    any     `json:"max_value,omitempty"`
+}
+
 type Relation string
 
 const RelationOneToOne = "one_to_one"
@@ -54,6 +79,10 @@ const RelationOneToMany = "one_to_many"
 const RelationManyToOne = "many_to_one"
 const RelationManyToMany = "many_to_many"
 
+// manyToManySampleSize is the number of distinct values sampled per column
+// to match many to many relations
+const manyToManySampleSize = 100
+
 func NewDataAnalyzer(conn Connection, opts DataAnalyzerOptions) (da *DataAnalyzer, err error) {
 	if len(opts.SchemaNames) == 0 {
 		err = g.Error("must provide SchemaNames")
@@ -70,6 +99,7 @@ func NewDataAnalyzer(conn Connection, opts DataAnalyzerOptions) (da *DataAnalyze
 		Conn:        conn,
 		Options:     opts,
 		ColumnMap:   map[string]iop.Column{},
+		RangeMap:    map[string][2]any{},
 		RelationMap: map[string]map[string]map[string]Relation{},
 		Schemata:    Schemata{Databases: map[string]Database{}},
 	}
@@ -89,6 +119,10 @@ func (da *DataAnalyzer) GetSchemata(force bool) (err error) {
 			return g.Error(err, "could not get schemata")
 		}
 
+		if da.Options.Pattern != "" {
+			schemata = schemata.Filtered(false, da.Options.Pattern)
+		}
+
 		// merge into da.Schemata
 		for dbKey, db := range schemata.Databases {
 			if _, ok := da.Schemata.Databases[dbKey]; ok {
@@ -166,13 +200,23 @@ func (da *DataAnalyzer) AnalyzeColumns(sampleSize int, includeViews bool) (err e
 		// {"value_maximum", "max({field}::text)"}, // pulls customer data
 	}
 
+	// no distinct count or min / max values for types without equality or ordering
+	colStatsFields := func(col iop.Column) []StatFieldSQL {
+		if col.Type == iop.JsonType || col.Type == iop.BinaryType {
+			return lo.Filter(statsFields, func(sf StatFieldSQL, i int) bool { return sf.Name != "uniq_cnt" })
+		} else if !da.Options.ValueRange || col.IsBool() {
+			return statsFields
+		}
+		return append(statsFields, StatFieldSQL{"min_value", `min({field})`}, StatFieldSQL{"max_value", `max({field})`})
+	}
+
 	ctx := g.NewContext(context.Background(), 2) // threads max
 	analyze := func(table Table, cols []iop.Column) {
 		defer ctx.Wg.Read.Done()
 
 		colsSQL := []string{}
 		for _, col := range cols {
-			for _, sf := range statsFields {
+			for _, sf := range colStatsFields(col) {
 				colSQL := g.R(
 					g.F("%s as {alias}_%s", sf.TemplateSQL, sf.Name),
 					"field", da.Conn.Quote(col.Name),
@@ -186,14 +230,16 @@ func (da *DataAnalyzer) AnalyzeColumns(sampleSize int, includeViews bool) (err e
 			sqlAnalyzeColumns,
 			"cols_sql", strings.Join(colsSQL, ", "),
 			"table", table.FDQN(),
-			"order_col", getOrderCol(table).Name,
+			"order_col", da.Conn.Quote(getOrderCol(table).Name),
 			"limit", cast.ToString(sampleSize),
 		)
 		data, err := da.Conn.Query(sql)
 		if err != nil {
 			ctx.ErrGroup.Capture(g.Error(err, "could not get analysis sql for %s", table.FullName()))
+			return
 		} else if len(data.Rows) == 0 {
 			ctx.ErrGroup.Capture(g.Error("got zero rows for analysis sql for %s", table.FullName()))
+			return
 		}
 
 		// retrieve values, since in order
@@ -201,10 +247,14 @@ func (da *DataAnalyzer) AnalyzeColumns(sampleSize int, includeViews bool) (err e
 		i := 0
 		for _, col := range cols {
 			m := g.M()
-			for _, sf := range statsFields {
+			for _, sf := range colStatsFields(col) {
 				m[sf.Name] = row[i]
 				i++
 			}
+			valueRange := [2]any{m["min_value"], m["max_value"]}
+			delete(m, "min_value")
+			delete(m, "max_value")
+
 			// unmarshal
 			err = g.Unmarshal(g.Marshal(m), &col.Stats)
 			if err != nil {
@@ -212,7 +262,12 @@ func (da *DataAnalyzer) AnalyzeColumns(sampleSize int, includeViews bool) (err e
 			}
 
 			// store in master map
+			ctx.Mux.Lock()
 			da.ColumnMap[col.Key()] = col
+			if da.Options.ValueRange {
+				da.RangeMap[col.Key()] = valueRange
+			}
+			ctx.Mux.Unlock()
 			if col.IsUnique() {
 				g.Info("    %s is unique [%d rows]", col.Key(), col.Stats.TotalCnt)
 			}
@@ -230,6 +285,9 @@ func (da *DataAnalyzer) AnalyzeColumns(sampleSize int, includeViews bool) (err e
 
 		// need order to retrieve values
 		colsAll := lo.Filter(lo.Values(tableColMap), func(c iop.Column, i int) bool {
+			if da.Options.AllColumns {
+				return true
+			}
 			// t := strings.ToLower(c.DbType)
 			isText := c.IsString() && c.Type != iop.JsonType
 			// isNumber := strings.Contains(t, "int") || strings.Contains(t, "double")
@@ -257,6 +315,61 @@ func (da *DataAnalyzer) AnalyzeColumns(sampleSize int, includeViews bool) (err e
 	return
 }
 
+// Profiles returns the column profiles collected with AnalyzeColumns,
+// sorted by table and column position
+func (da *DataAnalyzer) Profiles() (profiles []ColumnProfile) {
+	for key, col := range da.ColumnMap {
+		cs := col.Stats
+		profile := ColumnProfile{
+			Schema:      col.Schema,
+			Table:       col.Table,
+			Column:      col.Name,
+			Position:    col.Position,
+			Type:        string(col.Type),
+			DbType:      col.DbType,
+			TotalCnt:    cs.TotalCnt,
+			NullCnt:     cs.NullCnt,
+			DistinctCnt: cs.UniqCnt,
+			MinLen:      cs.MinLen,
+			MaxLen:      cs.MaxLen,
+		}
+		if cs.TotalCnt > 0 {
+			profile.NullPct = math.Round(10000*float64(cs.NullCnt)/float64(cs.TotalCnt)) / 100
+			profile.DistinctPct = math.Round(10000*float64(cs.UniqCnt)/float64(cs.TotalCnt)) / 100
+		}
+		if valueRange, ok := da.RangeMap[key]; ok {
+			profile.MinValue, profile.MaxValue = valueRange[0], valueRange[1]
+		}
+		profiles = append(profiles, profile)
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		ti := strings.ToLower(profiles[i].Schema + "." + profiles[i].Table)
+		tj := strings.ToLower(profiles[j].Schema + "." + profiles[j].Table)
+		if ti != tj {
+			return ti < tj
+		}
+		return profiles[i].Position < profiles[j].Position
+	})
+
+	return
+}
+
+// ProfilesDataset returns the column profiles as a dataset
+func (da *DataAnalyzer) ProfilesDataset() (data iop.Dataset) {
+	data = iop.NewDataset(iop.NewColumnsFromFields(
+		"schema", "table", "column", "position", "type", "db_type", "total_cnt", "null_cnt", "null_pct",
+		"distinct_cnt", "distinct_pct", "min_len", "max_len", "min_value", "max_value",
+	))
+	for _, p := range da.Profiles() {
+		data.Append([]any{
+			p.Schema, p.Table, p.Column, p.Position, p.Type, p.DbType, p.TotalCnt, p.NullCnt, p.NullPct,
+			p.DistinctCnt, p.DistinctPct, p.MinLen, p.MaxLen, p.MinValue, p.MaxValue,
+		})
+	}
+	return
+}
+
 func (da *DataAnalyzer) ProcessRelations() (err error) {
 	err = da.ProcessRelationsString()
 	if err != nil {
@@ -309,10 +422,12 @@ func (da *DataAnalyzer) ProcessRelationsString() (err error) {
 		}
 	}
 
-	g.Info("processing string relations: OneToMany")
-	err = da.GetOneToMany(uniqueCols, nonUniqueCols, true)
-	if err != nil {
-		return g.Error(err, "could not run GetOneToMany")
+	if len(uniqueCols) > 0 && len(nonUniqueCols) > 0 {
+		g.Info("processing string relations: OneToMany")
+		err = da.GetOneToMany(uniqueCols, nonUniqueCols, true)
+		if err != nil {
+			return g.Error(err, "could not run GetOneToMany")
+		}
 	}
 
 	g.Info("processing string relations: OneToOne")
@@ -369,10 +484,12 @@ func (da *DataAnalyzer) ProcessRelationsInteger() (err error) {
 		}
 	}
 
-	g.Info("processing integer relations: OneToMany")
-	err = da.GetOneToMany(uniqueCols, nonUniqueCols, false)
-	if err != nil {
-		return g.Error(err, "could not run GetOneToMany")
+	if len(uniqueCols) > 0 && len(nonUniqueCols) > 0 {
+		g.Info("processing integer relations: OneToMany")
+		err = da.GetOneToMany(uniqueCols, nonUniqueCols, false)
+		if err != nil {
+			return g.Error(err, "could not run GetOneToMany")
+		}
 	}
 
 	g.Info("processing integer relations: OneToOne")
@@ -390,10 +507,19 @@ func (da *DataAnalyzer) ProcessRelationsInteger() (err error) {
 	return
 }
 
+// RelationsYaml returns the relation map as YAML
+func (da *DataAnalyzer) RelationsYaml() (out []byte, err error) {
+	out, err = yaml.Marshal(da.RelationMap)
+	if err != nil {
+		return nil, g.Error(err, "could not marshal to yaml")
+	}
+	return
+}
+
 func (da *DataAnalyzer) WriteRelationsYaml(path string) (err error) {
-	out, err := yaml.Marshal(da.RelationMap)
+	out, err := da.RelationsYaml()
 	if err != nil {
-		return g.Error(err, "could not marshal to yaml")
+		return err
 	}
 
 	err = os.WriteFile(path, out, 0755)
@@ -508,6 +634,10 @@ func (da *DataAnalyzer) GetOneToMany(uniqueCols, nonUniqueCols iop.Columns, asSt
 }
 
 func (da *DataAnalyzer) GetOneToOne(uniqueCols iop.Columns, asString bool) (err error) {
+	if len(uniqueCols) < 2 {
+		return nil
+	}
+
 	stringType := da.Conn.Template().Function["string_type"]
 	uniqueExpressions := lo.Map(uniqueCols, func(col iop.Column, i int) string {
 		// integer template, matches only the max value on both sides
@@ -607,6 +737,237 @@ func (da *DataAnalyzer) GetOneToOne(uniqueCols iop.Columns, asString bool) (err
 	return
 }
 
+// GetManyToMany finds the non-unique columns which share their values. A
+// sample of distinct values of each column is checked against the other column,
+// and a many to many relation requires all sampled values to match both ways.
 func (da *DataAnalyzer) GetManyToMany(nonUniqueCols iop.Columns, asString bool) (err error) {
-	return nil
+	if len(nonUniqueCols) < 2 {
+		return nil
+	}
+
+	stringType := da.Conn.Template().Function["string_type"]
+	field := func(col iop.Column, alias string) string {
+		name := alias + "." + da.Conn.Quote(col.Name)
+		return lo.Ternary(asString, g.F("cast(%s as %s)", name, stringType), name)
+	}
+
+	// for each pair of columns in different tables, count the sampled
+	// distinct values of column 1 which are found in column 2
+	matchingSQLs := []string{}
+	for _, col1 := range nonUniqueCols {
+		for _, col2 := range nonUniqueCols {
+			if col1.Schema == col2.Schema && col1.Table == col2.Table {
+				continue
+			}
+			matchingSQLs = append(matchingSQLs, g.R(
+				`select '{col_key_1}' as non_unique_column_key_1, '{col_key_2}' as non_unique_column_key_2,
+					count(*) as sampled_cnt,
+					sum(case when exists ( select 1 from {schema_2}.{table_2} t2 where {field_2} = s.val ) then 1 else 0 end) as matched_cnt
+				from ( select distinct {field_1} as val from {schema_1}.{table_1} t1 where {field_1} is not null limit {limit} ) s`,
+				"col_key_1", col1.Key(),
+				"col_key_2", col2.Key(),
+				"field_1", field(col1, "t1"),
+				"field_2", field(col2, "t2"),
+				"schema_1", da.Conn.Quote(col1.Schema),
+				"table_1", da.Conn.Quote(col1.Table),
+				"schema_2", da.Conn.Quote(col2.Schema),
+				"table_2", da.Conn.Quote(col2.Table),
+				"limit", cast.ToString(manyToManySampleSize),
+			))
+		}
+	}
+
+	if len(matchingSQLs) == 0 {
+		return nil
+	}
+
+	data, err := da.Conn.Query(strings.Join(matchingSQLs, "\n    union all\n    "))
+	if err != nil {
+		return g.Error(err, "could not get matching columns")
+	}
+
+	// a direction matches if all of its sampled values are found
+	matched := map[string]bool{}
+	for _, rec := range data.Records() {
+		sampledCnt := cast.ToInt64(rec["sampled_cnt"])
+		matchedCnt := cast.ToInt64(rec["matched_cnt"])
+		if sampledCnt > 1 && matchedCnt == sampledCnt {
+			key := cast.ToString(rec["non_unique_column_key_1"]) + ">" + cast.ToString(rec["non_unique_column_key_2"])
+			matched[key] = true
+		}
+	}
+
+	// columns which reference the same unique column are siblings, not a many to many
+	referencesOf := func(colKey string) (keys []string) {
+		col := da.ColumnMap[colKey]
+		for key, relation := range da.RelationMap[g.F("%s.%s", col.Schema, col.Table)][colKey] {
+			if relation == RelationManyToOne {
+				keys = append(keys, key)
+			}
+		}
+		return
+	}
+
+	for _, col1 := range nonUniqueCols {
+		for _, col2 := range nonUniqueCols {
+			columnKey1, columnKey2 := col1.Key(), col2.Key()
+			if columnKey1 >= columnKey2 {
+				continue // each pair once
+			} else if !matched[columnKey1+">"+columnKey2] || !matched[columnKey2+">"+columnKey1] {
+				continue
+			} else if len(lo.Intersect(referencesOf(columnKey1), referencesOf(columnKey2))) > 0 {
+				continue
+			}
+
+			table1 := g.F("%s.%s", col1.Schema, col1.Table)
+			table2 := g.F("%s.%s", col2.Schema, col2.Table)
+
+			for _, pair := range [][3]string{{table1, columnKey1, columnKey2}, {table2, columnKey2, columnKey1}} {
+				table, keyA, keyB := pair[0], pair[1], pair[2]
+				if mt, ok := da.RelationMap[table]; ok {
+					if m, ok := mt[keyA]; ok {
+						m[keyB] = RelationManyToMany
+					} else {
+						mt[keyA] = map[string]Relation{keyB: RelationManyToMany}
+					}
+				} else {
+					da.RelationMap[table] = map[string]map[string]Relation{
+						keyA: {keyB: RelationManyToMany},
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// RelationEdge is a relation between two columns, from ProcessRelations
+type RelationEdge struct {
+	FromTable  string   `json:"from_table" yaml:"from_table"`
+	FromColumn string   `json:"from_column" yaml:"from_column"`
+	ToTable    string   `json:"to_table" yaml:"to_table"`
+	ToColumn   string   `json:"to_column" yaml:"to_column"`
+	Relation   Relation `json:"relation" yaml:"relation"`
+
+	fromType, toType iop.ColumnType
+}
+
+// RelationEdges returns the distinct relations found with ProcessRelations.
+// Each relation is listed once: many_to_one is the inverse of one_to_many, and
+// one_to_one / many_to_many are symmetric.
+func (da *DataAnalyzer) RelationEdges() (edges []RelationEdge) {
+	for _, columnMap := range da.RelationMap {
+		for keyA, relMap := range columnMap {
+			for keyB, relation := range relMap {
+				switch relation {
+				case RelationManyToOne:
+					continue
+				case RelationOneToOne, RelationManyToMany:
+					if keyA > keyB {
+						continue
+					}
+				}
+
+				colA, colB := da.ColumnMap[keyA], da.ColumnMap[keyB]
+				edges = append(edges, RelationEdge{
+					FromTable:  g.F("%s.%s", colA.Schema, colA.Table),
+					FromColumn: colA.Name,
+					ToTable:    g.F("%s.%s", colB.Schema, colB.Table),
+					ToColumn:   colB.Name,
+					Relation:   relation,
+					fromType:   colA.Type,
+					toType:     colB.Type,
+				})
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		ki := strings.ToLower(edges[i].FromTable + "." + edges[i].FromColumn + ">" + edges[i].ToTable + "." + edges[i].ToColumn)
+		kj := strings.ToLower(edges[j].FromTable + "." + edges[j].FromColumn + ">" + edges[j].ToTable + "." + edges[j].ToColumn)
+		return ki < kj
+	})
+
+	return
+}
+
+// RelationsDiagram renders the relations as an ER diagram.
+// Accepted formats are `mermaid` and `graphviz` (or `dot`).
+func (da *DataAnalyzer) RelationsDiagram(format string) (diagram string, err error) {
+	edges := da.RelationEdges()
+	lines := []string{}
+
+	switch strings.ToLower(format) {
+	case "mermaid":
+		entityName := func(table string) string {
+			return strings.Map(func(r rune) rune {
+				if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+					return r
+				}
+				return '_'
+			}, table)
+		}
+
+		// entities with the related columns
+		entityColumns := map[string][]string{}
+		columnTypes := map[string]iop.ColumnType{}
+		addColumn := func(table, column string, colType iop.ColumnType) {
+			if !g.In(column, entityColumns[table]...) {
+				entityColumns[table] = append(entityColumns[table], column)
+				columnTypes[table+"."+column] = colType
+			}
+		}
+		for _, edge := range edges {
+			addColumn(edge.FromTable, edge.FromColumn, edge.fromType)
+			addColumn(edge.ToTable, edge.ToColumn, edge.toType)
+		}
+
+		lines = append(lines, "erDiagram")
+		tables := lo.Keys(entityColumns)
+		sort.Strings(tables)
+		for _, table := range tables {
+			lines = append(lines, g.F("  %s {", entityName(table)))
+			for _, column := range entityColumns[table] {
+				colType := string(columnTypes[table+"."+column])
+				if colType == "" {
+					colType = "string"
+				}
+				lines = append(lines, g.F("    %s %s", entityName(colType), entityName(column)))
+			}
+			lines = append(lines, "  }")
+		}
+
+		cardinality := map[Relation]string{
+			RelationOneToMany:  "||--o{",
+			RelationOneToOne:   "||--||",
+			RelationManyToMany: "}o--o{",
+		}
+		for _, edge := range edges {
+			lines = append(lines, g.F(
+				`  %s %s %s : "%s to %s"`,
+				entityName(edge.FromTable), cardinality[edge.Relation], entityName(edge.ToTable),
+				edge.FromColumn, edge.ToColumn,
+			))
+		}
+
+	case "graphviz", "dot":
+		arrows := map[Relation]string{
+			RelationOneToMany:  `arrowhead=crow, arrowtail=tee, dir=both`,
+			RelationOneToOne:   `arrowhead=tee, arrowtail=tee, dir=both`,
+			RelationManyToMany: `arrowhead=crow, arrowtail=crow, dir=both`,
+		}
+		lines = append(lines, "digraph relations {", "  rankdir=LR;", "  node [shape=box];")
+		for _, edge := range edges {
+			lines = append(lines, g.F(
+				`  "%s" -> "%s" [label="%s to %s", %s];`,
+				edge.FromTable, edge.ToTable, edge.FromColumn, edge.ToColumn, arrows[edge.Relation],
+			))
+		}
+		lines = append(lines, "}")
+
+	default:
+		return "", g.Error("invalid diagram format: %s. Accepted values are 'mermaid' and 'graphviz'", format)
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
 }
@@ -2,9 +2,12 @@ package database
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/flarco/g"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDataAnalyzer(t *testing.T) {
@@ -30,3 +33,86 @@ func TestDataAnalyzer(t *testing.T) {
 		return
 	}
 }
+
+func TestRelationsDiagram(t *testing.T) {
+	userID := iop.Column{Schema: "main", Table: "users", Name: "id", Type: iop.BigIntType}
+	orderUserID := iop.Column{Schema: "main", Table: "orders", Name: "user_id", Type: iop.BigIntType}
+
+	da := &DataAnalyzer{
+		ColumnMap: map[string]iop.Column{
+			userID.Key():      userID,
+			orderUserID.Key(): orderUserID,
+		},
+		RelationMap: map[string]map[string]map[string]Relation{
+			"main.users":  {userID.Key(): {orderUserID.Key(): RelationOneToMany}},
+			"main.orders": {orderUserID.Key(): {userID.Key(): RelationManyToOne}},
+		},
+	}
+
+	edges := da.RelationEdges()
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, "main.users", edges[0].FromTable)
+		assert.Equal(t, "id", edges[0].FromColumn)
+		assert.Equal(t, "main.orders", edges[0].ToTable)
+		assert.Equal(t, "user_id", edges[0].ToColumn)
+		assert.EqualValues(t, RelationOneToMany, edges[0].Relation)
+	}
+
+	diagram, err := da.RelationsDiagram("mermaid")
+	if assert.NoError(t, err) {
+		assert.Contains(t, diagram, "erDiagram")
+		assert.Contains(t, diagram, "    bigint user_id")
+		assert.Contains(t, diagram, `main_users ||--o{ main_orders : "id to user_id"`)
+	}
+
+	diagram, err = da.RelationsDiagram("graphviz")
+	if assert.NoError(t, err) {
+		assert.Contains(t, diagram, `"main.users" -> "main.orders" [label="id to user_id"`)
+	}
+
+	_, err = da.RelationsDiagram("plantuml")
+	assert.Error(t, err)
+}
+
+func TestGetManyToMany(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "relations.db")
+	conn, err := NewConn("sqlite://" + dbPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, conn.Connect()) {
+		return
+	}
+	defer conn.Close()
+
+	for _, table := range []string{"post_tags", "tag_follows", "counts"} {
+		_, err = conn.Exec(g.F("create table %s (val integer)", table))
+		assert.NoError(t, err)
+	}
+	_, err = conn.Exec(`insert into post_tags values (1), (1), (2), (3), (3)`)
+	assert.NoError(t, err)
+	_, err = conn.Exec(`insert into tag_follows values (1), (2), (2), (3)`)
+	assert.NoError(t, err)
+	// same max value, but different values
+	_, err = conn.Exec(`insert into counts values (3), (3), (7), (7)`)
+	assert.NoError(t, err)
+
+	da := &DataAnalyzer{Conn: conn, ColumnMap: map[string]iop.Column{}, RelationMap: map[string]map[string]map[string]Relation{}}
+	cols := iop.Columns{}
+	for _, table := range []string{"post_tags", "tag_follows", "counts"} {
+		col := iop.Column{Schema: "main", Table: table, Name: "val", Type: iop.BigIntType}
+		da.ColumnMap[col.Key()] = col
+		cols = append(cols, col)
+	}
+
+	if !assert.NoError(t, da.GetManyToMany(cols, false)) {
+		return
+	}
+
+	edges := da.RelationEdges()
+	if assert.Len(t, edges, 1) {
+		assert.Equal(t, "main.post_tags", edges[0].FromTable)
+		assert.Equal(t, "main.tag_follows", edges[0].ToTable)
+		assert.EqualValues(t, RelationManyToMany, edges[0].Relation)
+	}
+}
@@ -2170,45 +2170,183 @@ func (conn *BaseConn) Upsert(srcTable string, tgtTable string, primKeys []string
 	return cast.ToInt64(cnt), err
 }
 
-// SwapTable swaps two table
+// SwapTable replaces tgtTable with srcTable. If the dialect supports an atomic
+// exchange (`core.swap_table`) it is used, otherwise the target is renamed to a
+// backup name and srcTable is renamed into place. The previous target table is
+// only dropped once srcTable is in place. The grants and comments of tgtTable
+// are copied to srcTable beforehand, where the dialect provides the templates.
 func (conn *BaseConn) SwapTable(srcTable string, tgtTable string) (err error) {
+	src, err := ParseTableName(srcTable, conn.GetType())
+	if err != nil {
+		return g.Error(err, "could not parse table name "+srcTable)
+	}
 
-	tgtTableTemp := tgtTable + "_tmp" + g.RandString(g.AlphaRunesLower, 2)
-	conn.DropTable(tgtTableTemp)
+	tgt, err := ParseTableName(tgtTable, conn.GetType())
+	if err != nil {
+		return g.Error(err, "could not parse table name "+tgtTable)
+	}
 
-	sql := g.R(
-		conn.GetTemplateValue("core.rename_table"),
-		"table", tgtTable,
-		"new_table", tgtTableTemp,
-	)
-	_, err = conn.Exec(sql)
+	// tables are renamed within their schema, so srcTable must be in the target schema
+	if !strings.EqualFold(src.Schema, tgt.Schema) {
+		if src, err = conn.setSchema(src, tgt.Schema); err != nil {
+			return g.Error(err, "could not move table %s into schema %s", src.FullName(), tgt.Schema)
+		}
+	}
+
+	exists, err := TableExists(conn.Self(), tgt.FullName())
 	if err != nil {
-		return g.Error(err, "could not rename table "+tgtTable)
+		return g.Error(err, "could not check existence of table "+tgt.FullName())
+	} else if !exists {
+		// nothing to swap with, simply rename into place
+		return conn.renameTable(src, tgt.Name)
 	}
 
-	sql = g.R(
-		conn.GetTemplateValue("core.rename_table"),
-		"table", srcTable,
-		"new_table", tgtTable,
-	)
-	_, err = conn.Exec(sql)
+	conn.copyTableProperties(tgt, src)
+
+	suffix := lo.Ternary(conn.GetType().DBNameUpperCase(), "_OLD", "_old") + g.RandString(g.AlphaRunesLower, 2)
+	backup := tgt
+	if conn.GetType() == dbio.TypeDbOracle && len(backup.Name) > 30-len(suffix) {
+		backup.Name = backup.Name[:30-len(suffix)] // max is 30 chars
+	}
+	backup.Name = backup.Name + suffix
+	conn.Self().DropTable(backup.FullName())
+
+	if template := conn.GetTemplateValue("core.swap_table"); template != "" {
+		sql := g.R(
+			template,
+			"table", tgt.FullName(),
+			"new_table", src.FullName(),
+			"backup_table", backup.FullName(),
+		)
+		if _, err = conn.Self().Exec(sql); err != nil {
+			return g.Error(err, "could not swap table %s with %s", tgt.FullName(), src.FullName())
+		}
+
+		// the previous target data is now in the backup table if renamed,
+		// or in srcTable if exchanged
+		previous := lo.Ternary(strings.Contains(template, "{backup_table}"), backup, src)
+		err = conn.Self().DropTable(previous.FullName())
+		if err != nil {
+			return g.Error(err, "could not drop previous table "+previous.FullName())
+		}
+		return nil
+	}
+
+	err = conn.renameTable(tgt, backup.Name)
 	if err != nil {
-		return g.Error(err, "could not rename table "+srcTable)
+		return g.Error(err, "could not rename table "+tgt.FullName())
 	}
 
-	sql = g.R(
+	err = conn.renameTable(src, tgt.Name)
+	if err != nil {
+		// put previous table back in place
+		g.LogError(conn.renameTable(backup, tgt.Name))
+		return g.Error(err, "could not rename table "+src.FullName())
+	}
+
+	err = conn.Self().DropTable(backup.FullName())
+	if err != nil {
+		return g.Error(err, "could not drop previous table "+backup.FullName())
+	}
+
+	return
+}
+
+// renameTable renames a table within its schema
+func (conn *BaseConn) renameTable(table Table, newName string) (err error) {
+	newTable := table
+	newTable.Name = newName
+	q := GetQualifierQuote(conn.GetType())
+
+	sql := g.R(
 		conn.GetTemplateValue("core.rename_table"),
-		"table", tgtTableTemp,
-		"new_table", srcTable,
+		"table", table.FullName(),
+		"new_table", newTable.FullName(),
+		"new_name", q+newName+q,
+		"new_name_raw", newName,
 	)
-	_, err = conn.Exec(sql)
+	_, err = conn.Self().Exec(sql)
 	if err != nil {
-		return g.Error(err, "could not rename table "+tgtTableTemp)
+		return g.Error(err, "could not rename table %s to %s", table.FullName(), newTable.FullName())
 	}
-
 	return
 }
 
+// setSchema moves a table into another schema, if the dialect provides `core.set_schema`
+func (conn *BaseConn) setSchema(table Table, newSchema string) (newTable Table, err error) {
+	template := conn.GetTemplateValue("core.set_schema")
+	if template == "" {
+		return table, g.Error("moving a table into another schema is not supported for %s", conn.GetType())
+	}
+
+	newTable = table
+	newTable.Schema = newSchema
+	sql := g.R(
+		template,
+		"table", table.FullName(),
+		"new_table", newTable.FullName(),
+		"new_schema", conn.Self().Quote(newSchema, false),
+	)
+	if _, err = conn.Self().Exec(sql); err != nil {
+		return table, g.Error(err, "could not set schema of table %s", table.FullName())
+	}
+	return newTable, nil
+}
+
+// copyTableProperties copies the grants, table comment and column comments
+// of table to newTable, with the `metadata.table_grants`, `metadata.table_comment`
+// and `metadata.column_comments` templates of the dialect. Failures are logged.
+func (conn *BaseConn) copyTableProperties(table Table, newTable Table) {
+	query := func(key string) []map[string]string {
+		template := conn.GetTemplateValue("metadata." + key)
+		if template == "" {
+			return nil
+		}
+
+		data, err := conn.Self().Query(g.R(template, "schema", table.Schema, "table", table.Name) + noDebugKey)
+		if err != nil {
+			g.Warn("could not get %s of %s: %s", strings.ReplaceAll(key, "_", " "), table.FullName(), err.Error())
+			return nil
+		}
+		return data.RecordsString()
+	}
+
+	exec := func(key string, values ...string) {
+		sql := g.R(conn.GetTemplateValue("core."+key), append([]string{"table", newTable.FullName()}, values...)...)
+		if _, err := conn.Self().Exec(sql); err != nil {
+			g.Warn("could not apply %s to %s: %s", strings.ReplaceAll(key, "_", " "), newTable.FullName(), err.Error())
+		}
+	}
+
+	quote := func(val string) string {
+		return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+	}
+
+	for _, rec := range query("table_grants") {
+		exec("grant_table", "privilege", rec["privilege"], "grantee", rec["grantee"])
+	}
+
+	for _, rec := range query("table_comment") {
+		if rec["comment"] != "" {
+			exec("comment_table", "comment", quote(rec["comment"]))
+		}
+	}
+
+	if records := query("column_comments"); len(records) > 0 {
+		columns, err := conn.Self().GetColumns(newTable.FullName())
+		if err != nil {
+			g.Warn("could not get columns of %s: %s", newTable.FullName(), err.Error())
+			return
+		}
+
+		for _, rec := range records {
+			if col := columns.GetColumn(rec["column_name"]); col != nil && rec["comment"] != "" {
+				exec("comment_column", "column", conn.Self().Quote(col.Name, false), "comment", quote(rec["comment"]))
+			}
+		}
+	}
+}
+
 // GetNativeType returns the native column type from generic
 func (conn *BaseConn) GetNativeType(col iop.Column) (nativeType string, err error) {
 	return col.GetNativeType(conn.GetType())
@@ -2497,6 +2635,13 @@ func (conn *BaseConn) GenerateUpsertExpressions(srcTable string, tgtTable string
 		return
 	}
 
+	// source columns not in the target table are not loaded
+	if cast.ToBool(conn.GetProp("ignore_new_columns")) {
+		srcColumns = lo.Filter(srcColumns, func(c iop.Column, i int) bool {
+			return tgtColumns.GetColumn(c.Name) != nil
+		})
+	}
+
 	pkCols, err := conn.ValidateColumnNames(tgtColumns, pkFields, true)
 	if err != nil {
 		err = g.Error(err, "PK columns mismatch")
@@ -2854,30 +2999,7 @@ func (conn *BaseConn) CompareChecksums(tableName string, columns iop.Columns) (e
 			continue // making sure it is a common column
 		}
 
-		expr := ""
-		switch {
-		case col.Type == iop.JsonType:
-			expr = conn.GetTemplateValue("function.checksum_json")
-		case col.IsString():
-			expr = conn.GetTemplateValue("function.checksum_string")
-		case col.IsInteger():
-			expr = conn.GetTemplateValue("function.checksum_integer")
-		case col.IsFloat():
-			expr = conn.GetTemplateValue("function.checksum_decimal")
-		case col.IsDecimal():
-			expr = conn.GetTemplateValue("function.checksum_decimal")
-		case col.IsDate():
-			expr = conn.GetTemplateValue("function.checksum_date")
-			if expr == "" {
-				expr = conn.GetTemplateValue("function.checksum_datetime")
-			}
-		case col.IsDatetime():
-			expr = conn.GetTemplateValue("function.checksum_datetime")
-		case col.IsBool():
-			expr = conn.GetTemplateValue("function.checksum_boolean")
-		default:
-			expr = "0"
-		}
+		expr := checksumExpr(conn.Self(), col)
 		colName := fieldsMap[strings.ToLower(col.Name)]
 		expr = g.R(expr, "field", conn.Self().Quote(cast.ToString(colName)))
 		exprs = append(exprs, g.F("sum(%s) as %s", expr, conn.Self().Quote(cast.ToString(colName))))
@@ -2924,6 +3046,35 @@ func (conn *BaseConn) CompareChecksums(tableName string, columns iop.Columns) (e
 	return eg.Err()
 }
 
+// checksumExpr returns the checksum expression template of a column, with a `{field}` placeholder.
+// The expressions are comparable across databases for the same general type.
+func checksumExpr(conn Connection, col iop.Column) (expr string) {
+	switch {
+	case col.Type == iop.JsonType:
+		expr = conn.GetTemplateValue("function.checksum_json")
+	case col.IsString():
+		expr = conn.GetTemplateValue("function.checksum_string")
+	case col.IsInteger():
+		expr = conn.GetTemplateValue("function.checksum_integer")
+	case col.IsFloat():
+		expr = conn.GetTemplateValue("function.checksum_decimal")
+	case col.IsDecimal():
+		expr = conn.GetTemplateValue("function.checksum_decimal")
+	case col.IsDate():
+		expr = conn.GetTemplateValue("function.checksum_date")
+		if expr == "" {
+			expr = conn.GetTemplateValue("function.checksum_datetime")
+		}
+	case col.IsDatetime():
+		expr = conn.GetTemplateValue("function.checksum_datetime")
+	case col.IsBool():
+		expr = conn.GetTemplateValue("function.checksum_boolean")
+	default:
+		expr = "0"
+	}
+	return
+}
+
 // Info returns connection information
 func (conn *BaseConn) Info() (ci ConnInfo) {
 	U, err := net.NewURL(conn.GetURL())
@@ -200,6 +200,13 @@ func (conn *MongoDBConn) StreamRowsContext(ctx context.Context, collectionName s
 		filter = append(filter, bson.D{{Key: updateKey, Value: bson.D{{Key: "$lte", Value: endValue}}}}...)
 	}
 
+	// push down the row filter if possible
+	if where := conn.GetProp("where"); where != "" {
+		if conds := makeMongoWhereFilter(where); len(conds) > 0 {
+			filter = append(filter, bson.E{Key: "$and", Value: conds})
+		}
+	}
+
 	if strings.TrimSpace(collectionName) == "" {
 		g.Warn("Empty collection name")
 		return ds, nil
@@ -278,6 +285,45 @@ func (conn *MongoDBConn) StreamRowsContext(ctx context.Context, collectionName s
 	return
 }
 
+// makeMongoWhereFilter converts the conditions of a row filter expression into
+// mongo filters. Conditions on `_id` (ObjectID) or on flattened fields are skipped,
+// since the filter is also applied on the stream.
+func makeMongoWhereFilter(where string) (filters bson.A) {
+	expr, err := iop.ParseExpression(where)
+	if err != nil {
+		return nil
+	}
+
+	conds, ok := expr.Conditions()
+	if !ok {
+		return nil
+	}
+
+	operators := map[string]string{
+		"=": "$eq", "!=": "$ne", "<": "$lt", "<=": "$lte", ">": "$gt", ">=": "$gte",
+		"in": "$in", "not in": "$nin",
+	}
+
+	for _, cond := range conds {
+		if cond.Column == "_id" || strings.Contains(cond.Column, "__") {
+			continue
+		}
+
+		switch cond.Operator {
+		case "is null":
+			filters = append(filters, bson.D{{Key: cond.Column, Value: nil}})
+		case "is not null":
+			filters = append(filters, bson.D{{Key: cond.Column, Value: bson.D{{Key: "$ne", Value: nil}}}})
+		case "in", "not in":
+			filters = append(filters, bson.D{{Key: cond.Column, Value: bson.D{{Key: operators[cond.Operator], Value: bson.A(cond.Values)}}}})
+		default:
+			filters = append(filters, bson.D{{Key: cond.Column, Value: bson.D{{Key: operators[cond.Operator], Value: cond.Values[0]}}}})
+		}
+	}
+
+	return filters
+}
+
 // GetSchemas returns schemas
 func (conn *MongoDBConn) GetSchemas() (data iop.Dataset, err error) {
 	queryContext := g.NewContext(conn.Context().Ctx)
@@ -0,0 +1,553 @@
+package database
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
+	"github.com/spf13/cast"
+)
+
+// DiffOptions are the options to compare two tables
+type DiffOptions struct {
+	PrimaryKey    []string `json:"primary_key"`
+	Buckets       int      `json:"buckets"`         // number of primary key buckets, determined by row count if 0
+	RowsPerBucket int      `json:"rows_per_bucket"` // target number of rows per bucket, default 1000
+	SampleSize    int      `json:"sample_size"`     // max number of sample keys to report, default 10
+}
+
+// DiffChange is a row present on both sides with different values
+type DiffChange struct {
+	Key     []any    `json:"key"`
+	Columns []string `json:"columns"`
+}
+
+// DiffResult is the result of comparing two tables
+type DiffResult struct {
+	Source         string       `json:"source"`
+	Target         string       `json:"target"`
+	PrimaryKey     []string     `json:"primary_key"`
+	Columns        []string     `json:"columns"`
+	SkippedColumns []string     `json:"skipped_columns,omitempty"`
+	SourceCount    uint64       `json:"source_count"`
+	TargetCount    uint64       `json:"target_count"`
+	Buckets        int          `json:"buckets"`
+	DiffBuckets    int          `json:"diff_buckets"`
+	Missing        uint64       `json:"missing"`
+	Extra          uint64       `json:"extra"`
+	Changed        uint64       `json:"changed"`
+	MissingKeys    [][]any      `json:"missing_keys,omitempty"`
+	ExtraKeys      [][]any      `json:"extra_keys,omitempty"`
+	ChangedRows    []DiffChange `json:"changed_rows,omitempty"`
+}
+
+// Equal returns true if no differences were found
+func (dr *DiffResult) Equal() bool {
+	return dr.Missing == 0 && dr.Extra == 0 && dr.Changed == 0
+}
+
+// Text returns a human readable report of the differences
+func (dr *DiffResult) Text() string {
+	keyStr := func(key []any) string {
+		parts := make([]string, len(key))
+		for i, v := range key {
+			parts[i] = g.F("%s=%v", dr.PrimaryKey[i], v)
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	lines := []string{
+		g.F("source: %s (%d rows)", dr.Source, dr.SourceCount),
+		g.F("target: %s (%d rows)", dr.Target, dr.TargetCount),
+		g.F("compared %d columns by primary key (%s) in %d buckets, %d differ", len(dr.Columns), strings.Join(dr.PrimaryKey, ", "), dr.Buckets, dr.DiffBuckets),
+	}
+	if len(dr.SkippedColumns) > 0 {
+		lines = append(lines, g.F("skipped columns (not comparable): %s", strings.Join(dr.SkippedColumns, ", ")))
+	}
+
+	if dr.Equal() {
+		lines = append(lines, "no differences found")
+		return strings.Join(lines, "\n")
+	}
+
+	lines = append(lines, g.F("missing in target: %d", dr.Missing))
+	for _, key := range dr.MissingKeys {
+		lines = append(lines, "  - "+keyStr(key))
+	}
+	lines = append(lines, g.F("extra in target: %d", dr.Extra))
+	for _, key := range dr.ExtraKeys {
+		lines = append(lines, "  - "+keyStr(key))
+	}
+	lines = append(lines, g.F("changed: %d", dr.Changed))
+	for _, change := range dr.ChangedRows {
+		lines = append(lines, g.F("  - %s (%s)", keyStr(change.Key), strings.Join(change.Columns, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+const (
+	// diffNullText is the text of null values when hashing rows
+	diffNullText = "~null~"
+	// diffDecimalScale is the scale of the decimal values when hashing rows
+	diffDecimalScale = 1000000
+	// diffChunkRows is the max number of rows of the buckets compared at once
+	diffChunkRows = 100000
+)
+
+// diffSide holds the details of one of the compared tables
+type diffSide struct {
+	conn    Connection
+	table   Table
+	columns iop.Columns // the primary key columns, then the compared columns
+	bucket  string      // the bucket expression
+	hash    string      // the `function.diff_hash` template, empty if not supported
+}
+
+// normalized returns the expression of a column value, normalized per column type so
+// that the values of different databases have the same text: datetimes as epoch
+// microseconds, decimals as integers scaled to 6 decimals and booleans as 1 or 0
+func (ds *diffSide) normalized(col iop.Column) string {
+	field := ds.conn.Quote(col.Name)
+	switch {
+	case col.IsDate() || col.IsDatetime():
+		return g.R(checksumExpr(ds.conn, col), "field", field)
+	case col.IsFloat() || col.IsDecimal():
+		return g.R(ds.conn.GetTemplateValue("function.checksum_decimal"), "field", g.F("(%s * %d)", field, diffDecimalScale))
+	case col.IsBool():
+		return g.R(ds.conn.GetTemplateValue("function.diff_boolean"), "field", field)
+	}
+	return field
+}
+
+// text returns the expression of a normalized column value cast to text
+func (ds *diffSide) text(col iop.Column) string {
+	expr := g.R(ds.conn.GetTemplateValue("function.cast_to_text"), "field", ds.normalized(col))
+	return g.F("coalesce(%s, '%s')", expr, diffNullText)
+}
+
+// concat returns the concatenation of the expressions, with the two-argument
+// concat function, which is the most widely supported
+func (ds *diffSide) concat(exprs ...string) string {
+	expr := exprs[0]
+	for _, e := range exprs[1:] {
+		expr = g.F("concat(%s, %s)", expr, e)
+	}
+	return expr
+}
+
+// keyText returns the expression of the primary key values as text
+func (ds *diffSide) keyText(pkLen int) string {
+	exprs := []string{}
+	for i, col := range ds.columns[:pkLen] {
+		if i > 0 {
+			exprs = append(exprs, "'|'")
+		}
+		exprs = append(exprs, ds.text(col))
+	}
+	return ds.concat(exprs...)
+}
+
+// hashFields returns the hash expressions of the primary key, then of the compared
+// columns. The primary key is part of each hashed value, so that values swapped
+// between rows are detected
+func (ds *diffSide) hashFields(pkLen int) (fields []string) {
+	keyText := ds.keyText(pkLen)
+	fields = append(fields, g.R(ds.hash, "field", keyText))
+	for _, col := range ds.columns[pkLen:] {
+		fields = append(fields, g.R(ds.hash, "field", ds.concat(keyText, "'|'", ds.text(col))))
+	}
+	return
+}
+
+// bucketQuery aggregates the row count and column hashes per bucket
+func (ds *diffSide) bucketQuery(pkLen int) string {
+	fields := []string{ds.bucket + " as sling_bucket", "count(*) as sling_cnt"}
+	if ds.hash != "" {
+		for i, field := range ds.hashFields(pkLen) {
+			fields = append(fields, g.F("sum(%s) as cs_%d", field, i))
+		}
+	}
+
+	sql := g.F("select %s from %s", strings.Join(fields, ", "), ds.table.FullName())
+	if ds.bucket != "0" {
+		sql = sql + " group by " + ds.bucket
+	}
+	return sql
+}
+
+// rowsQuery returns the primary key and column values of the rows in the buckets
+func (ds *diffSide) rowsQuery(buckets []int64) string {
+	fields := lo.Map(ds.columns, func(c iop.Column, i int) string { return ds.conn.Quote(c.Name) })
+	bucketList := lo.Map(buckets, func(b int64, i int) string { return cast.ToString(b) })
+	return g.F(
+		"select %s from %s where %s in (%s)",
+		strings.Join(fields, ", "), ds.table.FullName(), ds.bucket, strings.Join(bucketList, ", "),
+	)
+}
+
+// DiffTables compares the rows of two tables, possibly on different connections, by primary key.
+// The primary keys are hashed into buckets with dialect-specific SQL, and the row count and the
+// sum of the md5 hashes of the column values (`function.diff_hash`) are compared per bucket. Only
+// the rows of differing buckets are then pulled, and their values compared to determine the
+// missing, extra and changed rows. If a dialect cannot hash, all buckets are compared by rows.
+func DiffTables(srcConn Connection, srcTableName string, tgtConn Connection, tgtTableName string, opts DiffOptions) (result DiffResult, err error) {
+	if len(opts.PrimaryKey) == 0 {
+		return result, g.Error("primary key is required to diff tables")
+	}
+	if opts.RowsPerBucket <= 0 {
+		opts.RowsPerBucket = 1000
+	}
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = 10
+	}
+
+	src := &diffSide{conn: srcConn, hash: srcConn.GetTemplateValue("function.diff_hash")}
+	tgt := &diffSide{conn: tgtConn, hash: tgtConn.GetTemplateValue("function.diff_hash")}
+	hashing := src.hash != "" && tgt.hash != ""
+	if !hashing {
+		g.Debug("row hashing is not supported for %s, comparing all rows", lo.Ternary(src.hash == "", srcConn.GetType(), tgtConn.GetType()))
+		src.hash, tgt.hash = "", ""
+	}
+	src.table, err = ParseTableName(srcTableName, srcConn.GetType())
+	if err != nil {
+		return result, g.Error(err, "could not parse source table name")
+	}
+	tgt.table, err = ParseTableName(tgtTableName, tgtConn.GetType())
+	if err != nil {
+		return result, g.Error(err, "could not parse target table name")
+	}
+	result.Source = src.table.FullName()
+	result.Target = tgt.table.FullName()
+
+	srcColumns, err := srcConn.GetColumns(src.table.FullName())
+	if err != nil {
+		return result, g.Error(err, "could not get columns for %s", result.Source)
+	}
+	tgtColumns, err := tgtConn.GetColumns(tgt.table.FullName())
+	if err != nil {
+		return result, g.Error(err, "could not get columns for %s", result.Target)
+	}
+
+	// match primary key columns, then common comparable columns
+	tgtColMap := lo.KeyBy(tgtColumns, func(c iop.Column) string { return strings.ToLower(c.Name) })
+	srcColMap := lo.KeyBy(srcColumns, func(c iop.Column) string { return strings.ToLower(c.Name) })
+	for _, pk := range opts.PrimaryKey {
+		srcCol, ok1 := srcColMap[strings.ToLower(pk)]
+		tgtCol, ok2 := tgtColMap[strings.ToLower(pk)]
+		if !ok1 || !ok2 {
+			return result, g.Error("primary key column %s not found in both tables", pk)
+		}
+		src.columns = append(src.columns, srcCol)
+		tgt.columns = append(tgt.columns, tgtCol)
+		result.PrimaryKey = append(result.PrimaryKey, srcCol.Name)
+	}
+
+	for _, srcCol := range srcColumns {
+		key := strings.ToLower(srcCol.Name)
+		if g.In(key, lo.Map(result.PrimaryKey, func(pk string, i int) string { return strings.ToLower(pk) })...) {
+			continue
+		}
+		tgtCol, ok := tgtColMap[key]
+		if !ok {
+			result.SkippedColumns = append(result.SkippedColumns, srcCol.Name)
+			continue
+		} else if !diffComparable(srcCol, tgtCol) {
+			g.Warn("column %s is not comparable (%s vs %s), skipping", srcCol.Name, srcCol.Type, tgtCol.Type)
+			result.SkippedColumns = append(result.SkippedColumns, srcCol.Name)
+			continue
+		}
+		src.columns = append(src.columns, srcCol)
+		tgt.columns = append(tgt.columns, tgtCol)
+		result.Columns = append(result.Columns, srcCol.Name)
+	}
+	pkLen := len(result.PrimaryKey)
+
+	// determine number of buckets from row counts
+	result.SourceCount, err = srcConn.GetCount(src.table.FullName())
+	if err != nil {
+		return result, g.Error(err, "could not get count for %s", result.Source)
+	}
+	result.TargetCount, err = tgtConn.GetCount(tgt.table.FullName())
+	if err != nil {
+		return result, g.Error(err, "could not get count for %s", result.Target)
+	}
+
+	result.Buckets = opts.Buckets
+	if result.Buckets <= 0 {
+		maxCnt := lo.Max([]uint64{result.SourceCount, result.TargetCount})
+		result.Buckets = int(math.Ceil(float64(maxCnt) / float64(opts.RowsPerBucket)))
+		result.Buckets = lo.Clamp(result.Buckets, 1, 100000)
+	}
+
+	// single integer keys are bucketed by value, other keys by the hash of their text
+	intKey := pkLen == 1 && src.columns[0].IsInteger() && tgt.columns[0].IsInteger()
+	for _, side := range []*diffSide{src, tgt} {
+		field := ""
+		switch {
+		case intKey:
+			pkCol := side.columns[0]
+			field = g.F("abs(coalesce(%s, 0))", g.R(checksumExpr(side.conn, pkCol), "field", side.conn.Quote(pkCol.Name)))
+		case hashing:
+			field = g.R(side.hash, "field", side.keyText(pkLen))
+		default:
+			side.bucket = "0" // cannot hash keys, single bucket
+			result.Buckets = 1
+			continue
+		}
+
+		side.bucket = g.R(
+			side.conn.GetTemplateValue("function.diff_bucket"),
+			"field", field,
+			"buckets", cast.ToString(result.Buckets),
+		)
+	}
+
+	// compare buckets
+	srcBuckets, err := diffQueryBuckets(src, pkLen)
+	if err != nil {
+		return result, g.Error(err, "could not get buckets for %s", result.Source)
+	}
+	tgtBuckets, err := diffQueryBuckets(tgt, pkLen)
+	if err != nil {
+		return result, g.Error(err, "could not get buckets for %s", result.Target)
+	}
+
+	diffBuckets := []int64{}
+	for bucket, srcVals := range srcBuckets {
+		if tgtVals, ok := tgtBuckets[bucket]; !ok || !hashing || !diffValuesEqual(srcVals, tgtVals) {
+			diffBuckets = append(diffBuckets, bucket)
+		}
+	}
+	for bucket := range tgtBuckets {
+		if _, ok := srcBuckets[bucket]; !ok {
+			diffBuckets = append(diffBuckets, bucket)
+		}
+	}
+	sort.Slice(diffBuckets, func(i, j int) bool { return diffBuckets[i] < diffBuckets[j] })
+	result.DiffBuckets = len(diffBuckets)
+	if len(diffBuckets) == 0 {
+		return
+	}
+	g.Debug("%d of %d buckets differ, comparing rows", len(diffBuckets), result.Buckets)
+
+	// compare rows of differing buckets, by chunks so that only the rows of
+	// a chunk are held in memory
+	for _, chunk := range diffChunks(diffBuckets, srcBuckets, tgtBuckets) {
+		srcRows, err := diffQueryRows(src, pkLen, chunk)
+		if err != nil {
+			return result, g.Error(err, "could not get rows for %s", result.Source)
+		}
+		tgtRows, err := diffQueryRows(tgt, pkLen, chunk)
+		if err != nil {
+			return result, g.Error(err, "could not get rows for %s", result.Target)
+		}
+		result.compareRows(srcRows, tgtRows, pkLen, opts.SampleSize)
+	}
+
+	return
+}
+
+// compareRows counts the missing, extra and changed rows, keeping samples of their keys
+func (dr *DiffResult) compareRows(srcRows, tgtRows map[string][]any, pkLen, sampleSize int) {
+	srcKeys := lo.Keys(srcRows)
+	sort.Strings(srcKeys)
+	for _, key := range srcKeys {
+		srcRow := srcRows[key]
+		tgtRow, ok := tgtRows[key]
+		if !ok {
+			dr.Missing++
+			if len(dr.MissingKeys) < sampleSize {
+				dr.MissingKeys = append(dr.MissingKeys, srcRow[:pkLen])
+			}
+			continue
+		}
+
+		changed := []string{}
+		for i, colName := range dr.Columns {
+			if !diffValueEqual(srcRow[pkLen+i], tgtRow[pkLen+i]) {
+				changed = append(changed, colName)
+			}
+		}
+		if len(changed) > 0 {
+			dr.Changed++
+			if len(dr.ChangedRows) < sampleSize {
+				dr.ChangedRows = append(dr.ChangedRows, DiffChange{Key: srcRow[:pkLen], Columns: changed})
+			}
+		}
+	}
+
+	tgtKeys := lo.Keys(tgtRows)
+	sort.Strings(tgtKeys)
+	for _, key := range tgtKeys {
+		if _, ok := srcRows[key]; !ok {
+			dr.Extra++
+			if len(dr.ExtraKeys) < sampleSize {
+				dr.ExtraKeys = append(dr.ExtraKeys, tgtRows[key][:pkLen])
+			}
+		}
+	}
+}
+
+// diffChunks splits the buckets into chunks of up to diffChunkRows rows on
+// either side, from the bucket row counts. A chunk has at least one bucket
+func diffChunks(buckets []int64, srcBuckets, tgtBuckets map[int64][]any) (chunks [][]int64) {
+	count := func(buckets map[int64][]any, bucket int64) int64 {
+		if vals, ok := buckets[bucket]; ok && len(vals) > 0 {
+			return cast.ToInt64(vals[0])
+		}
+		return 0
+	}
+
+	chunk, rows := []int64{}, int64(0)
+	for _, bucket := range buckets {
+		bucketRows := lo.Max([]int64{count(srcBuckets, bucket), count(tgtBuckets, bucket)})
+		if len(chunk) > 0 && (rows+bucketRows > diffChunkRows || len(chunk) >= 500) {
+			chunks = append(chunks, chunk)
+			chunk, rows = []int64{}, 0
+		}
+		chunk = append(chunk, bucket)
+		rows += bucketRows
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return
+}
+
+// diffQueryBuckets returns the row count and column hashes, keyed by bucket
+func diffQueryBuckets(side *diffSide, pkLen int) (buckets map[int64][]any, err error) {
+	data, err := side.conn.Query(side.bucketQuery(pkLen))
+	if err != nil {
+		return nil, err
+	}
+
+	buckets = map[int64][]any{}
+	for _, row := range data.Rows {
+		buckets[cast.ToInt64(diffKeyValue(row[0]))] = row[1:]
+	}
+	return
+}
+
+// diffQueryRows returns the primary key and column values of the rows
+// in the buckets, keyed by the normalized primary key values
+func diffQueryRows(side *diffSide, pkLen int, buckets []int64) (rows map[string][]any, err error) {
+	ds, err := side.conn.StreamRows(side.rowsQuery(buckets))
+	if err != nil {
+		return nil, err
+	}
+
+	rows = map[string][]any{}
+	for row := range ds.Rows() {
+		keyParts := lo.Map(row[:pkLen], func(v any, i int) string { return diffKeyValue(v) })
+		rows[strings.Join(keyParts, "\x1f")] = row
+	}
+	return rows, ds.Err()
+}
+
+// diffComparable returns true if the values of both columns are comparable
+func diffComparable(col1, col2 iop.Column) bool {
+	isNumber := func(c iop.Column) bool { return c.IsInteger() || c.IsFloat() || c.IsDecimal() }
+	isTime := func(c iop.Column) bool { return c.IsDate() || c.IsDatetime() }
+	switch {
+	case col1.Type == col2.Type:
+		return true
+	case isNumber(col1) && isNumber(col2):
+		return true
+	case isTime(col1) && isTime(col2):
+		return true
+	case col1.IsString() && col2.IsString():
+		return true
+	}
+	return false
+}
+
+// diffKeyValue normalizes a primary key value, so that keys match across databases
+func diffKeyValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		if val == math.Trunc(val) {
+			return strconv.FormatInt(int64(val), 10)
+		}
+	case float32:
+		if val == float32(math.Trunc(float64(val))) {
+			return strconv.FormatInt(int64(val), 10)
+		}
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if val != nil {
+			return val.UTC().Format(time.RFC3339Nano)
+		}
+	case []byte:
+		return string(val)
+	case string:
+		// decimal strings such as 1.000
+		if strings.Contains(val, ".") {
+			if f, ok := new(big.Float).SetString(val); ok && f.IsInt() {
+				return f.Text('f', 0)
+			}
+		}
+		return val
+	}
+	return cast.ToString(v)
+}
+
+// diffValueEqual compares two values, normalized across databases
+func diffValueEqual(v1, v2 any) bool {
+	if (v1 == nil) != (v2 == nil) {
+		return false
+	}
+
+	s1, s2 := diffKeyValue(v1), diffKeyValue(v2)
+	if s1 == s2 {
+		return true
+	}
+
+	// numbers may be returned with different precisions or as strings
+	f1, ok1 := new(big.Float).SetString(s1)
+	f2, ok2 := new(big.Float).SetString(s2)
+	if ok1 && ok2 {
+		return f1.Cmp(f2) == 0
+	}
+
+	// booleans may be stored as numbers or strings
+	_, isBool1 := v1.(bool)
+	_, isBool2 := v2.(bool)
+	if isBool1 || isBool2 {
+		b1, err1 := cast.ToBoolE(s1)
+		b2, err2 := cast.ToBoolE(s2)
+		return err1 == nil && err2 == nil && b1 == b2
+	}
+
+	// datetimes may be returned as strings
+	_, isTime1 := v1.(time.Time)
+	_, isTime2 := v2.(time.Time)
+	if isTime1 || isTime2 {
+		t1, err1 := cast.ToTimeE(v1)
+		t2, err2 := cast.ToTimeE(v2)
+		return err1 == nil && err2 == nil && t1.Equal(t2)
+	}
+
+	return false
+}
+
+func diffValuesEqual(vals1, vals2 []any) bool {
+	if len(vals1) != len(vals2) {
+		return false
+	}
+	for i := range vals1 {
+		if !diffValueEqual(vals1[i], vals2[i]) {
+			return false
+		}
+	}
+	return true
+}
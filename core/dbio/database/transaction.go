@@ -288,6 +288,7 @@ func InsertBatchStream(conn Connection, tx Transaction, tableFName string, ds *i
 	}
 	_ = mux
 
+	var rejectedCnt uint64 // rows failing to insert, sent to the reject writer
 	insertBatch := func(bColumns iop.Columns, rows [][]interface{}) {
 		var err error
 		defer context.Wg.Write.Done()
@@ -318,7 +319,7 @@ func InsertBatchStream(conn Connection, tx Transaction, tableFName string, ds *i
 		}
 
 		vals := []interface{}{}
-		for _, row := range rows {
+		for i, row := range rows {
 			if conn.GetType() == dbio.TypeDbClickhouse {
 				row = processClickhouseInsertRow(bColumns, row)
 			} else if conn.GetType() == dbio.TypeDbTrino {
@@ -326,12 +327,22 @@ func InsertBatchStream(conn Connection, tx Transaction, tableFName string, ds *i
 			} else if conn.GetType() == dbio.TypeDbProton {
 				row = processClickhouseInsertRow(bColumns, row)
 			}
+			rows[i] = row
 			vals = append(vals, row...)
 		}
 
 		// Do insert
 		_, err = stmt.ExecContext(ds.Context.Ctx, vals...)
-		if err != nil {
+		if err != nil && ds.Sp.Config.Rejects != nil {
+			// insert row by row, to reject the failing rows only
+			stmt.Close()
+			cnt, err := insertRowsOrReject(conn, tx, tableFName, ds, insCols, rows)
+			if err != nil {
+				context.CaptureErr(err)
+			}
+			rejectedCnt += cnt
+			return
+		} else if err != nil {
 			batchErrStr := g.F("Batch Size: %d rows x %d cols = %d (%d vals)", len(rows), len(bColumns), len(rows)*len(bColumns), len(vals))
 			if len(insertTemplate) > 3000 {
 				insertTemplate = insertTemplate[:3000]
@@ -435,6 +446,7 @@ func InsertBatchStream(conn Connection, tx Transaction, tableFName string, ds *i
 	context.Wg.Write.Wait()
 	err = context.Err()
 	ds.SetEmpty()
+	count -= rejectedCnt
 
 	if err != nil {
 		ds.Context.Cancel()
@@ -448,6 +460,32 @@ func InsertBatchStream(conn Connection, tx Transaction, tableFName string, ds *i
 	return count, nil
 }
 
+// insertRowsOrReject inserts the rows one at a time, writing the rows failing
+// to insert into the reject writer of the stream. Returns the number of rejected rows
+func insertRowsOrReject(conn Connection, tx Transaction, tableFName string, ds *iop.Datastream, insCols iop.Columns, rows [][]any) (rejected uint64, err error) {
+	insertTemplate := conn.Self().GenerateInsertStatement(tableFName, insCols, 1)
+
+	var stmt *sql.Stmt
+	if tx != nil {
+		stmt, err = tx.Prepare(insertTemplate)
+	} else {
+		stmt, err = conn.Prepare(insertTemplate)
+	}
+	if err != nil {
+		return 0, g.Error(err, "Error in PrepareContext")
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ds.Context.Ctx, row...); err != nil {
+			ds.Reject(row, "", g.F("could not insert into %s: %s", tableFName, err.Error()))
+			rejected++
+		}
+	}
+
+	return rejected, nil
+}
+
 // Upsert upserts from source table into target table
 func Upsert(conn Connection, tx Transaction, sourceTable, targetTable string, pkFields []string) (count int64, err error) {
 
@@ -0,0 +1,112 @@
+package iop
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/flarco/g"
+)
+
+// CastErrorPolicy is the policy to apply when a value cannot be cast into
+// the type forced for a column
+type CastErrorPolicy string
+
+const (
+	CastErrorNull   CastErrorPolicy = "null"   // set the value to null
+	CastErrorReject CastErrorPolicy = "reject" // reject the row, requires `reject_to`
+	CastErrorFail   CastErrorPolicy = "fail"   // fail the stream
+	// `default:<value>` sets the value to the provided default
+)
+
+var regexCastErrorPolicy = regexp.MustCompile(`(?i)^\s*on_cast_error\s*[:=]\s*(.+?)\s*$`)
+
+// Default returns the default value of a `default:<value>` policy
+func (p CastErrorPolicy) Default() (value string, ok bool) {
+	prefix := string(p)
+	if len(prefix) < 8 || !strings.EqualFold(prefix[:8], "default:") {
+		return "", false
+	}
+	return strings.TrimSpace(prefix[8:]), true
+}
+
+// Validate checks the policy, and that the default value can be cast into the column type
+func (p CastErrorPolicy) Validate(colType ColumnType) error {
+	switch p {
+	case "", CastErrorNull, CastErrorReject, CastErrorFail:
+		return nil
+	}
+
+	value, ok := p.Default()
+	if !ok {
+		return g.Error("invalid on_cast_error policy (%s). Expecting one of: null, default:<value>, reject, fail", p)
+	}
+
+	sp := NewStreamProcessor()
+	sp.rowChecksum = make([]uint64, 1)
+	col := Column{Type: colType, Sourced: true, OnCastError: CastErrorNull}
+	if sp.CastVal(0, value, &col); sp.colStats[0].CastErrCnt > 0 {
+		return g.Error("default value of on_cast_error (%s) cannot be cast into %s", value, colType)
+	}
+	return nil
+}
+
+// SetCastErrorPolicy parses the cast error policy from the column type,
+// such as `decimal(18,2) | on_cast_error: null`
+func (col *Column) SetCastErrorPolicy() {
+	parts := strings.Split(string(col.Type), "|")
+	if len(parts) < 2 {
+		return
+	}
+
+	kept := []string{}
+	for i, part := range parts {
+		if m := regexCastErrorPolicy.FindStringSubmatch(part); i > 0 && len(m) == 2 {
+			col.OnCastError = CastErrorPolicy(m[1])
+			if _, ok := col.OnCastError.Default(); !ok {
+				col.OnCastError = CastErrorPolicy(strings.ToLower(m[1]))
+			}
+			continue
+		}
+		kept = append(kept, part)
+	}
+
+	// fix type value
+	col.Type = ColumnType(strings.TrimSpace(strings.Join(kept, "|")))
+}
+
+// handleCastError applies the cast error policy of the column, when a value
+// cannot be cast into its forced type. Returns false when not handled, so the
+// column type is changed to accommodate the value.
+func (sp *StreamProcessor) handleCastError(i int, col *Column, cs *ColumnStats, val any) (nVal any, handled bool) {
+	if !col.Sourced {
+		return nil, false
+	}
+	cs.CastErrCnt++
+
+	switch policy := col.OnCastError; policy {
+	case "":
+		// reject if enabled, otherwise keep the value
+		if !sp.rejectCurrent(col, g.F("could not cast value into %s", col.Type), val) {
+			return nil, false
+		}
+	case CastErrorNull:
+	case CastErrorReject:
+		if !sp.rejectCurrent(col, g.F("could not cast value into %s", col.Type), val) && sp.ds != nil {
+			sp.ds.Context.CaptureErr(g.Error("on_cast_error is 'reject' for column %s, but reject_to is not set", col.Name))
+		}
+	case CastErrorFail:
+		if sp.ds != nil {
+			sp.ds.Context.CaptureErr(g.Error("could not cast value %#v of column %s into %s (row %d)", val, col.Name, col.Type, sp.N))
+		}
+	default:
+		if value, ok := policy.Default(); ok {
+			dCol := *col
+			dCol.OnCastError = CastErrorNull // so we don't loop
+			return sp.CastVal(i, value, &dCol), true
+		}
+	}
+
+	cs.TotalCnt++
+	cs.NullCnt++
+	return nil, true
+}
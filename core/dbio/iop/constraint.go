@@ -0,0 +1,57 @@
+package iop
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// ConstraintOnFail is the policy to apply when a constraint fails
+type ConstraintOnFail string
+
+const (
+	ConstraintOnFailWarn  ConstraintOnFail = "warn"  // log the failure and keep the row. The default.
+	ConstraintOnFailSkip  ConstraintOnFail = "skip"  // drop the row from the stream
+	ConstraintOnFailAbort ConstraintOnFail = "abort" // fail the stream
+)
+
+var regexConstraintOnFail = regexp.MustCompile(`(?i)\|\s*on_fail\s*[:=]\s*(\w+)\s*$`)
+
+// parseConstraintExpression parses a constraint expression into an evaluation function.
+// The expression references the column value with `value`, or its length with `value_len`.
+// Supported are comparisons (=, !=, <>, <, <=, >, >=), `in` / `not in` lists,
+// `is null` / `is not null` (or simply `not null`), regex matching with `~` / `!~`,
+// ranges with `between`, combined with `and`, `or`, `not` and parentheses.
+// When the operand is omitted, `value` is implied, e.g. `> 0`. Other words are taken
+// as strings. The expression is parsed with the expression parser, see ParseExpression.
+// As with SQL check constraints, null values only fail a `not null` check.
+func parseConstraintExpression(expr string) (ConstraintEvalFunc, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil, g.Error(err, "could not parse constraint expression: %s", expr)
+	}
+
+	p := &exprParser{tokens: tokens, constraint: true}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, g.Error(err, "could not parse constraint expression: %s", expr)
+	} else if !p.done() {
+		return nil, g.Error("could not parse constraint expression: %s. Unexpected token: %s", expr, p.peek().text)
+	}
+
+	evalFunc := func(value any) bool {
+		result, err := root.eval(&exprContext{row: []any{value}, parseTime: cast.ToTimeE})
+		if err != nil {
+			return false
+		}
+		return result == nil || exprTruthy(result) // null passes
+	}
+
+	return ConstraintEvalFunc(evalFunc), nil
+}
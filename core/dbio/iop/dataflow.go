@@ -442,6 +442,10 @@ func (df *Dataflow) SyncStats() {
 	dfCols := df.Columns.Clone()
 	for i, col := range dfCols {
 		dfCols[i].Stats = ColumnStats{MaxLen: col.Stats.MaxLen} // keep manual column length spec
+		if c := col.Constraint; c != nil {
+			// fresh copy to aggregate failures from streams
+			dfCols[i].Constraint = &ColumnConstraint{Expression: c.Expression, OnFail: c.OnFail, EvalFunc: c.EvalFunc}
+		}
 	}
 
 	for _, ds := range df.Streams {
@@ -467,6 +471,7 @@ func (df *Dataflow) SyncStats() {
 			dfCols[i].Stats.DateCnt = dfCols[i].Stats.DateCnt + colStats.DateCnt
 			dfCols[i].Stats.DateTimeCnt = dfCols[i].Stats.DateTimeCnt + colStats.DateTimeCnt
 			dfCols[i].Stats.DateTimeZCnt = dfCols[i].Stats.DateTimeZCnt + colStats.DateTimeZCnt
+			dfCols[i].Stats.CastErrCnt = dfCols[i].Stats.CastErrCnt + colStats.CastErrCnt
 			dfCols[i].Stats.Checksum = dfCols[i].Stats.Checksum + colStats.Checksum
 
 			if colStats.Min < dfCols[i].Stats.Min {
@@ -481,9 +486,17 @@ func (df *Dataflow) SyncStats() {
 			if colStats.MaxDecLen > dfCols[i].Stats.MaxDecLen {
 				dfCols[i].Stats.MaxDecLen = colStats.MaxDecLen
 			}
+			if colStats.UniqCnt > dfCols[i].Stats.UniqCnt {
+				// the distinct counter is shared by the streams
+				dfCols[i].Stats.UniqCnt = colStats.UniqCnt
+			}
 
 			if col.Constraint != nil {
+				if dfCols[i].Constraint == nil {
+					dfCols[i].Constraint = &ColumnConstraint{Expression: col.Constraint.Expression, OnFail: col.Constraint.OnFail}
+				}
 				dfCols[i].Constraint.FailCnt = dfCols[i].Constraint.FailCnt + col.Constraint.FailCnt
+				dfCols[i].Constraint.Errors = append(dfCols[i].Constraint.Errors, col.Constraint.Errors...)
 			}
 		}
 	}
@@ -763,6 +776,11 @@ func MergeDataflow(df *Dataflow) (dsN *Datastream) {
 
 			ds.Buffer = nil // clear buffer
 		}
+
+		// carry over errors of the merged streams
+		if err := df.Err(); err != nil {
+			dsN.Context.CaptureErr(err)
+		}
 	}()
 
 	err := dsN.Start()
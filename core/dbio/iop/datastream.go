@@ -10,6 +10,7 @@ import (
 	"path"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -81,6 +82,7 @@ type FileStreamConfig struct {
 	Format           dbio.FileType     `json:"format"`
 	IncrementalKey   string            `json:"incremental_key"`
 	IncrementalValue string            `json:"incremental_value"`
+	Where            string            `json:"where"` // row filter expression, pushed down if possible
 	Props            map[string]string `json:"props"`
 }
 
@@ -128,6 +130,9 @@ type Iterator struct {
 	incrementalVal  any
 	incrementalCol  string
 	incrementalColI int
+
+	pending  [][]any // exploded rows to emit next
+	exploded bool    // whether the current row is exploded
 }
 
 // NewDatastream return a new datastream
@@ -413,6 +418,188 @@ func (ds *Datastream) Close() {
 	}
 }
 
+// transformColumns returns the computed columns for the `split` and
+// `json_extract` transforms. For `split`, there is one column per part of
+// the value, the number of parts being the max found in the sampled rows
+// unless provided. For `json_extract`, the type is inferred from the sampled rows
+func (ds *Datastream) transformColumns(sample [][]any) (computed []ComputedColumn) {
+	fieldMap := ds.Columns.FieldMap(true)
+	ensureName := func(name string) string {
+		for _, ok := fieldMap[strings.ToLower(name)]; ok; _, ok = fieldMap[strings.ToLower(name)] {
+			name = name + "_"
+		}
+		fieldMap[strings.ToLower(name)] = -1
+		return name
+	}
+
+	for i, col := range ds.Columns {
+		for _, t := range ds.Sp.Config.transforms[strings.ToLower(col.Name)] {
+			switch {
+			case t.Name == TransformSplit.Name:
+				delim := cast.ToString(t.params[0])
+				parts := 1
+				if len(t.params) > 1 {
+					parts = cast.ToInt(t.params[1])
+				} else {
+					for _, row := range sample {
+						if i < len(row) && row[i] != nil {
+							parts = max(parts, strings.Count(cast.ToString(row[i]), delim)+1)
+						}
+					}
+				}
+
+				for p := 1; p <= parts; p++ {
+					computed = append(computed, ComputedColumn{
+						Name: ensureName(g.F("%s_%d", col.Name, p)),
+						Expression: g.F(
+							"split_part(%s, %s, %d)",
+							quoteExprString(col.Name, '"'), quoteExprString(delim, '\''), p,
+						),
+						Type: StringType,
+					})
+				}
+
+			case t.Name == TransformJSONExtract.Name && len(t.params) > 0:
+				path := cast.ToString(t.params[0])
+				name := g.F("%s_%s", col.Name, strings.Join(jsonPathKeys(path), "_"))
+				if len(t.params) > 1 {
+					name = cast.ToString(t.params[1])
+				}
+
+				values := NewDataset(Columns{{Name: name}})
+				values.NoDebug = true
+				values.SafeInference = ds.SafeInference
+				values.Sp.dateLayouts = ds.Sp.dateLayouts
+				values.Sp.Config = ds.Sp.Config
+				for _, row := range sample {
+					if i < len(row) {
+						val, _ := Transforms.JSONExtract(row[i], path)
+						values.Rows = append(values.Rows, []any{val})
+					}
+				}
+				values.InferColumnTypes()
+
+				computed = append(computed, ComputedColumn{
+					Name: ensureName(name),
+					Expression: g.F(
+						"json_extract(%s, %s)",
+						quoteExprString(col.Name, '"'), quoteExprString(path, '\''),
+					),
+					Type: lo.Ternary(values.Columns[0].Type == "", StringType, values.Columns[0].Type),
+				})
+			}
+		}
+	}
+	return computed
+}
+
+// explodeIndexes returns the indexes of the columns to explode, from the
+// `explode` transforms of a column, or `explode(column)`
+func (ds *Datastream) explodeIndexes() (indexes []int) {
+	fieldMap := ds.Columns.FieldMap(true)
+	for key, transforms := range ds.Sp.Config.transforms {
+		for _, t := range transforms {
+			if t.Name != TransformExplode.Name {
+				continue
+			}
+
+			name := key
+			if len(t.params) > 0 {
+				name = cast.ToString(t.params[0])
+			}
+			if index, ok := fieldMap[strings.ToLower(name)]; ok {
+				if !lo.Contains(indexes, index) {
+					indexes = append(indexes, index)
+				}
+			} else {
+				g.Warn("column '%s' not found for transform 'explode'", name)
+			}
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// sampleRows returns the buffered rows, exploded if needed
+func (ds *Datastream) sampleRows() [][]any {
+	if len(ds.Sp.explode) == 0 {
+		return ds.Buffer
+	}
+
+	sample := make([][]any, 0, len(ds.Buffer))
+	for _, row := range ds.Buffer {
+		sample = append(sample, ds.Sp.ExplodeRow(row)...)
+	}
+	return sample
+}
+
+// addComputedColumns adds the configured computed columns to the stream,
+// and binds their expressions to the stream columns. The columns are added
+// in the configured order, and evaluated in the order of their references.
+func (ds *Datastream) addComputedColumns() (err error) {
+	computed := append(ds.transformColumns(ds.sampleRows()), ds.Sp.Config.ComputedColumns...)
+	if len(computed) == 0 {
+		return nil
+	}
+
+	ordered, err := PrepareComputedColumns(computed)
+	if err != nil {
+		return g.Error(err, "could not prepare computed columns")
+	}
+
+	// lay out the columns, new ones after the stream columns
+	columns := ds.Columns.Clone()
+	fieldMap := columns.FieldMap(true)
+	for _, cc := range computed {
+		if _, ok := fieldMap[strings.ToLower(cc.Name)]; !ok {
+			columns = append(columns, Column{Name: cc.Name, Position: len(columns) + 1})
+			fieldMap[strings.ToLower(cc.Name)] = len(columns) - 1
+		}
+	}
+
+	// bind in evaluation order, so the types of referenced computed columns are known
+	ds.Sp.computedIndex = map[int]bool{}
+	for i, cc := range ordered {
+		if err = cc.expr.Bind(columns); err != nil {
+			return g.Error(err, "could not bind computed column %s", cc.Name)
+		}
+		cc.expr.parseTime = ds.Sp.CastToTime
+
+		colType := cc.Type
+		if colType == "" {
+			colType = cc.expr.Type()
+		} else if !colType.IsValid() {
+			return g.Error("invalid type for computed column %s: %s", cc.Name, colType)
+		}
+
+		index := fieldMap[strings.ToLower(cc.Name)]
+		columns[index] = Column{
+			Name:        cc.Name,
+			Type:        colType,
+			Position:    index + 1,
+			Description: "Sling.ComputedColumn",
+			Metadata:    map[string]string{"expression": cc.Expression},
+		}
+
+		ordered[i].index = index
+		ds.Sp.computedIndex[index] = true
+	}
+
+	// computed columns with the name of a stream column replace it
+	for i := range ds.Columns {
+		if ds.Sp.computedIndex[i] {
+			ds.Sp.computedReplace = true
+			ds.Columns[i].Description = columns[i].Description
+			ds.Columns[i].Metadata = columns[i].Metadata
+			ds.ChangeColumn(i, columns[i].Type)
+		}
+	}
+	ds.AddColumns(columns[len(ds.Columns):], false)
+
+	ds.Sp.computed = ordered
+	return nil
+}
+
 // SetColumns sets the columns
 func (ds *Datastream) AddColumns(newCols Columns, overwrite bool) (added Columns) {
 	mergedCols, colsAdded, colsChanged := ds.Columns.Merge(newCols, overwrite)
@@ -691,10 +878,13 @@ loop:
 		}
 	}
 
+	// columns to explode into rows
+	ds.Sp.explode = ds.explodeIndexes()
+
 	// infer types
 	if !ds.Inferred {
 		sampleData := NewDataset(ds.Columns)
-		sampleData.Rows = ds.Buffer
+		sampleData.Rows = ds.sampleRows()
 		sampleData.NoDebug = ds.NoDebug
 		sampleData.SafeInference = ds.SafeInference
 		sampleData.Sp.dateLayouts = ds.Sp.dateLayouts
@@ -802,6 +992,57 @@ loop:
 		}
 	}
 
+	// add computed columns
+	if err = ds.addComputedColumns(); err != nil {
+		return err
+	}
+
+	// bind row filter, on the source and computed columns
+	if where := ds.Sp.Config.Where; where != "" {
+		if ds.Sp.where, err = ParseExpression(where); err != nil {
+			return g.Error(err, "invalid where filter")
+		} else if err = ds.Sp.where.Bind(ds.Columns); err != nil {
+			return g.Error(err, "could not bind where filter")
+		}
+		ds.Sp.where.parseTime = ds.Sp.CastToTime
+
+		// the values to cast for the filter. All of them if a computed
+		// column is referenced, since it may reference any column
+		ds.Sp.whereIndexes = []int{}
+		fieldMap := ds.Columns.FieldMap(true)
+		for _, ref := range ds.Sp.where.References() {
+			if ds.Sp.computedIndex[fieldMap[ref]] {
+				ds.Sp.whereIndexes = lo.Range(len(ds.Columns))
+				ds.Sp.whereComputed = true
+				break
+			}
+			ds.Sp.whereIndexes = append(ds.Sp.whereIndexes, fieldMap[ref])
+		}
+	}
+
+	// columns with distinct values counted, for the unique expectations
+	if dc := ds.Sp.Config.Distinct; dc != nil {
+		ds.Sp.distinct = map[int]string{}
+		for i, col := range ds.Columns {
+			if dc.Counts(col.Name) {
+				ds.Sp.distinct[i] = col.Name
+			}
+		}
+	}
+
+	// add lookup columns
+	if err = ds.addLookupColumns(); err != nil {
+		return err
+	}
+
+	// transforms can change the column type, e.g. parse_datetime.
+	// masked values are strings, whatever the source type
+	for i, col := range ds.Columns {
+		if colType := ds.Sp.transformedType(col); colType != "" {
+			setChangedType(&ds.Columns[i], colType)
+		}
+	}
+
 	// setMetaValues sets mata column values
 	setMetaValues := func(it *Iterator) []any { return it.Row }
 	if len(metaValuesMap) > 0 {
@@ -862,13 +1103,44 @@ loop:
 			for {
 				// reprocess row if needed (to expand it as needed)
 				ds.it.Row = setMetaValues(ds.it)
-				if ds.it.IsCasted || ds.it.RowIsCasted {
+				if len(ds.Sp.explode) > 0 && !ds.it.exploded && !ds.it.RowIsCasted {
+					// the other rows are emitted next by the iterator
+					rows := ds.Sp.ExplodeRow(ds.it.Row)
+					ds.it.Row, ds.it.exploded = rows[0], true
+					ds.it.pending = append(ds.it.pending, rows[1:]...)
+				}
+				if !ds.it.RowIsCasted { // reprocessed rows are already filtered
+					if ok, err := ds.Sp.MatchesWhere(ds.it.Row, ds.Columns); err != nil {
+						ds.Context.CaptureErr(err)
+						break loop
+					} else if !ok {
+						goto loop
+					}
+				}
+				if ds.it.IsCasted && len(ds.Sp.Config.transforms) > 0 && !ds.it.RowIsCasted {
+					// casted values still need to be transformed. Copy so the source rows are kept
+					row = ds.Sp.CastRow(append(make([]any, 0, len(ds.Columns)), ds.it.Row...), ds.Columns)
+				} else if ds.it.IsCasted || ds.it.RowIsCasted {
 					row = ds.it.Row
+					ds.Sp.skipCurrent = false
+					if !ds.it.RowIsCasted { // reprocessed rows are already computed
+						if len(ds.Sp.computed) > 0 {
+							row = ds.Sp.ComputeRow(row, ds.Columns)
+						}
+						if len(ds.Sp.lookups) > 0 {
+							row = ds.Sp.LookupRow(row, ds.Columns)
+						}
+						if ds.Sp.rejectPending != nil {
+							ds.Sp.collectReject(row)
+						}
+					}
 				} else {
 					row = ds.Sp.CastRow(ds.it.Row, ds.Columns)
 				}
 				if ds.config.SkipBlankLines && ds.Sp.rowBlankValCnt == len(row) {
 					goto loop
+				} else if ds.Sp.skipCurrent {
+					goto loop
 				}
 				if ds.Limited() {
 					break loop
@@ -2651,6 +2923,7 @@ func (it *Iterator) incrementStreamRowNum() {
 
 func (it *Iterator) next() bool {
 	it.RowIsCasted = false // reset RowIsCasted
+	it.exploded = false
 
 	select {
 	case <-it.Context.Ctx.Done():
@@ -2660,6 +2933,14 @@ func (it *Iterator) next() bool {
 		it.RowIsCasted = true // skip re-casting of single row
 		return true
 	default:
+		// emit the rows exploded from the previous row
+		if len(it.pending) > 0 {
+			it.Row, it.pending = it.pending[0], it.pending[1:]
+			it.exploded = true
+			it.incrementStreamRowNum()
+			return true
+		}
+
 		if it.Closed {
 			return false
 		}
@@ -1,7 +1,112 @@
 package iop
 
-import "testing"
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+)
 
 func TestBatching(t *testing.T) {
 
 }
+
+func TestPlugin(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not found")
+	}
+
+	newStream := func() *Datastream {
+		data := NewDataset(Columns{
+			{Name: "id", Type: BigIntType},
+			{Name: "name", Type: StringType},
+		})
+		data.Inferred = true
+		data.Append([]any{int64(1), "a"}, []any{int64(2), "b"}, []any{int64(3), nil})
+		return data.Stream()
+	}
+
+	// cat echoes the rows, in either format
+	for _, format := range []PluginFormat{PluginFormatJsonLines, PluginFormatArrow} {
+		plugin := Plugin{Command: []string{"cat"}, Format: format, BatchSize: 2}
+		ds, err := plugin.Run(newStream())
+		if !assert.NoError(t, err, format) {
+			continue
+		}
+
+		result, err := ds.Collect(0)
+		if assert.NoError(t, err, format) && assert.Len(t, result.Rows, 3, format) {
+			assert.Equal(t, []string{"id", "name"}, result.Columns.Names(), format)
+			if format == PluginFormatArrow {
+				assert.Equal(t, BigIntType, result.Columns[0].Type)
+			}
+			assert.EqualValues(t, 2, cast.ToInt64(result.Rows[1][0]), format)
+			assert.Equal(t, "b", result.Rows[1][1], format)
+			assert.Nil(t, result.Rows[2][1], format)
+		}
+	}
+
+	// errors include the stderr output. The plugin exits before writing
+	// any row, so the error is returned when sampling the rows
+	plugin := Plugin{Command: []string{"sh", "-c", "cat > /dev/null; echo 'bad input' >&2; exit 3"}}
+	_, err := plugin.Run(newStream())
+	assert.ErrorContains(t, err, "bad input")
+}
+
+func TestScript(t *testing.T) {
+	script, err := NewScript("test.star", `
+def transform(row):
+    if row["qty"] == 0:
+        return None  # drop
+    row["total"] = row["qty"] * row["price"]
+    row.pop("price")
+    return row
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	script.Workers = 4 // the order is kept
+
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "qty", Type: BigIntType},
+		{Name: "price", Type: FloatType},
+	})
+	data.Inferred = true
+	data.Append([]any{int64(1), int64(2), 1.5}, []any{int64(2), int64(0), 3.0}, []any{int64(3), int64(1), 4.0})
+
+	ds, err := script.Run(data.Stream())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result, err := ds.Collect(0)
+	if assert.NoError(t, err) && assert.Len(t, result.Rows, 2) {
+		assert.Equal(t, []string{"id", "qty", "total"}, result.Columns.Names())
+		assert.EqualValues(t, 3, cast.ToFloat64(result.Rows[0][2]))
+		assert.EqualValues(t, 3, cast.ToInt64(result.Rows[1][0]))
+	}
+
+	// many rows, in order. In a dataflow, as the added columns are synced
+	data = NewDataset(Columns{{Name: "id", Type: BigIntType}, {Name: "qty", Type: BigIntType}, {Name: "price", Type: FloatType}})
+	data.Inferred = true
+	for i := 0; i < 5000; i++ {
+		data.Append([]any{int64(i), int64(i % 3), 1.0})
+	}
+	ds, err = script.Run(data.Stream())
+	if !assert.NoError(t, err) {
+		return
+	}
+	df, err := MakeDataFlow(ds)
+	if assert.NoError(t, err) {
+		result, err = df.Collect()
+		if assert.NoError(t, err) && assert.Len(t, result.Rows, 3333) {
+			for i, row := range result.Rows {
+				if !assert.EqualValues(t, i+i/2+1, cast.ToInt64(row[0])) { // qty 0 is dropped
+					break
+				}
+			}
+		}
+	}
+}
@@ -18,11 +18,10 @@ import (
 
 var (
 	// RemoveTrailingDecZeros removes the trailing zeros in CastToString
-	RemoveTrailingDecZeros    = false
-	SampleSize                = 900
-	replacePattern            = regexp.MustCompile("[^_0-9a-zA-Z]+") // to clean header fields
-	regexFirstDigit           = *regexp.MustCompile(`^\d`)
-	parseConstraintExpression = func(string) (ConstraintEvalFunc, error) { return nil, nil }
+	RemoveTrailingDecZeros = false
+	SampleSize             = 900
+	replacePattern         = regexp.MustCompile("[^_0-9a-zA-Z]+") // to clean header fields
+	regexFirstDigit        = *regexp.MustCompile(`^\d`)
 )
 
 // Column represents a schemata column
@@ -43,8 +42,9 @@ type Column struct {
 	Description string `json:"description,omitempty"`
 	FileURI     string `json:"file_uri,omitempty"`
 
-	Constraint *ColumnConstraint `json:"constraint,omitempty"`
-	Metadata   map[string]string `json:"metadata,omitempty"`
+	Constraint  *ColumnConstraint `json:"constraint,omitempty"`
+	OnCastError CastErrorPolicy   `json:"on_cast_error,omitempty"` // policy when a value cannot be cast into a forced type
+	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
 // Columns represent many columns
@@ -75,6 +75,7 @@ type ConstraintEvalFunc func(value any) bool
 
 type ColumnConstraint struct {
 	Expression string             `json:"expression,omitempty"`
+	OnFail     ConstraintOnFail   `json:"on_fail,omitempty"`
 	Errors     []string           `json:"errors,omitempty"`
 	FailCnt    uint64             `json:"fail_cnt,omitempty"`
 	EvalFunc   ConstraintEvalFunc `json:"-"`
@@ -120,6 +121,7 @@ type ColumnStats struct {
 	DateTimeZCnt int64  `json:"datetimez_cnt,omitempty"`
 	TotalCnt     int64  `json:"total_cnt"`
 	UniqCnt      int64  `json:"uniq_cnt"`
+	CastErrCnt   int64  `json:"cast_err_cnt,omitempty"`
 	Checksum     uint64 `json:"checksum"`
 }
 
@@ -328,6 +330,7 @@ func (cols Columns) Clone() (newCols Columns) {
 			Database:    col.Database,
 			Metadata:    col.Metadata,
 			Constraint:  col.Constraint,
+			OnCastError: col.OnCastError,
 		}
 	}
 	return newCols
@@ -548,6 +551,7 @@ func (cols Columns) Coerce(castCols Columns, hasHeader bool) (newCols Columns) {
 			newCols[i].Stats.MaxLen = lo.Ternary(col.Stats.MaxLen > 0, col.Stats.MaxLen, newCols[i].Stats.MaxLen)
 			newCols[i].DbPrecision = lo.Ternary(col.DbPrecision > 0, col.DbPrecision, newCols[i].DbPrecision)
 			newCols[i].DbScale = lo.Ternary(col.DbScale > 0, col.DbScale, newCols[i].DbScale)
+			newCols[i].OnCastError = col.OnCastError
 			newCols[i].Sourced = true
 			if !newCols[i].Type.IsValid() {
 				g.Warn("Provided unknown column type (%s) for column '%s'. Using string.", newCols[i].Type, newCols[i].Name)
@@ -564,6 +568,7 @@ func (cols Columns) Coerce(castCols Columns, hasHeader bool) (newCols Columns) {
 				newCols[i].Stats.MaxLen = lo.Ternary(col.Stats.MaxLen > 0, col.Stats.MaxLen, newCols[i].Stats.MaxLen)
 				newCols[i].DbPrecision = lo.Ternary(col.DbPrecision > 0, col.DbPrecision, newCols[i].DbPrecision)
 				newCols[i].DbScale = lo.Ternary(col.DbScale > 0, col.DbScale, newCols[i].DbScale)
+				newCols[i].OnCastError = col.OnCastError
 				newCols[i].Sourced = true
 			} else {
 				g.Warn("Provided unknown column type (%s) for column '%s'. Using string.", col.Type, col.Name)
@@ -576,6 +581,7 @@ func (cols Columns) Coerce(castCols Columns, hasHeader bool) (newCols Columns) {
 			if col.Type.IsValid() {
 				g.Debug("casting column '%s' as '%s'", newCols[i].Name, col.Type)
 				newCols[i].Type = col.Type
+				newCols[i].OnCastError = col.OnCastError
 				newCols[i].Sourced = true
 			} else {
 				g.Warn("Provided unknown column type (%s) for column '%s'. Using string.", col.Type, newCols[i].Name)
@@ -803,8 +809,19 @@ func MakeRowsChan() chan []any {
 
 const regexExtractPrecisionScale = `[a-zA-Z]+ *\( *(\d+) *(, *\d+)* *\)`
 
+// SetConstraint parses the constraint from the column type (`type | expression`),
+// or from the provided constraint object. A policy can be appended to the
+// expression, such as `integer | value > 0 | on_fail: skip`.
 func (col *Column) SetConstraint() {
-	parts := strings.Split(string(col.Type), "|")
+	if col.Constraint != nil {
+		col.Constraint.parse()
+		if col.Constraint.EvalFunc == nil {
+			col.Constraint = nil
+		}
+		return
+	}
+
+	parts := strings.SplitN(string(col.Type), "|", 2)
 	if len(parts) != 2 {
 		return
 	}
@@ -815,6 +832,10 @@ func (col *Column) SetConstraint() {
 	cc := &ColumnConstraint{
 		Expression: strings.TrimSpace(parts[1]),
 	}
+	if m := regexConstraintOnFail.FindStringSubmatch(cc.Expression); len(m) == 2 {
+		cc.OnFail = ConstraintOnFail(strings.ToLower(m[1]))
+		cc.Expression = strings.TrimSpace(strings.TrimSuffix(cc.Expression, m[0]))
+	}
 	cc.parse()
 	if cc.EvalFunc != nil {
 		col.Constraint = cc
@@ -865,13 +886,28 @@ func (col *Column) SetLengthPrecisionScale() {
 func (col *Column) EvaluateConstraint(value any, sp *StreamProcessor) {
 	if c := col.Constraint; c.EvalFunc != nil && !c.EvalFunc(value) {
 		c.FailCnt++
+
+		onFail := c.OnFail
+		if onFail == "" {
+			onFail = ConstraintOnFail(strings.ToLower(os.Getenv("SLING_ON_CONSTRAINT_FAILURE")))
+		}
+
+		errMsg := g.F("constraint failure for column '%s', at row number %d, for value: %s", col.Name, sp.N, cast.ToString(value))
 		if c.FailCnt <= 10 {
-			errMsg := g.F("constraint failure for column '%s', at row number %d, for value: %s", col.Name, sp.N, cast.ToString(value))
 			g.Warn(errMsg)
 			c.Errors = append(c.Errors, errMsg)
-			if os.Getenv("SLING_ON_CONSTRAINT_FAILURE") == "abort" {
+		}
+
+		switch onFail {
+		case ConstraintOnFailAbort:
+			if sp.ds != nil {
 				sp.ds.Context.CaptureErr(g.Error(errMsg))
 			}
+		case ConstraintOnFailSkip:
+			// send to rejects if enabled, else drop
+			if !sp.rejectCurrent(col, g.F("constraint failure (%s)", c.Expression), value) {
+				sp.skipCurrent = true
+			}
 		}
 	}
 }
@@ -1079,6 +1115,11 @@ func isUTC(t *time.Time) bool {
 
 // parse parses the constraint expression and sets the function
 func (cc *ColumnConstraint) parse() {
+	if !g.In(cc.OnFail, "", ConstraintOnFailWarn, ConstraintOnFailSkip, ConstraintOnFailAbort) {
+		g.Warn("invalid constraint on_fail value '%s', using '%s'", cc.OnFail, ConstraintOnFailWarn)
+		cc.OnFail = ConstraintOnFailWarn
+	}
+
 	var err error
 	cc.EvalFunc, err = parseConstraintExpression(cc.Expression)
 	if err != nil {
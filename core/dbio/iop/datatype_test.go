@@ -1,6 +1,7 @@
 package iop
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -372,3 +373,438 @@ func TestParseString(t *testing.T) {
 	g.P(val)
 	g.P(cast.ToTime(val).Location().String() == "UTC")
 }
+
+func TestParseConstraintExpression(t *testing.T) {
+	type testCase struct {
+		expr   string
+		value  any
+		expect bool
+	}
+	cases := []testCase{
+		{"value_len = 3", "abc", true},
+		{"value_len = 3", "hi", false},
+		{"value > 0", 5, true},
+		{"value > 0", -1, false},
+		{"> 0", nil, true},
+		{"value >= 1.5 and value < 10", 1.5, true},
+		{"value between 1 and 10", 11, false},
+		{"value not between 1 and 10", 11, true},
+		{"value in ('a', 'b')", "b", true},
+		{"value not in ('a', 'b')", "b", false},
+		{"value in (1, 2, 3)", int64(2), true},
+		{"not null", nil, false},
+		{"value is not null", "x", true},
+		{"value is null or value = ''", nil, true},
+		{"value ~ '^[a-z]+@[a-z]+\\.com$'", "me@mail.com", true},
+		{"value !~ '^\\d+$'", "123", false},
+		{"value > '2024-01-01'", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true},
+		{"not (value = 'x' or value = 'y')", "y", false},
+		{"upper(value) in (A, B)", "b", true},
+		{"length(trim(value)) > 2", " ab ", false},
+	}
+
+	for _, c := range cases {
+		evalFunc, err := parseConstraintExpression(c.expr)
+		if !assert.NoError(t, err, c.expr) || !assert.NotNil(t, evalFunc, c.expr) {
+			continue
+		}
+		assert.Equal(t, c.expect, evalFunc(c.value), "%s -> %#v", c.expr, c.value)
+	}
+
+	for _, expr := range []string{"value >", "value in (1, 2", "value ~ 5", "value = 'a"} {
+		_, err := parseConstraintExpression(expr)
+		assert.Error(t, err, expr)
+	}
+
+	col := Column{Name: "id", Type: "integer | value > 0 | on_fail: skip"}
+	col.SetConstraint()
+	assert.Equal(t, IntegerType, col.Type)
+	if assert.NotNil(t, col.Constraint) {
+		assert.Equal(t, "value > 0", col.Constraint.Expression)
+		assert.Equal(t, ConstraintOnFailSkip, col.Constraint.OnFail)
+	}
+}
+
+func TestRejectRows(t *testing.T) {
+	var rejected Dataset
+	rw := NewRejectWriter(2, func(ds *Datastream) (err error) {
+		rejected, err = ds.Collect(0)
+		return err
+	})
+	assert.Equal(t, rw, GetRejectWriter(rw.ID))
+
+	sp := NewStreamProcessor()
+	sp.SetConfig(map[string]string{"reject_writer": rw.ID})
+	columns := Columns{
+		{Name: "id", Type: IntegerType, Sourced: true},
+		{Name: "name", Type: StringType},
+	}
+
+	row := sp.CastRow([]any{"1", "a"}, columns)
+	assert.False(t, sp.skipCurrent)
+	assert.EqualValues(t, 1, row[0])
+
+	sp.CastRow([]any{"abc", "b"}, columns)
+	assert.True(t, sp.skipCurrent)
+	assert.EqualValues(t, 1, rw.Count())
+
+	assert.NoError(t, rw.Write(RejectedRow{Column: "id", Reason: "test"}))
+	assert.Error(t, rw.Write(RejectedRow{Column: "id", Reason: "test"})) // exceeds max_rejects
+
+	assert.NoError(t, rw.Close())
+	assert.Nil(t, GetRejectWriter(rw.ID))
+	if assert.Len(t, rejected.Rows, 3) {
+		rr := rejected.Rows[0]
+		assert.Equal(t, "id", rr[2])
+		assert.Equal(t, "abc", rr[4])
+		assert.EqualValues(t, 2, rr[1])
+		assert.Contains(t, rr[5], `"b"`)
+	}
+
+	// nothing is written without rejects
+	written := false
+	rw = NewRejectWriter(0, func(ds *Datastream) error {
+		written = true
+		return nil
+	})
+	assert.NoError(t, rw.Close())
+	assert.False(t, written)
+}
+
+func TestCastErrorPolicy(t *testing.T) {
+	col := Column{Name: "amount", Type: "decimal(18,2) | on_cast_error: null"}
+	col.SetCastErrorPolicy()
+	col.SetLengthPrecisionScale()
+	assert.Equal(t, DecimalType, col.Type)
+	assert.Equal(t, CastErrorNull, col.OnCastError)
+
+	col = Column{Name: "id", Type: "integer | value > 0 | on_cast_error: default:-1"}
+	col.SetCastErrorPolicy()
+	col.SetConstraint()
+	assert.Equal(t, IntegerType, col.Type)
+	assert.NotNil(t, col.Constraint)
+	value, ok := col.OnCastError.Default()
+	assert.True(t, ok)
+	assert.Equal(t, "-1", value)
+
+	assert.NoError(t, CastErrorPolicy("default:-1").Validate(IntegerType))
+	assert.Error(t, CastErrorPolicy("default:abc").Validate(IntegerType))
+	assert.Error(t, CastErrorPolicy("ignore").Validate(IntegerType))
+
+	sp := NewStreamProcessor()
+	columns := Columns{
+		{Name: "amount", Type: DecimalType, Sourced: true, OnCastError: CastErrorNull},
+		{Name: "id", Type: IntegerType, Sourced: true, OnCastError: "default:-1"},
+	}
+
+	row := sp.CastRow([]any{"1.5", "2"}, columns)
+	assert.Equal(t, "1.5", row[0])
+	assert.EqualValues(t, 2, row[1])
+
+	row = sp.CastRow([]any{"n/a", "x"}, columns)
+	assert.Nil(t, row[0])
+	assert.EqualValues(t, -1, row[1])
+	assert.EqualValues(t, 1, sp.colStats[0].CastErrCnt)
+	assert.EqualValues(t, 1, sp.colStats[1].CastErrCnt)
+	assert.Equal(t, DecimalType, columns[0].Type)
+}
+
+func TestExpression(t *testing.T) {
+	columns := Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "first_name", Type: StringType},
+		{Name: "last_name", Type: StringType},
+		{Name: "amount", Type: DecimalType},
+		{Name: "created_at", Type: DatetimeType},
+	}
+	created := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	row := []any{int64(7), "Jane", "Doe", 12.5, created}
+	nullRow := []any{int64(8), nil, "Roe", nil, nil}
+
+	type testCase struct {
+		expr     string
+		expected any
+		colType  ColumnType
+	}
+	cases := []testCase{
+		{"id * 2 + 1", int64(15), BigIntType},
+		{"id / 2", 3.5, DecimalType},
+		{"-id % 4", int64(-3), BigIntType},
+		{"amount * 2", 25.0, DecimalType},
+		{"first_name || ' ' || upper(last_name)", "Jane DOE", StringType},
+		{"concat(first_name, '-', id)", "Jane-7", StringType},
+		{"length(first_name)", int64(4), BigIntType},
+		{"substr(first_name, 2, 2)", "an", StringType},
+		{"split_part('a,b,c', ',', 2)", "b", StringType},
+		{"amount > 10 and id in (1, 7)", true, BoolType},
+		{"id between 1 and 5", false, BoolType},
+		{"first_name is not null", true, BoolType},
+		{"last_name ~ '^D' and first_name !~ '^J'", false, BoolType},
+		{"case when amount > 100 then 'high' when amount > 10 then 'mid' else 'low' end", "mid", StringType},
+		{"case id when 7 then 'seven' end", "seven", StringType},
+		{"if(amount > 100, 'high', 'low')", "low", StringType},
+		{"round(amount / 3, 2)", 4.17, DecimalType},
+		{"year(created_at) * 100 + month(created_at)", int64(202403), BigIntType},
+		{"date_diff('2024-03-20', created_at, 'day')", int64(4), BigIntType},
+		{"date_add(created_at, 1, 'month')", created.AddDate(0, 1, 0), DatetimeType},
+		{`"id" = '7'`, true, BoolType},
+		{"1 / 0", nil, DecimalType},
+		{"'it''s'", "it's", StringType},
+	}
+
+	for _, c := range cases {
+		e, err := ParseExpression(c.expr)
+		if !assert.NoError(t, err, c.expr) {
+			continue
+		}
+		assert.NoError(t, e.Bind(columns), c.expr)
+		val, err := e.Eval(row)
+		assert.NoError(t, err, c.expr)
+		assert.Equal(t, c.expected, val, c.expr)
+		assert.Equal(t, c.colType, e.Type(), c.expr)
+	}
+
+	// nulls propagate, except for coalesce / concat
+	nullCases := map[string]any{
+		"amount * 2":                       nil,
+		"upper(first_name)":                nil,
+		"coalesce(first_name, last_name)":  "Roe",
+		"concat(first_name, last_name)":    "Roe",
+		"first_name is null":               true,
+		"amount > 1 or id = 8":             true,
+		"amount > 1 and id = 8":            nil,
+		"if(amount > 1, 'yes', 'no')":      "no",
+		"nullif(last_name, 'Roe') is null": true,
+	}
+	for expr, expected := range nullCases {
+		e, err := ParseExpression(expr)
+		if assert.NoError(t, err, expr) && assert.NoError(t, e.Bind(columns), expr) {
+			val, err := e.Eval(nullRow)
+			assert.NoError(t, err, expr)
+			assert.Equal(t, expected, val, expr)
+		}
+	}
+
+	// invalid expressions
+	for _, expr := range []string{"", "id +", "upper(id", "unknown_func(id)", "'abc", "id ? 2", "upper()"} {
+		_, err := ParseExpression(expr)
+		assert.Error(t, err, expr)
+	}
+
+	e, err := ParseExpression("missing + 1")
+	assert.NoError(t, err)
+	assert.Error(t, e.Bind(columns))
+}
+
+func TestComputedColumns(t *testing.T) {
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "amount", Type: DecimalType},
+		{Name: "qty", Type: BigIntType},
+	})
+	data.Inferred = true
+	data.Append([]any{int64(1), 2.5, int64(4)}, []any{int64(2), 10.0, nil})
+
+	computed := []ComputedColumn{
+		{Name: "total_tax", Expression: "total * 0.2"},
+		{Name: "total", Expression: "amount * coalesce(qty, 1)"},
+		{Name: "id", Expression: "'ID-' || id", Type: StringType},
+	}
+	ds := data.Stream(map[string]string{"computed_columns": g.Marshal(computed)})
+	result, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "amount", "qty", "total_tax", "total"}, result.Columns.Names())
+	assert.Equal(t, DecimalType, result.Columns[3].Type)
+	if assert.Len(t, result.Rows, 2) {
+		assert.Equal(t, "ID-1", result.Rows[0][0])
+		assert.EqualValues(t, 10, cast.ToFloat64(result.Rows[0][4]))
+		assert.EqualValues(t, 2, cast.ToFloat64(result.Rows[0][3]))
+		assert.EqualValues(t, 10, cast.ToFloat64(result.Rows[1][4]))
+	}
+
+	_, err = PrepareComputedColumns([]ComputedColumn{
+		{Name: "a", Expression: "b + 1"},
+		{Name: "b", Expression: "a + 1"},
+	})
+	assert.ErrorContains(t, err, "circular")
+}
+
+func TestDistinctCounter(t *testing.T) {
+	dc := NewDistinctCounter([]string{"ID"})
+	defer dc.Close()
+
+	df := NewDataflow(0)
+	for _, rows := range [][][]any{{{"1", "a"}, {"2", "b"}}, {{"2", "c"}, {"3", ""}}} {
+		ds := NewDatastreamIt(context.Background(), Columns{
+			{Name: "id", Type: BigIntType},
+			{Name: "name", Type: StringType},
+		}, func(it *Iterator) bool {
+			if len(rows) == 0 {
+				return false
+			}
+			it.Row, rows = rows[0], rows[1:]
+			return true
+		})
+		ds.Inferred = true
+		ds.SetConfig(map[string]string{"distinct_counter": dc.ID})
+		if assert.NoError(t, ds.Start()) {
+			_, err := ds.Collect(0)
+			assert.NoError(t, err)
+		}
+		df.Streams = append(df.Streams, ds)
+	}
+	df.Columns = df.Streams[0].Columns
+
+	// counted across the streams
+	df.SyncStats()
+	assert.EqualValues(t, 4, df.Columns[0].Stats.TotalCnt)
+	assert.EqualValues(t, 3, df.Columns[0].Stats.UniqCnt)
+	assert.EqualValues(t, 0, df.Columns[1].Stats.UniqCnt) // not counted
+}
+
+func TestWhereFilter(t *testing.T) {
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "status", Type: StringType},
+	})
+	data.Inferred = true
+	data.Append([]any{int64(1), "active"}, []any{int64(2), "deleted"}, []any{int64(3), nil}, []any{int64(4), "active"})
+
+	ds := data.Stream(map[string]string{"where": "status = 'active' and id > 1"})
+	result, err := ds.Collect(0)
+	if assert.NoError(t, err) && assert.Len(t, result.Rows, 1) {
+		assert.EqualValues(t, 4, result.Rows[0][0])
+	}
+	assert.EqualValues(t, 1, ds.Count)
+
+	// rows filtered out are not in the stats, and computed columns can be referenced
+	newStream := func(configMap map[string]string) *Datastream {
+		rows := [][]any{{"1", "active"}, {"2", "deleted"}, {"3", ""}, {"4", "active"}}
+		ds := NewDatastreamIt(context.Background(), Columns{
+			{Name: "id", Type: BigIntType},
+			{Name: "status", Type: StringType},
+		}, func(it *Iterator) bool {
+			if len(rows) == 0 {
+				return false
+			}
+			it.Row, rows = rows[0], rows[1:]
+			return true
+		})
+		ds.Inferred = true
+		ds.SetConfig(configMap)
+		return ds
+	}
+
+	ds = newStream(map[string]string{
+		"where":            "id_x2 > 2 and status = 'active'",
+		"computed_columns": `[{"name": "id_x2", "expression": "id * 2"}]`,
+	})
+	if assert.NoError(t, ds.Start()) {
+		result, err = ds.Collect(0)
+		if assert.NoError(t, err) && assert.Len(t, result.Rows, 1) {
+			assert.EqualValues(t, 8, result.Rows[0][2])
+		}
+		assert.EqualValues(t, 1, ds.Sp.ColStats()[0].TotalCnt)
+	}
+
+	// evaluation errors fail the stream
+	ds = newStream(map[string]string{"where": "status * 2 > 1"})
+	if assert.NoError(t, ds.Start()) {
+		_, err = ds.Collect(0)
+		assert.ErrorContains(t, err, "could not evaluate where filter")
+	}
+
+	expr, err := ParseExpression("id >= 2 and 10 > id and status in ('a', 'b') and status is not null")
+	if assert.NoError(t, err) {
+		conds, ok := expr.Conditions()
+		assert.True(t, ok)
+		if assert.Len(t, conds, 4) {
+			assert.Equal(t, WhereCondition{Column: "id", Operator: "<", Values: []any{int64(10)}}, conds[1])
+			assert.Equal(t, "in", conds[2].Operator)
+		}
+	}
+
+	expr, _ = ParseExpression("id = 1 or status = 'a'")
+	_, ok := expr.Conditions()
+	assert.False(t, ok)
+}
+
+func TestLookup(t *testing.T) {
+	ref := NewDataset(Columns{
+		{Name: "code", Type: StringType},
+		{Name: "rate", Type: DecimalType},
+	})
+	ref.Append([]any{"EUR", 1.1}, []any{"GBP", 1.3})
+
+	lookup, err := NewLookup("rates", ref, []string{"currency"}, []string{"code"}, []string{"rate"}, []string{"fx_rate"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	lookup.Defaults = []any{1}
+
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "currency", Type: StringType},
+	})
+	data.Inferred = true
+	data.Append([]any{int64(1), "EUR"}, []any{int64(2), "USD"})
+
+	lookupsID := RegisterLookups([]Lookup{lookup})
+	defer UnregisterLookups(lookupsID)
+
+	ds := data.Stream(map[string]string{"lookups_id": lookupsID})
+	result, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "currency", "fx_rate"}, result.Columns.Names())
+	assert.Equal(t, DecimalType, result.Columns[2].Type)
+	if assert.Len(t, result.Rows, 2) {
+		assert.EqualValues(t, 1.1, cast.ToFloat64(result.Rows[0][2]))
+		assert.EqualValues(t, 1, cast.ToFloat64(result.Rows[1][2]))
+	}
+
+	// unmatched keys are rejected
+	lookup.OnUnmatched = "reject"
+	data.Rows = [][]any{{int64(1), "EUR"}, {int64(2), "USD"}}
+	rw := NewRejectWriter(0, func(ds *Datastream) (err error) {
+		_, err = ds.Collect(0)
+		return err
+	})
+	rejectLookupsID := RegisterLookups([]Lookup{lookup})
+	defer UnregisterLookups(rejectLookupsID)
+
+	ds = data.Stream(map[string]string{"lookups_id": rejectLookupsID, "reject_writer": rw.ID})
+	result, err = ds.Collect(0)
+	if assert.NoError(t, err) {
+		assert.Len(t, result.Rows, 1)
+		assert.EqualValues(t, 1, rw.Count())
+	}
+	assert.NoError(t, rw.Close())
+
+	// values keep their types, e.g. large integer keys and times
+	updated := time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)
+	ref = NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "updated_at", Type: DatetimeType},
+	})
+	ref.Append([]any{int64(1234567890123456789), updated})
+	lookup, err = NewLookup("updates", ref, []string{"id"}, []string{"id"}, []string{"updated_at"}, []string{"updated_at"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	typedLookupsID := RegisterLookups([]Lookup{lookup})
+	defer UnregisterLookups(typedLookupsID)
+
+	data = NewDataset(Columns{{Name: "id", Type: BigIntType}})
+	data.Inferred = true
+	data.Append([]any{int64(1234567890123456789)})
+	result, err = data.Stream(map[string]string{"lookups_id": typedLookupsID}).Collect(0)
+	if assert.NoError(t, err) && assert.Len(t, result.Rows, 1) {
+		assert.Equal(t, updated, result.Rows[0][1])
+	}
+}
@@ -0,0 +1,70 @@
+package iop
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// distinctCounters are the registered distinct counters, by ID. Stream
+// processors obtain theirs with the `distinct_counter` config key
+var distinctCounters = sync.Map{}
+
+// DistinctCounter counts the distinct values of columns, across the streams
+// of a dataflow. The counts are set as the UniqCnt of the column stats.
+// Values are kept as 64-bit hashes, to limit memory usage.
+type DistinctCounter struct {
+	ID string
+
+	hashes map[string]map[uint64]struct{} // lower column name => value hashes
+	mux    sync.Mutex
+}
+
+// NewDistinctCounter creates and registers a distinct counter for the columns
+func NewDistinctCounter(columns []string) *DistinctCounter {
+	dc := &DistinctCounter{
+		ID:     g.NewTsID("distinct"),
+		hashes: map[string]map[uint64]struct{}{},
+	}
+	for _, column := range columns {
+		dc.hashes[strings.ToLower(column)] = map[uint64]struct{}{}
+	}
+	distinctCounters.Store(dc.ID, dc)
+	return dc
+}
+
+// GetDistinctCounter returns the registered distinct counter with the provided ID
+func GetDistinctCounter(id string) *DistinctCounter {
+	if dc, ok := distinctCounters.Load(id); ok {
+		return dc.(*DistinctCounter)
+	}
+	return nil
+}
+
+// Counts returns whether the distinct values of the column are counted
+func (dc *DistinctCounter) Counts(column string) bool {
+	_, ok := dc.hashes[strings.ToLower(column)]
+	return ok
+}
+
+// Add adds a non-null value of the column, and returns the number of distinct
+// values of the column so far
+func (dc *DistinctCounter) Add(column string, val any) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(cast.ToString(val)))
+
+	dc.mux.Lock()
+	defer dc.mux.Unlock()
+
+	hashes := dc.hashes[strings.ToLower(column)]
+	hashes[h.Sum64()] = struct{}{}
+	return int64(len(hashes))
+}
+
+// Close unregisters the distinct counter
+func (dc *DistinctCounter) Close() {
+	distinctCounters.Delete(dc.ID)
+}
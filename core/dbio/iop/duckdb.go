@@ -860,6 +860,12 @@ func (duck *DuckDb) MakeScanQuery(format dbio.FileType, uri string, fsc FileStre
 		where = g.F("where %s", incrementalWhereCond)
 	}
 
+	// push down the row filter if possible
+	whereCond := makeWhereConditionSQL(fsc.Where, dbio.TypeDbDuckDb.Quote)
+	if whereCond != "" && fsc.SQL == "" {
+		where = lo.Ternary(where == "", g.F("where %s", whereCond), g.F("%s and %s", where, whereCond))
+	}
+
 	if format == dbio.FileTypeNone {
 		g.Warn("duck.MakeScanQuery: format is empty, cannot determine stream_scanner")
 	}
@@ -873,6 +879,10 @@ func (duck *DuckDb) MakeScanQuery(format dbio.FileType, uri string, fsc FileStre
 			"uri", uri,
 		)
 
+		if whereCond != "" {
+			sql = g.F("select * from ( %s ) as t where %s", sql, whereCond)
+		}
+
 		if fsc.Limit > 0 {
 			sql = g.F("select * from ( %s ) as t limit %d", sql, fsc.Limit)
 		}
@@ -904,3 +914,45 @@ func (duck *DuckDb) MakeScanQuery(format dbio.FileType, uri string, fsc FileStre
 
 	return sql
 }
+
+// makeWhereConditionSQL converts a row filter expression into a SQL condition.
+// Returns empty if the expression cannot be pushed down as is.
+func makeWhereConditionSQL(where string, quote func(field string, normalize ...bool) string) string {
+	if strings.TrimSpace(where) == "" {
+		return ""
+	}
+
+	expr, err := ParseExpression(where)
+	if err != nil {
+		return ""
+	}
+
+	conds, ok := expr.Conditions()
+	if !ok {
+		return ""
+	}
+
+	literal := func(v any) string {
+		switch val := v.(type) {
+		case string:
+			return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+		}
+		return cast.ToString(v)
+	}
+
+	parts := make([]string, len(conds))
+	for i, cond := range conds {
+		col := quote(cond.Column)
+		switch cond.Operator {
+		case "is null", "is not null":
+			parts[i] = g.F("%s %s", col, cond.Operator)
+		case "in", "not in":
+			values := lo.Map(cond.Values, func(v any, i int) string { return literal(v) })
+			parts[i] = g.F("%s %s (%s)", col, cond.Operator, strings.Join(values, ", "))
+		default:
+			parts[i] = g.F("%s %s %s", col, cond.Operator, literal(cond.Values[0]))
+		}
+	}
+
+	return strings.Join(parts, " and ")
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -127,4 +129,42 @@ func TestDuckDb(t *testing.T) {
 		assert.Contains(t, data.Columns.Names(), "name")
 		assert.Contains(t, data.Columns.Names(), "file")
 	})
+
+	t.Run("MakeScanQuery with where", func(t *testing.T) {
+		duck := NewDuckDb(context.Background())
+		uri := "/tmp/test.parquet"
+
+		sql := duck.MakeScanQuery(dbio.FileTypeParquet, uri, FileStreamConfig{Where: "amount > 10 and status in ('a', 'b''s')"})
+		assert.Equal(t, `select * from parquet_scan('/tmp/test.parquet') where "amount" > 10 and "status" in ('a', 'b''s')`, sql)
+
+		sql = duck.MakeScanQuery(dbio.FileTypeParquet, uri, FileStreamConfig{Where: "5 <= id", IncrementalKey: "id", IncrementalValue: "1"})
+		assert.Equal(t, `select * from parquet_scan('/tmp/test.parquet') where "id" > 1 and "id" >= 5`, sql)
+
+		// not pushed down, filtered on the stream
+		sql = duck.MakeScanQuery(dbio.FileTypeParquet, uri, FileStreamConfig{Where: "upper(status) = 'A' or id > 1"})
+		assert.Equal(t, `select * from parquet_scan('/tmp/test.parquet')`, sql)
+	})
+
+	t.Run("TransformSQL", func(t *testing.T) {
+		data := NewDataset(Columns{
+			{Name: "region", Type: StringType},
+			{Name: "amount", Type: BigIntType},
+		})
+		data.Inferred = true
+		data.Append([]any{"east", int64(10)}, []any{"west", int64(5)}, []any{"east", int64(7)}, []any{"west", nil})
+
+		ds, err := TransformSQL(data.Stream(), "select region, sum(amount) as total, count(*) as cnt from stream group by region order by region")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		result, err := ds.Collect(0)
+		if assert.NoError(t, err) && assert.Len(t, result.Rows, 2) {
+			assert.Equal(t, []string{"region", "total", "cnt"}, result.Columns.Names())
+			records := result.Records()
+			assert.EqualValues(t, "east", records[0]["region"])
+			assert.EqualValues(t, 17, cast.ToInt(records[0]["total"]))
+			assert.EqualValues(t, 2, cast.ToInt(records[1]["cnt"]))
+		}
+	})
 }
@@ -0,0 +1,1540 @@
+package iop
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// ComputedColumn is a column derived from an expression on the other columns of the row
+type ComputedColumn struct {
+	Name       string     `json:"name"`
+	Expression string     `json:"expression"`
+	Type       ColumnType `json:"type,omitempty"` // inferred from the expression if omitted
+
+	expr   *Expression
+	index  int
+	warned bool
+}
+
+// PrepareComputedColumns parses the expressions of the computed columns, and
+// orders them so that columns referencing other computed columns come after
+func PrepareComputedColumns(computed []ComputedColumn) (ordered []ComputedColumn, err error) {
+	byName := map[string]int{}
+	for i, cc := range computed {
+		if cc.Name == "" {
+			return nil, g.Error("computed column #%d has no name", i+1)
+		} else if _, ok := byName[strings.ToLower(cc.Name)]; ok {
+			return nil, g.Error("duplicate computed column: %s", cc.Name)
+		}
+		byName[strings.ToLower(cc.Name)] = i
+
+		computed[i].expr, err = ParseExpression(cc.Expression)
+		if err != nil {
+			return nil, g.Error(err, "invalid expression for computed column %s", cc.Name)
+		}
+	}
+
+	// depth-first ordering on the references, with cycle detection
+	state := map[int]int{} // 1: visiting, 2: done
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case 1:
+			return g.Error("circular reference in computed column %s", computed[i].Name)
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		for _, ref := range computed[i].expr.References() {
+			if j, ok := byName[ref]; ok && j != i {
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = 2
+		ordered = append(ordered, computed[i])
+		return nil
+	}
+
+	for i := range computed {
+		if err = visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// Expression is a parsed expression, evaluated against a row.
+// Columns are referenced by name (double-quoted if needed), strings are single-quoted.
+// Supported are arithmetic (+, -, *, /, %), concatenation (||), comparisons
+// (=, !=, <>, <, <=, >, >=), regex matching (~, !~), `is [not] null`, `[not] in (...)`,
+// `[not] between`, `and`, `or`, `not`, `case when ... then ... else ... end` and the
+// functions in exprFunctions. As with SQL, operations on null values return null.
+// Column constraints are parsed with the same parser, see parseConstraintExpression.
+type Expression struct {
+	Text string
+	root exprNode
+	refs []string // referenced column names, lower case
+
+	parseTime func(any) (time.Time, error)
+}
+
+// ParseExpression parses an expression text
+func ParseExpression(text string) (e *Expression, err error) {
+	tokens, err := tokenizeExpression(text)
+	if err != nil {
+		return nil, g.Error(err, "could not parse expression: %s", text)
+	} else if len(tokens) == 0 {
+		return nil, g.Error("empty expression")
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, g.Error(err, "could not parse expression: %s", text)
+	} else if !p.done() {
+		return nil, g.Error("could not parse expression: %s. Unexpected token: %s", text, p.peek().text)
+	}
+
+	e = &Expression{Text: text, root: root, refs: p.refs, parseTime: cast.ToTimeE}
+	return e, nil
+}
+
+// References returns the names of the referenced columns (lower case)
+func (e *Expression) References() []string {
+	return e.refs
+}
+
+// Bind resolves the referenced columns to their position in the row.
+// Must be called before Eval.
+func (e *Expression) Bind(columns Columns) (err error) {
+	fieldMap := columns.FieldMap(true)
+	return walkExprNodes(e.root, func(n exprNode) error {
+		if col, ok := n.(*exprColumn); ok {
+			i, found := fieldMap[strings.ToLower(col.name)]
+			if !found {
+				return g.Error("column '%s' not found", col.name)
+			}
+			col.index = i
+			col.colType = columns[i].Type
+		}
+		return nil
+	})
+}
+
+// Eval evaluates the expression for a row
+func (e *Expression) Eval(row []any) (any, error) {
+	return e.root.eval(&exprContext{row: row, parseTime: e.parseTime})
+}
+
+// Type returns the column type of the expression result. Must be called after Bind.
+func (e *Expression) Type() ColumnType {
+	switch e.root.kind() {
+	case exprKindInteger:
+		return BigIntType
+	case exprKindDecimal:
+		return DecimalType
+	case exprKindBool:
+		return BoolType
+	case exprKindDatetime:
+		return DatetimeType
+	case exprKindDate:
+		return DateType
+	}
+	return StringType
+}
+
+// WhereCondition is a simple predicate of a column against literal values,
+// used to push filters down to readers
+type WhereCondition struct {
+	Column   string
+	Operator string // =, !=, <, <=, >, >=, in, not in, is null, is not null
+	Values   []any
+}
+
+// Conditions returns the expression as a list of conditions which must all be true.
+// Returns false if the expression cannot be expressed as such.
+func (e *Expression) Conditions() (conds []WhereCondition, ok bool) {
+	flipped := map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+	var collect func(n exprNode) bool
+	collect = func(n exprNode) bool {
+		switch node := n.(type) {
+		case *exprBinary:
+			if node.op == "and" {
+				return collect(node.left) && collect(node.right)
+			} else if !g.In(node.op, "=", "==", "!=", "<>", "<", "<=", ">", ">=") {
+				return false
+			}
+
+			op := strings.NewReplacer("==", "=", "<>", "!=").Replace(node.op)
+			col, isCol := node.left.(*exprColumn)
+			lit, isLit := node.right.(*exprLiteral)
+			if !isCol {
+				// literal on the left side
+				col, isCol = node.right.(*exprColumn)
+				lit, isLit = node.left.(*exprLiteral)
+				if newOp, found := flipped[op]; found {
+					op = newOp
+				}
+			}
+			if !isCol || !isLit || lit.val == nil {
+				return false
+			}
+			conds = append(conds, WhereCondition{Column: col.name, Operator: op, Values: []any{lit.val}})
+			return true
+
+		case *exprIn:
+			col, isCol := node.x.(*exprColumn)
+			if !isCol {
+				return false
+			}
+			cond := WhereCondition{Column: col.name, Operator: lo.Ternary(node.negate, "not in", "in")}
+			for _, item := range node.list {
+				lit, isLit := item.(*exprLiteral)
+				if !isLit || lit.val == nil {
+					return false
+				}
+				cond.Values = append(cond.Values, lit.val)
+			}
+			conds = append(conds, cond)
+			return true
+
+		case *exprIsNull:
+			col, isCol := node.x.(*exprColumn)
+			if !isCol {
+				return false
+			}
+			conds = append(conds, WhereCondition{Column: col.name, Operator: lo.Ternary(node.negate, "is not null", "is null")})
+			return true
+		}
+		return false
+	}
+
+	if !collect(e.root) {
+		return nil, false
+	}
+	return conds, true
+}
+
+type exprKind int
+
+const (
+	exprKindAny exprKind = iota
+	exprKindString
+	exprKindInteger
+	exprKindDecimal
+	exprKindBool
+	exprKindDatetime
+	exprKindDate
+)
+
+func exprKindOf(colType ColumnType) exprKind {
+	switch {
+	case colType.IsInteger():
+		return exprKindInteger
+	case colType.IsNumber():
+		return exprKindDecimal
+	case colType.IsBool():
+		return exprKindBool
+	case colType == DateType:
+		return exprKindDate
+	case colType.IsDatetime():
+		return exprKindDatetime
+	case colType.IsString():
+		return exprKindString
+	}
+	return exprKindAny
+}
+
+type exprContext struct {
+	row       []any
+	parseTime func(any) (time.Time, error)
+}
+
+type exprNode interface {
+	eval(ctx *exprContext) (any, error)
+	kind() exprKind
+}
+
+// walkExprNodes calls the function for the node and its children
+func walkExprNodes(n exprNode, f func(exprNode) error) error {
+	if err := f(n); err != nil {
+		return err
+	}
+
+	children := []exprNode{}
+	switch node := n.(type) {
+	case *exprUnary:
+		children = append(children, node.x)
+	case *exprBinary:
+		children = append(children, node.left, node.right)
+	case *exprCall:
+		children = append(children, node.args...)
+	case *exprCase:
+		if node.operand != nil {
+			children = append(children, node.operand)
+		}
+		for _, w := range node.whens {
+			children = append(children, w[0], w[1])
+		}
+		if node.elseX != nil {
+			children = append(children, node.elseX)
+		}
+	case *exprIn:
+		children = append(append(children, node.x), node.list...)
+	case *exprIsNull:
+		children = append(children, node.x)
+	case *exprRegex:
+		children = append(children, node.x)
+	}
+
+	for _, child := range children {
+		if err := walkExprNodes(child, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type exprLiteral struct{ val any }
+
+func (n *exprLiteral) eval(ctx *exprContext) (any, error) { return n.val, nil }
+func (n *exprLiteral) kind() exprKind {
+	switch n.val.(type) {
+	case string:
+		return exprKindString
+	case int64:
+		return exprKindInteger
+	case float64:
+		return exprKindDecimal
+	case bool:
+		return exprKindBool
+	}
+	return exprKindAny
+}
+
+type exprColumn struct {
+	name    string
+	index   int
+	colType ColumnType
+}
+
+func (n *exprColumn) eval(ctx *exprContext) (any, error) {
+	if n.index >= len(ctx.row) {
+		return nil, nil
+	}
+
+	switch val := ctx.row[n.index].(type) {
+	case *string:
+		if val == nil {
+			return nil, nil
+		}
+		return *val, nil
+	case *time.Time:
+		if val == nil {
+			return nil, nil
+		}
+		return *val, nil
+	}
+	return ctx.row[n.index], nil
+}
+func (n *exprColumn) kind() exprKind { return exprKindOf(n.colType) }
+
+type exprUnary struct {
+	op string
+	x  exprNode
+}
+
+func (n *exprUnary) eval(ctx *exprContext) (any, error) {
+	val, err := n.x.eval(ctx)
+	if err != nil || val == nil {
+		return nil, err
+	}
+
+	if n.op == "not" {
+		return !exprTruthy(val), nil
+	}
+
+	i, f, isInt, err := exprToNumber(val)
+	if err != nil {
+		return nil, err
+	} else if isInt {
+		return -i, nil
+	}
+	return -f, nil
+}
+func (n *exprUnary) kind() exprKind {
+	if n.op == "not" {
+		return exprKindBool
+	} else if k := n.x.kind(); k == exprKindInteger {
+		return k
+	}
+	return exprKindDecimal
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *exprBinary) eval(ctx *exprContext) (any, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit logic, with SQL three-valued semantics
+	switch n.op {
+	case "and":
+		if left != nil && !exprTruthy(left) {
+			return false, nil
+		}
+	case "or":
+		if left != nil && exprTruthy(left) {
+			return true, nil
+		}
+	}
+
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "and":
+		if right != nil && !exprTruthy(right) {
+			return false, nil
+		} else if left == nil || right == nil {
+			return nil, nil
+		}
+		return true, nil
+	case "or":
+		if right != nil && exprTruthy(right) {
+			return true, nil
+		} else if left == nil || right == nil {
+			return nil, nil
+		}
+		return false, nil
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	switch n.op {
+	case "||":
+		return exprToString(left) + exprToString(right), nil
+	case "+", "-", "*", "/", "%":
+		return exprArithmetic(n.op, left, right)
+	case "=", "==", "!=", "<>", "<", "<=", ">", ">=":
+		return exprCompareOp(exprCompare(ctx, left, right), n.op), nil
+	}
+	return nil, g.Error("unsupported operator: %s", n.op)
+}
+func (n *exprBinary) kind() exprKind {
+	switch n.op {
+	case "||":
+		return exprKindString
+	case "+", "-", "*", "%":
+		if n.left.kind() == exprKindInteger && n.right.kind() == exprKindInteger {
+			return exprKindInteger
+		}
+		return exprKindDecimal
+	case "/":
+		return exprKindDecimal
+	}
+	return exprKindBool
+}
+
+type exprIn struct {
+	x      exprNode
+	list   []exprNode
+	negate bool
+}
+
+func (n *exprIn) eval(ctx *exprContext) (any, error) {
+	val, err := n.x.eval(ctx)
+	if err != nil || val == nil {
+		return nil, err
+	}
+
+	for _, item := range n.list {
+		itemVal, err := item.eval(ctx)
+		if err != nil {
+			return nil, err
+		} else if itemVal != nil && exprCompare(ctx, val, itemVal) == 0 {
+			return !n.negate, nil
+		}
+	}
+	return n.negate, nil
+}
+func (n *exprIn) kind() exprKind { return exprKindBool }
+
+type exprIsNull struct {
+	x      exprNode
+	negate bool
+}
+
+func (n *exprIsNull) eval(ctx *exprContext) (any, error) {
+	val, err := n.x.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return (val == nil) != n.negate, nil
+}
+func (n *exprIsNull) kind() exprKind { return exprKindBool }
+
+type exprRegex struct {
+	x      exprNode
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (n *exprRegex) eval(ctx *exprContext) (any, error) {
+	val, err := n.x.eval(ctx)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	return n.re.MatchString(exprToString(val)) != n.negate, nil
+}
+func (n *exprRegex) kind() exprKind { return exprKindBool }
+
+type exprCase struct {
+	operand exprNode      // for `case x when value then ...`
+	whens   [][2]exprNode // condition (or value), result
+	elseX   exprNode
+}
+
+func (n *exprCase) eval(ctx *exprContext) (any, error) {
+	var operand any
+	if n.operand != nil {
+		var err error
+		if operand, err = n.operand.eval(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, w := range n.whens {
+		cond, err := w[0].eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := false
+		if n.operand != nil {
+			matched = operand != nil && cond != nil && exprCompare(ctx, operand, cond) == 0
+		} else {
+			matched = cond != nil && exprTruthy(cond)
+		}
+
+		if matched {
+			return w[1].eval(ctx)
+		}
+	}
+
+	if n.elseX != nil {
+		return n.elseX.eval(ctx)
+	}
+	return nil, nil
+}
+func (n *exprCase) kind() exprKind {
+	results := []exprNode{n.elseX}
+	for _, w := range n.whens {
+		results = append(results, w[1])
+	}
+	return exprCommonKind(results...)
+}
+
+// exprCommonKind returns the first known kind of the nodes
+func exprCommonKind(nodes ...exprNode) exprKind {
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		} else if k := node.kind(); k != exprKindAny {
+			return k
+		}
+	}
+	return exprKindAny
+}
+
+type exprCall struct {
+	name string
+	fn   exprFunction
+	args []exprNode
+}
+
+func (n *exprCall) eval(ctx *exprContext) (any, error) {
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		val, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		} else if val == nil && !n.fn.nullable {
+			return nil, nil // strict functions return null on null input
+		}
+		args[i] = val
+	}
+
+	val, err := n.fn.call(ctx, args)
+	if err != nil {
+		return nil, g.Error(err, "error in function %s", n.name)
+	}
+	return val, nil
+}
+func (n *exprCall) kind() exprKind {
+	if n.fn.kindOf != nil {
+		return n.fn.kindOf(n.args)
+	}
+	return n.fn.kind
+}
+
+// exprFunction is a function available in expressions
+type exprFunction struct {
+	minArgs  int
+	maxArgs  int      // -1 for variadic
+	kind     exprKind // result kind
+	kindOf   func(args []exprNode) exprKind
+	nullable bool // whether the function handles null arguments, otherwise returns null
+	call     func(ctx *exprContext, args []any) (any, error)
+}
+
+var exprDateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+var exprFunctions = map[string]exprFunction{
+	// conditional
+	"if": {minArgs: 2, maxArgs: 3, nullable: true,
+		kindOf: func(args []exprNode) exprKind { return exprCommonKind(args[1:]...) },
+		call: func(ctx *exprContext, args []any) (any, error) {
+			if args[0] != nil && exprTruthy(args[0]) {
+				return args[1], nil
+			} else if len(args) > 2 {
+				return args[2], nil
+			}
+			return nil, nil
+		}},
+	"coalesce": {minArgs: 1, maxArgs: -1, nullable: true,
+		kindOf: func(args []exprNode) exprKind { return exprCommonKind(args...) },
+		call: func(ctx *exprContext, args []any) (any, error) {
+			for _, arg := range args {
+				if arg != nil {
+					return arg, nil
+				}
+			}
+			return nil, nil
+		}},
+	"nullif": {minArgs: 2, maxArgs: 2, nullable: true,
+		kindOf: func(args []exprNode) exprKind { return args[0].kind() },
+		call: func(ctx *exprContext, args []any) (any, error) {
+			if args[0] != nil && args[1] != nil && exprCompare(ctx, args[0], args[1]) == 0 {
+				return nil, nil
+			}
+			return args[0], nil
+		}},
+
+	// string
+	"upper": {minArgs: 1, maxArgs: 1, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) { return strings.ToUpper(exprToString(args[0])), nil }},
+	"lower": {minArgs: 1, maxArgs: 1, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) { return strings.ToLower(exprToString(args[0])), nil }},
+	"trim": {minArgs: 1, maxArgs: 1, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) { return strings.TrimSpace(exprToString(args[0])), nil }},
+	"ltrim": {minArgs: 1, maxArgs: 1, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			return strings.TrimLeftFunc(exprToString(args[0]), unicode.IsSpace), nil
+		}},
+	"rtrim": {minArgs: 1, maxArgs: 1, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			return strings.TrimRightFunc(exprToString(args[0]), unicode.IsSpace), nil
+		}},
+	"length": {minArgs: 1, maxArgs: 1, kind: exprKindInteger,
+		call: func(ctx *exprContext, args []any) (any, error) { return int64(len([]rune(exprToString(args[0])))), nil }},
+	"concat": {minArgs: 1, maxArgs: -1, kind: exprKindString, nullable: true,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			var sb strings.Builder
+			for _, arg := range args {
+				if arg != nil {
+					sb.WriteString(exprToString(arg))
+				}
+			}
+			return sb.String(), nil
+		}},
+	"replace": {minArgs: 3, maxArgs: 3, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			return strings.ReplaceAll(exprToString(args[0]), exprToString(args[1]), exprToString(args[2])), nil
+		}},
+	"substr": {minArgs: 2, maxArgs: 3, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			runes := []rune(exprToString(args[0]))
+			start, err := cast.ToIntE(args[1])
+			if err != nil {
+				return nil, g.Error(err, "invalid start position")
+			}
+			start = max(start-1, 0) // 1-based
+			if start >= len(runes) {
+				return "", nil
+			}
+			end := len(runes)
+			if len(args) > 2 {
+				length, err := cast.ToIntE(args[2])
+				if err != nil {
+					return nil, g.Error(err, "invalid length")
+				}
+				end = min(start+max(length, 0), len(runes))
+			}
+			return string(runes[start:end]), nil
+		}},
+	"left": {minArgs: 2, maxArgs: 2, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			runes := []rune(exprToString(args[0]))
+			n := min(max(cast.ToInt(args[1]), 0), len(runes))
+			return string(runes[:n]), nil
+		}},
+	"right": {minArgs: 2, maxArgs: 2, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			runes := []rune(exprToString(args[0]))
+			n := min(max(cast.ToInt(args[1]), 0), len(runes))
+			return string(runes[len(runes)-n:]), nil
+		}},
+	"split_part": {minArgs: 3, maxArgs: 3, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			parts := strings.Split(exprToString(args[0]), exprToString(args[1]))
+			if n := cast.ToInt(args[2]); n >= 1 && n <= len(parts) {
+				return parts[n-1], nil
+			}
+			return "", nil
+		}},
+	"contains": {minArgs: 2, maxArgs: 2, kind: exprKindBool,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			return strings.Contains(exprToString(args[0]), exprToString(args[1])), nil
+		}},
+	"starts_with": {minArgs: 2, maxArgs: 2, kind: exprKindBool,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			return strings.HasPrefix(exprToString(args[0]), exprToString(args[1])), nil
+		}},
+	"ends_with": {minArgs: 2, maxArgs: 2, kind: exprKindBool,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			return strings.HasSuffix(exprToString(args[0]), exprToString(args[1])), nil
+		}},
+	"json_extract": {minArgs: 2, maxArgs: 2,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			return Transforms.JSONExtract(args[0], exprToString(args[1]))
+		}},
+
+	// numeric
+	"abs": {minArgs: 1, maxArgs: 1,
+		kindOf: func(args []exprNode) exprKind { return exprNumericKind(args[0]) },
+		call: func(ctx *exprContext, args []any) (any, error) {
+			i, f, isInt, err := exprToNumber(args[0])
+			if err != nil {
+				return nil, err
+			} else if isInt {
+				return exprAbsInt(i), nil
+			}
+			return math.Abs(f), nil
+		}},
+	"round": {minArgs: 1, maxArgs: 2, kind: exprKindDecimal,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			_, f, _, err := exprToNumber(args[0])
+			if err != nil {
+				return nil, err
+			}
+			scale := 1.0
+			if len(args) > 1 {
+				scale = math.Pow(10, cast.ToFloat64(args[1]))
+			}
+			return math.Round(f*scale) / scale, nil
+		}},
+	"floor": {minArgs: 1, maxArgs: 1, kind: exprKindInteger,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			_, f, _, err := exprToNumber(args[0])
+			return int64(math.Floor(f)), err
+		}},
+	"ceil": {minArgs: 1, maxArgs: 1, kind: exprKindInteger,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			_, f, _, err := exprToNumber(args[0])
+			return int64(math.Ceil(f)), err
+		}},
+	"power": {minArgs: 2, maxArgs: 2, kind: exprKindDecimal,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			_, base, _, err := exprToNumber(args[0])
+			if err != nil {
+				return nil, err
+			}
+			_, exp, _, err := exprToNumber(args[1])
+			return math.Pow(base, exp), err
+		}},
+
+	// casting
+	"int": {minArgs: 1, maxArgs: 1, kind: exprKindInteger,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			i, f, isInt, err := exprToNumber(args[0])
+			if isInt {
+				return i, err
+			}
+			return int64(f), err
+		}},
+	"float": {minArgs: 1, maxArgs: 1, kind: exprKindDecimal,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			_, f, _, err := exprToNumber(args[0])
+			return f, err
+		}},
+	"string": {minArgs: 1, maxArgs: 1, kind: exprKindString,
+		call: func(ctx *exprContext, args []any) (any, error) { return exprToString(args[0]), nil }},
+	"bool": {minArgs: 1, maxArgs: 1, kind: exprKindBool,
+		call: func(ctx *exprContext, args []any) (any, error) { return cast.ToBoolE(args[0]) }},
+
+	// date / time
+	"now": {minArgs: 0, maxArgs: 0, kind: exprKindDatetime,
+		call: func(ctx *exprContext, args []any) (any, error) { return time.Now(), nil }},
+	"date": {minArgs: 1, maxArgs: 1, kind: exprKindDate,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			t, err := ctx.parseTime(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), nil
+		}},
+	"year":        exprDatePart(func(t time.Time) int { return t.Year() }),
+	"month":       exprDatePart(func(t time.Time) int { return int(t.Month()) }),
+	"day":         exprDatePart(func(t time.Time) int { return t.Day() }),
+	"hour":        exprDatePart(func(t time.Time) int { return t.Hour() }),
+	"minute":      exprDatePart(func(t time.Time) int { return t.Minute() }),
+	"second":      exprDatePart(func(t time.Time) int { return t.Second() }),
+	"day_of_week": exprDatePart(func(t time.Time) int { return int(t.Weekday()) }),
+	"day_of_year": exprDatePart(func(t time.Time) int { return t.YearDay() }),
+	"date_add": {minArgs: 3, maxArgs: 3, kind: exprKindDatetime,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			t, err := ctx.parseTime(args[0])
+			if err != nil {
+				return nil, err
+			}
+			n, err := cast.ToIntE(args[1])
+			if err != nil {
+				return nil, g.Error(err, "invalid interval")
+			}
+
+			switch unit := strings.TrimSuffix(strings.ToLower(exprToString(args[2])), "s"); unit {
+			case "month":
+				return t.AddDate(0, n, 0), nil
+			case "year":
+				return t.AddDate(n, 0, 0), nil
+			default:
+				if d, ok := exprDateUnits[unit]; ok {
+					return t.Add(time.Duration(n) * d), nil
+				}
+				return nil, g.Error("invalid unit: %s", unit)
+			}
+		}},
+	"date_diff": {minArgs: 3, maxArgs: 3, kind: exprKindInteger,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			t1, err := ctx.parseTime(args[0])
+			if err != nil {
+				return nil, err
+			}
+			t2, err := ctx.parseTime(args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			switch unit := strings.TrimSuffix(strings.ToLower(exprToString(args[2])), "s"); unit {
+			case "month":
+				return int64((t1.Year()-t2.Year())*12 + int(t1.Month()) - int(t2.Month())), nil
+			case "year":
+				return int64(t1.Year() - t2.Year()), nil
+			default:
+				if d, ok := exprDateUnits[unit]; ok {
+					return int64(t1.Sub(t2) / d), nil
+				}
+				return nil, g.Error("invalid unit: %s", unit)
+			}
+		}},
+}
+
+func exprDatePart(part func(t time.Time) int) exprFunction {
+	return exprFunction{minArgs: 1, maxArgs: 1, kind: exprKindInteger,
+		call: func(ctx *exprContext, args []any) (any, error) {
+			t, err := ctx.parseTime(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return int64(part(t)), nil
+		}}
+}
+
+func exprNumericKind(n exprNode) exprKind {
+	if n.kind() == exprKindInteger {
+		return exprKindInteger
+	}
+	return exprKindDecimal
+}
+
+func exprAbsInt(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// exprToNumber converts a value to a number, keeping integers as int64
+func exprToNumber(v any) (i int64, f float64, isInt bool, err error) {
+	switch val := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		i = cast.ToInt64(val)
+		return i, float64(i), true, nil
+	case float32, float64:
+		f = cast.ToFloat64(val)
+		return int64(f), f, false, nil
+	case bool:
+		return 0, 0, false, g.Error("cannot use boolean as number")
+	case []byte:
+		v = string(val)
+	}
+
+	s := strings.TrimSpace(cast.ToString(v))
+	if i, err = strconv.ParseInt(s, 10, 64); err == nil {
+		return i, float64(i), true, nil
+	}
+	if f, err = strconv.ParseFloat(s, 64); err == nil {
+		return int64(f), f, false, nil
+	}
+	return 0, 0, false, g.Error("cannot convert '%s' to number", s)
+}
+
+func exprArithmetic(op string, left, right any) (any, error) {
+	li, lf, lInt, err := exprToNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	ri, rf, rInt, err := exprToNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	if lInt && rInt && op != "/" {
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "%":
+			if ri == 0 {
+				return nil, nil
+			}
+			return li % ri, nil
+		}
+	}
+
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, nil // division by zero is null
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, nil
+		}
+		return math.Mod(lf, rf), nil
+	}
+	return nil, g.Error("unsupported operator: %s", op)
+}
+
+// exprCompare compares two non-null values, returns -1, 0 or 1.
+// Numbers are compared numerically, times chronologically, others as strings.
+func exprCompare(ctx *exprContext, a, b any) int {
+	_, af, _, aErr := exprToNumber(a)
+	_, bf, _, bErr := exprToNumber(b)
+	if aErr == nil && bErr == nil {
+		return compareOrdered(af, bf)
+	}
+
+	_, aIsTime := a.(time.Time)
+	_, bIsTime := b.(time.Time)
+	if aIsTime || bIsTime {
+		at, aErr := ctx.parseTime(a)
+		bt, bErr := ctx.parseTime(b)
+		if aErr == nil && bErr == nil {
+			return at.Compare(bt)
+		}
+	}
+
+	if ab, ok := a.(bool); ok {
+		if bb, err := cast.ToBoolE(b); err == nil {
+			return compareOrdered(cast.ToInt(ab), cast.ToInt(bb))
+		}
+	}
+
+	return strings.Compare(exprToString(a), exprToString(b))
+}
+
+func compareOrdered[T int | float64](a, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// exprCompareOp returns whether the comparison result satisfies the operator
+func exprCompareOp(cmp int, op string) bool {
+	switch op {
+	case "=", "==":
+		return cmp == 0
+	case "!=", "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func exprTruthy(v any) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return cast.ToBool(val)
+	}
+	if _, f, _, err := exprToNumber(v); err == nil {
+		return f != 0
+	}
+	return false
+}
+
+func exprToString(v any) string {
+	switch val := v.(type) {
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case *time.Time:
+		if val != nil {
+			return val.Format(time.RFC3339Nano)
+		}
+		return ""
+	case *string:
+		if val != nil {
+			return *val
+		}
+		return ""
+	}
+	return cast.ToString(v)
+}
+
+type exprTokenKind int
+
+const (
+	exprTokenIdent exprTokenKind = iota
+	exprTokenQuotedIdent
+	exprTokenString
+	exprTokenNumber
+	exprTokenOperator
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+}
+
+// is returns true if the token is an identifier or operator matching one of the words
+func (t exprToken) is(words ...string) bool {
+	if t.kind != exprTokenIdent && t.kind != exprTokenOperator {
+		return false
+	}
+	for _, word := range words {
+		if strings.EqualFold(t.text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteExprString quotes the value as an identifier (`"`) or a string (`'`)
+func quoteExprString(val string, quote rune) string {
+	q := string(quote)
+	return q + strings.ReplaceAll(val, q, q+q) + q
+}
+
+func tokenizeExpression(text string) (tokens []exprToken, err error) {
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			// quoted string or identifier, doubled quote escapes the quote
+			quote := r
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == quote {
+					if i+1 < len(runes) && runes[i+1] == quote {
+						sb.WriteRune(quote)
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, g.Error("unterminated quote")
+			}
+			kind := exprTokenString
+			if quote == '"' {
+				kind = exprTokenQuotedIdent
+			}
+			tokens = append(tokens, exprToken{kind, sb.String()})
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || strings.ContainsRune(".eE", runes[j]) || (strings.ContainsRune("-+", runes[j]) && strings.ContainsRune("eE", runes[j-1]))) {
+				j++
+			}
+			tokens = append(tokens, exprToken{exprTokenNumber, string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, exprToken{exprTokenIdent, string(runes[i:j])})
+			i = j
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				if two := string(runes[i : i+2]); g.In(two, "==", "!=", "<>", "<=", ">=", "||", "!~") {
+					op = two
+				}
+			}
+			if !g.In(op, "=", "==", "!=", "<>", "<", "<=", ">", ">=", "||", "~", "!~", "+", "-", "*", "/", "%", "(", ")", ",") {
+				return nil, g.Error("invalid character: %s", op)
+			}
+			tokens = append(tokens, exprToken{exprTokenOperator, op})
+			i = i + len(op)
+		}
+	}
+	return
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	refs   []string
+
+	// constraint mode: `value` is the only column, and is implied when the
+	// operand is omitted. Other words are strings
+	constraint bool
+}
+
+// constraintValue is the value checked by a constraint, the only column of the row
+var constraintValue = &exprColumn{name: "value"}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) peek() exprToken {
+	if p.done() {
+		return exprToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) peekAt(offset int) exprToken {
+	if p.pos+offset >= len(p.tokens) {
+		return exprToken{}
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) expect(words ...string) error {
+	if t := p.next(); !t.is(words...) {
+		if t.text == "" {
+			return g.Error("expected '%s', got end of expression", strings.Join(words, "' or '"))
+		}
+		return g.Error("expected '%s', got '%s'", strings.Join(words, "' or '"), t.text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.constraint && p.peek().is("not") {
+		switch next := p.peekAt(1); {
+		case next.is("null"):
+			p.pos += 2
+			return &exprIsNull{x: constraintValue, negate: true}, nil
+		case next.is("in", "between"):
+			return p.parseComparison() // implied value
+		}
+	}
+
+	if p.peek().is("not") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: "not", x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	var left exprNode = constraintValue
+	if t := p.peek(); !p.constraint || !t.is("=", "==", "!=", "<>", "<", "<=", ">", ">=", "~", "!~", "is", "not", "in", "between") {
+		var err error
+		if left, err = p.parseConcat(); err != nil {
+			return nil, err
+		}
+	}
+
+	t := p.peek()
+	switch {
+	case t.is("~", "!~"):
+		p.next()
+		pattern := p.next()
+		if pattern.kind != exprTokenString {
+			return nil, g.Error("expected a quoted regex pattern, got '%s'", pattern.text)
+		}
+		re, err := regexp.Compile(pattern.text)
+		if err != nil {
+			return nil, g.Error(err, "invalid regex pattern: %s", pattern.text)
+		}
+		return &exprRegex{x: left, re: re, negate: t.text == "!~"}, nil
+
+	case t.is("=", "==", "!=", "<>", "<", "<=", ">", ">="):
+		p.next()
+		right, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		return &exprBinary{op: t.text, left: left, right: right}, nil
+
+	case t.is("is"):
+		p.next()
+		negate := p.peek().is("not")
+		if negate {
+			p.next()
+		}
+		return &exprIsNull{x: left, negate: negate}, p.expect("null")
+
+	case t.is("not", "in", "between"):
+		p.next()
+		negate := t.is("not")
+		if negate {
+			t = p.next()
+		}
+
+		if t.is("in") {
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			list, err := p.parseList()
+			if err != nil {
+				return nil, err
+			}
+			return &exprIn{x: left, list: list, negate: negate}, nil
+		} else if t.is("between") {
+			low, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("and"); err != nil {
+				return nil, err
+			}
+			high, err := p.parseConcat()
+			if err != nil {
+				return nil, err
+			}
+			var node exprNode = &exprBinary{
+				op:    "and",
+				left:  &exprBinary{op: ">=", left: left, right: low},
+				right: &exprBinary{op: "<=", left: left, right: high},
+			}
+			if negate {
+				node = &exprUnary{op: "not", x: node}
+			}
+			return node, nil
+		}
+		return nil, g.Error("expected 'in' or 'between', got '%s'", t.text)
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseConcat() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("||") {
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("+", "-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("*", "/", "%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.peek().is("-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: "-", x: x}, nil
+	} else if p.peek().is("+") {
+		p.next()
+	}
+	return p.parsePrimary()
+}
+
+// parseList parses comma separated expressions until the closing parenthesis
+func (p *exprParser) parseList() (list []exprNode, err error) {
+	if p.peek().is(")") {
+		p.next()
+		return
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		if p.peek().is(")") {
+			p.next()
+			return list, nil
+		} else if err = p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.done() {
+		return nil, g.Error("unexpected end of expression")
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == exprTokenString:
+		return &exprLiteral{val: t.text}, nil
+
+	case t.kind == exprTokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return &exprLiteral{val: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, g.Error("invalid number: %s", t.text)
+		}
+		return &exprLiteral{val: f}, nil
+
+	case t.kind == exprTokenQuotedIdent && p.constraint:
+		return &exprLiteral{val: t.text}, nil
+
+	case t.kind == exprTokenQuotedIdent:
+		p.refs = append(p.refs, strings.ToLower(t.text))
+		return &exprColumn{name: t.text}, nil
+
+	case t.is("("):
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+
+	case t.is("true", "false"):
+		return &exprLiteral{val: strings.EqualFold(t.text, "true")}, nil
+
+	case t.is("null"):
+		return &exprLiteral{val: nil}, nil
+
+	case t.is("case"):
+		return p.parseCase()
+
+	case t.kind == exprTokenIdent && p.constraint && !p.peek().is("("):
+		switch {
+		case t.is("value"):
+			return constraintValue, nil
+		case t.is("value_len", "length", "len"):
+			return &exprCall{name: "length", fn: exprFunctions["length"], args: []exprNode{constraintValue}}, nil
+		}
+		return &exprLiteral{val: t.text}, nil // unquoted words are taken as strings
+
+	case t.kind == exprTokenIdent:
+		if !p.peek().is("(") {
+			p.refs = append(p.refs, strings.ToLower(t.text))
+			return &exprColumn{name: t.text}, nil
+		}
+
+		// function call
+		p.next()
+		name := strings.ToLower(t.text)
+		fn, ok := exprFunctions[name]
+		if !ok {
+			return nil, g.Error("unknown function: %s", t.text)
+		}
+
+		args, err := p.parseList()
+		if err != nil {
+			return nil, err
+		} else if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+			return nil, g.Error("invalid number of arguments for %s: %d", name, len(args))
+		}
+		return &exprCall{name: name, fn: fn, args: args}, nil
+	}
+
+	return nil, g.Error("unexpected token '%s'", t.text)
+}
+
+func (p *exprParser) parseCase() (exprNode, error) {
+	node := &exprCase{}
+	if !p.peek().is("when") {
+		operand, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.operand = operand
+	}
+
+	for p.peek().is("when") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect("then"); err != nil {
+			return nil, err
+		}
+		result, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.whens = append(node.whens, [2]exprNode{cond, result})
+	}
+
+	if len(node.whens) == 0 {
+		return nil, g.Error("expected 'when' in case expression")
+	}
+
+	if p.peek().is("else") {
+		p.next()
+		elseX, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		node.elseX = elseX
+	}
+
+	return node, p.expect("end")
+}
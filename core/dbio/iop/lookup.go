@@ -0,0 +1,231 @@
+package iop
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// Lookup appends the columns of a reference dataset to the stream rows,
+// matching the stream key columns with the reference key columns
+type Lookup struct {
+	Name        string           `json:"name"`
+	Keys        []string         `json:"keys"`         // stream key columns
+	Columns     Columns          `json:"columns"`      // columns to append
+	Defaults    []any            `json:"defaults"`     // values for unmatched keys, per column
+	OnUnmatched string           `json:"on_unmatched"` // default (default) or reject
+	Entries     map[string][]any `json:"entries"`      // key => column values
+
+	keyIndexes []int
+	indexes    []int
+	failed     bool
+}
+
+// lookupSets are the registered lookups, by ID. Stream processors obtain
+// theirs with the `lookups_id` config key, so that the entries are shared
+// and keep their value types
+var lookupSets = sync.Map{}
+
+// RegisterLookups registers the lookups and returns their ID
+func RegisterLookups(lookups []Lookup) (id string) {
+	id = g.NewTsID("lookups")
+	lookupSets.Store(id, lookups)
+	return id
+}
+
+// GetLookups returns the registered lookups with the provided ID
+func GetLookups(id string) []Lookup {
+	if lookups, ok := lookupSets.Load(id); ok {
+		return lookups.([]Lookup)
+	}
+	return nil
+}
+
+// UnregisterLookups unregisters the lookups with the provided ID
+func UnregisterLookups(id string) {
+	lookupSets.Delete(id)
+}
+
+// NewLookup creates a lookup from the reference data, keyed by the
+// refKeys columns, which match the stream keys columns. The refColumns
+// columns are appended to the stream as the columns named names.
+// The first row of a duplicate key is kept.
+func NewLookup(name string, data Dataset, keys, refKeys, refColumns, names []string) (l Lookup, err error) {
+	if len(keys) == 0 || len(keys) != len(refKeys) {
+		return l, g.Error("lookup %s should have the same number of keys for the stream and the reference", name)
+	} else if len(refColumns) == 0 || len(refColumns) != len(names) {
+		return l, g.Error("lookup %s should have columns to append", name)
+	}
+
+	fieldMap := data.Columns.FieldMap(true)
+	getIndexes := func(colNames []string) (indexes []int, err error) {
+		for _, colName := range colNames {
+			index, ok := fieldMap[strings.ToLower(colName)]
+			if !ok {
+				return nil, g.Error("column %s not found in reference of lookup %s", colName, name)
+			}
+			indexes = append(indexes, index)
+		}
+		return indexes, nil
+	}
+
+	refKeyIndexes, err := getIndexes(refKeys)
+	if err != nil {
+		return l, err
+	}
+	refIndexes, err := getIndexes(refColumns)
+	if err != nil {
+		return l, err
+	}
+
+	l = Lookup{
+		Name:        name,
+		Keys:        keys,
+		Columns:     make(Columns, len(names)),
+		OnUnmatched: "default",
+		Entries:     map[string][]any{},
+	}
+	for i, index := range refIndexes {
+		l.Columns[i] = Column{Name: names[i], Type: data.Columns[index].Type}
+	}
+
+	duplicates := 0
+	for _, row := range data.Rows {
+		keyValues := make([]any, len(refKeyIndexes))
+		for i, index := range refKeyIndexes {
+			if index < len(row) {
+				keyValues[i] = row[index]
+			}
+		}
+
+		key, ok := lookupKey(keyValues)
+		if !ok {
+			continue
+		} else if _, exists := l.Entries[key]; exists {
+			duplicates++
+			continue
+		}
+
+		values := make([]any, len(refIndexes))
+		for i, index := range refIndexes {
+			if index < len(row) {
+				values[i] = row[index]
+			}
+		}
+		l.Entries[key] = values
+	}
+
+	if duplicates > 0 {
+		g.Warn("lookup %s has %d rows with duplicate keys, keeping the first", name, duplicates)
+	}
+
+	return l, nil
+}
+
+// lookupKey returns the key of the values, false if any is null
+func lookupKey(values []any) (string, bool) {
+	parts := make([]string, len(values))
+	for i, val := range values {
+		if val == nil {
+			return "", false
+		}
+		parts[i] = cast.ToString(val)
+	}
+	return strings.Join(parts, "\x1f"), true
+}
+
+// addLookupColumns appends the columns of the configured lookups to the
+// stream, and binds their keys to the stream columns
+func (ds *Datastream) addLookupColumns() (err error) {
+	if len(ds.Sp.Config.Lookups) == 0 {
+		return nil
+	}
+
+	if ds.Sp.computedIndex == nil {
+		ds.Sp.computedIndex = map[int]bool{}
+	}
+
+	lookups := make([]Lookup, len(ds.Sp.Config.Lookups))
+	copy(lookups, ds.Sp.Config.Lookups)
+	for i := range lookups {
+		l := &lookups[i]
+		fieldMap := ds.Columns.FieldMap(true)
+
+		l.keyIndexes = []int{}
+		for _, key := range l.Keys {
+			index, ok := fieldMap[strings.ToLower(key)]
+			if !ok {
+				return g.Error("key column %s not found for lookup %s", key, l.Name)
+			}
+			l.keyIndexes = append(l.keyIndexes, index)
+		}
+
+		l.indexes = []int{}
+		for _, lCol := range l.Columns {
+			if _, ok := fieldMap[strings.ToLower(lCol.Name)]; ok {
+				return g.Error("column %s of lookup %s already exists in stream", lCol.Name, l.Name)
+			}
+
+			col := Column{
+				Name:        lCol.Name,
+				Type:        lCol.Type,
+				Position:    len(ds.Columns) + 1,
+				Description: "Sling.Lookup",
+				Metadata:    map[string]string{"lookup": l.Name},
+			}
+			ds.Columns = append(ds.Columns, col)
+			fieldMap[strings.ToLower(col.Name)] = col.Position - 1
+
+			l.indexes = append(l.indexes, col.Position-1)
+			ds.Sp.computedIndex[col.Position-1] = true
+		}
+	}
+
+	ds.Sp.lookups = lookups
+	return nil
+}
+
+// LookupRow sets the looked-up values of a row. Unmatched keys get the
+// default values, or reject the row with `on_unmatched: reject`
+func (sp *StreamProcessor) LookupRow(row []any, columns Columns) []any {
+	for len(row) < len(columns) {
+		row = append(row, nil)
+	}
+	for len(sp.rowChecksum) < len(row) {
+		sp.rowChecksum = append(sp.rowChecksum, 0)
+	}
+
+	for i := range sp.lookups {
+		l := &sp.lookups[i]
+
+		keyValues := make([]any, len(l.keyIndexes))
+		for j, index := range l.keyIndexes {
+			keyValues[j] = row[index]
+		}
+
+		key, ok := lookupKey(keyValues)
+		values, matched := l.Entries[key]
+		if !ok || !matched {
+			values = l.Defaults
+			if l.OnUnmatched == "reject" {
+				reason := g.F("no match in lookup %s", l.Name)
+				if !sp.rejectCurrent(&columns[l.keyIndexes[0]], reason, key) && !l.failed && sp.ds != nil {
+					sp.ds.Context.CaptureErr(g.Error("%s for key %#v. Set target option 'reject_to' to reject the rows instead", reason, keyValues))
+					l.failed = true
+				}
+			}
+		}
+
+		for j, index := range l.indexes {
+			var val any
+			if j < len(values) {
+				val = values[j]
+			}
+			row[index] = sp.CastVal(index, val, &columns[index])
+		}
+	}
+
+	return row
+}
@@ -0,0 +1,441 @@
+package iop
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/apache/arrow/go/v16/arrow"
+	"github.com/apache/arrow/go/v16/arrow/array"
+	"github.com/apache/arrow/go/v16/arrow/ipc"
+	"github.com/apache/arrow/go/v16/arrow/memory"
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// PluginFormat is the format of the rows exchanged with a plugin process
+type PluginFormat string
+
+const (
+	// PluginFormatJsonLines exchanges one JSON object per row and line
+	PluginFormatJsonLines PluginFormat = "jsonlines"
+	// PluginFormatArrow exchanges an Arrow IPC stream, one record batch per batch
+	PluginFormatArrow PluginFormat = "arrow"
+)
+
+// PluginBatchSize is the default number of rows written to a plugin per batch
+var PluginBatchSize = 1000
+
+// Plugin is an external transform process. The plugin is started once per
+// stream, reads the rows from its stdin and writes the transformed rows to
+// its stdout, in the same format, until its stdin is closed. The transformed
+// rows may have a different schema, and any number of rows per batch.
+// Anything written to stderr is logged, and returned with a non-zero exit code.
+type Plugin struct {
+	Command   []string          `json:"command"`
+	Format    PluginFormat      `json:"format"`
+	BatchSize int               `json:"batch_size"`
+	Env       map[string]string `json:"env"`
+}
+
+// pluginProcess is a running plugin
+type pluginProcess struct {
+	plugin  Plugin
+	cmd     *exec.Cmd
+	stderr  bytes.Buffer
+	written chan error
+	once    sync.Once
+	err     error
+}
+
+// Run starts the plugin process and pipes the rows of ds through it.
+// The rows are written as they come, so a slow plugin slows the
+// reading of ds. It returns the stream of the transformed rows.
+func (p Plugin) Run(ds *Datastream) (nDs *Datastream, err error) {
+	if len(p.Command) == 0 {
+		return nil, g.Error("plugin has no command")
+	}
+	if p.Format == "" {
+		p.Format = PluginFormatJsonLines
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = PluginBatchSize
+	}
+
+	pp := &pluginProcess{plugin: p, written: make(chan error, 1)}
+	pp.cmd = exec.CommandContext(ds.Context.Ctx, p.Command[0], p.Command[1:]...)
+	pp.cmd.Env = os.Environ()
+	for k, v := range p.Env {
+		pp.cmd.Env = append(pp.cmd.Env, k+"="+v)
+	}
+	pp.cmd.Stderr = &pp.stderr
+
+	stdin, err := pp.cmd.StdinPipe()
+	if err != nil {
+		return nil, g.Error(err, "could not get stdin of plugin")
+	}
+	stdout, err := pp.cmd.StdoutPipe()
+	if err != nil {
+		return nil, g.Error(err, "could not get stdout of plugin")
+	}
+
+	g.Debug("starting plugin: %s", strings.Join(p.Command, " "))
+	if err = pp.cmd.Start(); err != nil {
+		return nil, g.Error(err, "could not start plugin: %s", strings.Join(p.Command, " "))
+	}
+
+	go func() {
+		defer stdin.Close()
+
+		var err error
+		switch p.Format {
+		case PluginFormatArrow:
+			err = pp.writeArrow(stdin, ds)
+		default:
+			err = pp.writeJsonLines(stdin, ds)
+		}
+		if err == nil {
+			err = ds.Err() // so a failed source does not pass as a complete one
+		}
+		pp.written <- err
+	}()
+
+	nDs = NewDatastreamContext(ds.Context.Ctx, nil)
+	switch p.Format {
+	case PluginFormatArrow:
+		err = pp.readArrow(stdout, nDs)
+	case PluginFormatJsonLines:
+		err = pp.readJsonLines(stdout, nDs)
+	default:
+		err = g.Error("invalid plugin format: %s", p.Format)
+	}
+	if err != nil {
+		_ = pp.cmd.Process.Kill() // so a plugin blocked on its stdout exits
+		if errP := pp.wait(); errP != nil {
+			err = errP
+		}
+		return nil, g.Error(err, "could not read rows from plugin")
+	}
+
+	return nDs, nil
+}
+
+// wait waits for the rows to be written and the process to exit.
+// It returns the exit error of the process, with its stderr output
+func (pp *pluginProcess) wait() error {
+	pp.once.Do(func() {
+		writeErr := <-pp.written
+
+		// stderr is fully copied once Wait returns
+		waitErr := pp.cmd.Wait()
+		stderr := strings.TrimSpace(pp.stderr.String())
+		if stderr != "" {
+			g.Debug("plugin stderr:\n%s", stderr)
+		}
+
+		if err := waitErr; err != nil {
+			pp.err = g.Error(
+				err,
+				"Plugin Command -> %s\nPlugin Error   -> %s",
+				strings.Join(pp.plugin.Command, " "), stderr,
+			)
+		} else if writeErr != nil {
+			pp.err = g.Error(writeErr, "could not write rows to plugin")
+		}
+	})
+	return pp.err
+}
+
+// writeJsonLines writes the rows as JSON objects, one per line,
+// flushed every batch
+func (pp *pluginProcess) writeJsonLines(w io.Writer, ds *Datastream) (err error) {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+
+	count := 0
+	for batch := range ds.BatchChan {
+		names := batch.Columns.Names()
+		for row := range batch.Rows {
+			rec := make(map[string]any, len(names))
+			for i, name := range names {
+				if i < len(row) {
+					rec[name] = row[i]
+				}
+			}
+			if err = encoder.Encode(rec); err != nil {
+				return g.Error(err, "could not write JSON line")
+			}
+
+			if count++; count%pp.plugin.BatchSize == 0 {
+				if err = bw.Flush(); err != nil {
+					return g.Error(err, "could not flush batch")
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// readJsonLines reads the JSON objects into nDs, one row per object.
+// The columns are inferred, with nested objects flattened
+func (pp *pluginProcess) readJsonLines(r io.Reader, nDs *Datastream) (err error) {
+	decoder := json.NewDecoder(r)
+	js := NewJSONStream(nDs, decoder, true, "")
+
+	nextFunc := func(it *Iterator) bool {
+		for {
+			select {
+			case row := <-js.buffer:
+				it.Row = row
+				return true
+			default:
+			}
+
+			rec := map[string]any{}
+			if err := decoder.Decode(&rec); err == io.EOF {
+				if err = pp.wait(); err != nil {
+					it.Context.CaptureErr(err)
+				}
+				return false
+			} else if err != nil {
+				it.Context.CaptureErr(g.Error(err, "could not decode JSON line from plugin"))
+				return false
+			}
+			js.parseRecords([]map[string]any{rec})
+		}
+	}
+
+	nDs.it = nDs.NewIterator(nDs.Columns, nextFunc)
+	return nDs.Start()
+}
+
+// writeArrow writes the rows as an Arrow IPC stream, one record per batch
+func (pp *pluginProcess) writeArrow(w io.Writer, ds *Datastream) (err error) {
+	columns := ds.Columns
+	fields := make([]arrow.Field, len(columns))
+	for i, col := range columns {
+		fields[i] = arrow.Field{Name: col.Name, Type: columnArrowType(col), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema))
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+
+	flush := func() error {
+		rec := builder.NewRecord()
+		defer rec.Release()
+		return writer.Write(rec)
+	}
+
+	count := 0
+	for batch := range ds.BatchChan {
+		if !batch.Columns.IsSimilarTo(columns) {
+			return g.Error("stream columns changed, which is not supported with the arrow plugin format. Use the jsonlines format instead")
+		}
+
+		for row := range batch.Rows {
+			for i := range columns {
+				var val any
+				if i < len(row) {
+					val = row[i]
+				}
+				if err = appendArrowValue(builder.Field(i), val); err != nil {
+					return g.Error(err, "could not convert value of column %s", columns[i].Name)
+				}
+			}
+
+			if count++; count%pp.plugin.BatchSize == 0 {
+				if err = flush(); err != nil {
+					return g.Error(err, "could not write arrow record")
+				}
+			}
+		}
+	}
+
+	if count%pp.plugin.BatchSize != 0 {
+		if err = flush(); err != nil {
+			return g.Error(err, "could not write arrow record")
+		}
+	}
+
+	return writer.Close()
+}
+
+// readArrow reads the Arrow IPC stream into nDs. The columns are
+// those of the stream schema
+func (pp *pluginProcess) readArrow(r io.Reader, nDs *Datastream) (err error) {
+	reader, err := ipc.NewReader(r)
+	if err != nil {
+		return g.Error(err, "could not read arrow schema")
+	}
+	nDs.Defer(reader.Release)
+
+	for i, field := range reader.Schema().Fields() {
+		nDs.Columns = append(nDs.Columns, Column{
+			Name:     field.Name,
+			Type:     arrowColumnType(field.Type),
+			Position: i + 1,
+		})
+	}
+
+	var rec arrow.Record
+	var recI int64
+	nextFunc := func(it *Iterator) bool {
+		for rec == nil || recI >= rec.NumRows() {
+			if !reader.Next() {
+				if err := reader.Err(); err != nil {
+					it.Context.CaptureErr(g.Error(err, "could not read arrow record from plugin"))
+				}
+				if err := pp.wait(); err != nil {
+					it.Context.CaptureErr(err)
+				}
+				return false
+			}
+			rec, recI = reader.Record(), 0
+		}
+
+		it.Row = make([]any, rec.NumCols())
+		for j := range it.Row {
+			it.Row[j] = arrowValue(rec.Column(j), int(recI))
+		}
+		recI++
+		return true
+	}
+
+	nDs.it = nDs.NewIterator(nDs.Columns, nextFunc)
+	nDs.Inferred = true
+	return nDs.Start()
+}
+
+// columnArrowType returns the arrow type of a column. Decimals are
+// strings, to keep their precision
+func columnArrowType(col Column) arrow.DataType {
+	switch {
+	case col.IsInteger():
+		return arrow.PrimitiveTypes.Int64
+	case col.IsFloat():
+		return arrow.PrimitiveTypes.Float64
+	case col.IsBool():
+		return arrow.FixedWidthTypes.Boolean
+	case col.IsDate(), col.IsDatetime():
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// arrowColumnType returns the column type of an arrow type
+func arrowColumnType(dt arrow.DataType) ColumnType {
+	switch dt.ID() {
+	case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64,
+		arrow.UINT8, arrow.UINT16, arrow.UINT32, arrow.UINT64:
+		return BigIntType
+	case arrow.FLOAT16, arrow.FLOAT32, arrow.FLOAT64:
+		return FloatType
+	case arrow.DECIMAL128, arrow.DECIMAL256:
+		return DecimalType
+	case arrow.BOOL:
+		return BoolType
+	case arrow.DATE32, arrow.DATE64:
+		return DateType
+	case arrow.TIMESTAMP:
+		if dt.(*arrow.TimestampType).TimeZone != "" {
+			return TimestampzType
+		}
+		return DatetimeType
+	case arrow.BINARY, arrow.LARGE_BINARY:
+		return BinaryType
+	default:
+		return StringType
+	}
+}
+
+// appendArrowValue appends a value to the builder of its column
+func appendArrowValue(b array.Builder, val any) (err error) {
+	if val == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	switch builder := b.(type) {
+	case *array.Int64Builder:
+		v, err := cast.ToInt64E(val)
+		if err != nil {
+			return g.Error(err, "invalid integer: %#v", val)
+		}
+		builder.Append(v)
+	case *array.Float64Builder:
+		v, err := cast.ToFloat64E(val)
+		if err != nil {
+			return g.Error(err, "invalid float: %#v", val)
+		}
+		builder.Append(v)
+	case *array.BooleanBuilder:
+		v, err := cast.ToBoolE(val)
+		if err != nil {
+			return g.Error(err, "invalid bool: %#v", val)
+		}
+		builder.Append(v)
+	case *array.TimestampBuilder:
+		v, err := cast.ToTimeE(val)
+		if err != nil {
+			return g.Error(err, "invalid datetime: %#v", val)
+		}
+		ts, err := arrow.TimestampFromTime(v, arrow.Microsecond)
+		if err != nil {
+			return g.Error(err, "invalid datetime: %#v", val)
+		}
+		builder.Append(ts)
+	case *array.StringBuilder:
+		v, err := cast.ToStringE(val)
+		if err != nil {
+			return g.Error(err, "invalid string: %#v", val)
+		}
+		builder.Append(v)
+	default:
+		return g.Error("unhandled arrow builder: %T", b)
+	}
+
+	return nil
+}
+
+// arrowValue returns the value at index i of an arrow array
+func arrowValue(arr arrow.Array, i int) any {
+	if arr.IsNull(i) {
+		return nil
+	}
+
+	switch a := arr.(type) {
+	case *array.Int8, *array.Int16, *array.Int32, *array.Int64,
+		*array.Uint8, *array.Uint16, *array.Uint32, *array.Uint64:
+		return cast.ToInt64(a.GetOneForMarshal(i))
+	case *array.Float16:
+		return float64(a.Value(i).Float32())
+	case *array.Float32:
+		return float64(a.Value(i))
+	case *array.Float64:
+		return a.Value(i)
+	case *array.Boolean:
+		return a.Value(i)
+	case *array.Date32:
+		return a.Value(i).ToTime()
+	case *array.Date64:
+		return a.Value(i).ToTime()
+	case *array.Timestamp:
+		return a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit)
+	case *array.Binary:
+		return bytes.Clone(a.Value(i))
+	case *array.LargeBinary:
+		return bytes.Clone(a.Value(i))
+	default:
+		return arr.ValueStr(i)
+	}
+}
@@ -0,0 +1,217 @@
+package iop
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/flarco/g"
+	"github.com/spf13/cast"
+)
+
+// RejectedRow is a row rejected from a stream, with the offending column and reason
+type RejectedRow struct {
+	Source string `json:"source"`
+	RowNum uint64 `json:"row_num"`
+	Column string `json:"column"`
+	Reason string `json:"reason"`
+	Value  any    `json:"value"`
+	Row    []any  `json:"row"`
+}
+
+// RejectedRowColumns are the columns of a rejected rows dataset
+var RejectedRowColumns = Columns{
+	{Position: 1, Name: "source", Type: StringType},
+	{Position: 2, Name: "row_num", Type: BigIntType},
+	{Position: 3, Name: "column", Type: StringType},
+	{Position: 4, Name: "reason", Type: TextType},
+	{Position: 5, Name: "value", Type: TextType},
+	{Position: 6, Name: "row", Type: TextType},
+}
+
+// Values returns the values to match RejectedRowColumns
+func (rr RejectedRow) Values() []any {
+	return []any{rr.Source, rr.RowNum, rr.Column, rr.Reason, cast.ToString(rr.Value), g.Marshal(rr.Row)}
+}
+
+// rejectWriters are the registered reject writers, by ID. Stream processors
+// obtain theirs with the `reject_writer` config key
+var rejectWriters = sync.Map{}
+
+// RejectWriter streams the rejected rows of one or more streams into a
+// datastream, which is written out while the main load continues
+type RejectWriter struct {
+	ID         string
+	MaxRejects int64 // fail once more rows are rejected
+
+	write   func(ds *Datastream) error
+	rows    chan []any
+	count   atomic.Int64
+	started sync.Once
+	done    chan struct{}
+	closed  bool
+	mux     sync.RWMutex
+	err     error
+}
+
+// NewRejectWriter creates and registers a reject writer. The write function is
+// called in a goroutine with the datastream of rejected rows, once the first row
+// is rejected. Nothing is written if no rows are rejected.
+func NewRejectWriter(maxRejects int64, write func(ds *Datastream) error) *RejectWriter {
+	rw := &RejectWriter{
+		ID:         g.NewTsID("rejects"),
+		MaxRejects: maxRejects,
+		write:      write,
+		rows:       MakeRowsChan(),
+		done:       make(chan struct{}),
+	}
+	rejectWriters.Store(rw.ID, rw)
+	return rw
+}
+
+// GetRejectWriter returns the registered reject writer with the provided ID
+func GetRejectWriter(id string) *RejectWriter {
+	if rw, ok := rejectWriters.Load(id); ok {
+		return rw.(*RejectWriter)
+	}
+	return nil
+}
+
+// start starts the datastream of rejected rows, and the write function
+func (rw *RejectWriter) start() {
+	nextFunc := func(it *Iterator) bool {
+		for it.Row = range rw.rows {
+			return true
+		}
+		return false
+	}
+
+	ds := NewDatastreamIt(context.Background(), RejectedRowColumns.Clone(), nextFunc)
+	ds.it.IsCasted = true
+	ds.Inferred = true
+
+	go func() {
+		defer close(rw.done)
+
+		if err := ds.Start(); err != nil {
+			rw.err = g.Error(err, "could not start stream of rejected rows")
+			return
+		}
+
+		if err := rw.write(ds); err != nil {
+			rw.err = g.Error(err, "could not write rejected rows")
+		}
+	}()
+}
+
+// Write pushes the rejected row to the writer. Returns an error if the
+// maximum number of rejects is exceeded, or if writing failed
+func (rw *RejectWriter) Write(rr RejectedRow) (err error) {
+	rw.mux.RLock()
+	defer rw.mux.RUnlock()
+
+	if rw.closed {
+		return g.Error("reject writer is closed")
+	}
+
+	rw.started.Do(rw.start)
+	select {
+	case rw.rows <- rr.Values():
+	case <-rw.done:
+		return g.Error(rw.err, "could not reject row %d from %s", rr.RowNum, rr.Source)
+	}
+
+	if n := rw.count.Add(1); rw.MaxRejects > 0 && n > rw.MaxRejects {
+		return g.Error("exceeded max_rejects (%d). Last rejected row %d from %s: %s", rw.MaxRejects, rr.RowNum, rr.Source, rr.Reason)
+	}
+	return nil
+}
+
+// Count returns the number of rejected rows
+func (rw *RejectWriter) Count() int64 {
+	return rw.count.Load()
+}
+
+// Close closes the stream of rejected rows, waits for the
+// writing to complete and unregisters the writer
+func (rw *RejectWriter) Close() error {
+	rw.mux.Lock()
+	defer rw.mux.Unlock()
+
+	if rw.closed {
+		return rw.err
+	}
+	rw.closed = true
+	rejectWriters.Delete(rw.ID)
+
+	close(rw.rows)
+	if rw.Count() > 0 {
+		<-rw.done
+	}
+	return rw.err
+}
+
+// Reject writes the row into the reject writer of the stream, if set.
+// Returns false if rejects are not enabled
+func (ds *Datastream) Reject(row []any, column, reason string) bool {
+	rw := ds.Sp.Config.Rejects
+	if rw == nil {
+		return false
+	}
+
+	rr := RejectedRow{Source: ds.rejectSource(), Column: column, Reason: reason, Row: row}
+	if col := ds.Columns.GetColumn(ds.Metadata.RowNum.Key); ds.Metadata.RowNum.Key != "" && col != nil && col.Position <= len(row) {
+		rr.RowNum = cast.ToUint64(row[col.Position-1])
+	}
+
+	if err := rw.Write(rr); err != nil {
+		ds.Context.CaptureErr(err)
+	}
+	return true
+}
+
+// rejectSource returns the stream URL, or the stream ID
+func (ds *Datastream) rejectSource() string {
+	if source := cast.ToString(ds.Metadata.StreamURL.Value); source != "" {
+		return source
+	}
+	return ds.ID
+}
+
+// rejectCurrent flags the current row as rejected, if rejects are enabled.
+// Returns false if rejects are not enabled.
+func (sp *StreamProcessor) rejectCurrent(col *Column, reason string, value any) bool {
+	if sp.Config.Rejects == nil {
+		return false
+	} else if sp.rejectPending != nil {
+		return true // first reason is kept
+	}
+
+	rr := RejectedRow{Column: col.Name, Reason: reason, Value: value, RowNum: sp.N}
+	if ds := sp.ds; ds != nil {
+		rr.Source = ds.rejectSource()
+		if ds.it != nil && ds.it.StreamRowNum > 0 {
+			rr.RowNum = ds.it.StreamRowNum
+		}
+	}
+	sp.rejectPending = &rr
+	return true
+}
+
+// collectReject writes the pending rejected row into the reject writer,
+// and captures an error if the maximum number of rejects is exceeded
+func (sp *StreamProcessor) collectReject(row []any) {
+	rr := sp.rejectPending
+	if rr == nil {
+		return
+	}
+	sp.rejectPending = nil
+	sp.skipCurrent = true
+
+	rr.Row = make([]any, len(row))
+	copy(rr.Row, row)
+
+	if err := sp.Config.Rejects.Write(*rr); err != nil && sp.ds != nil {
+		sp.ds.Context.CaptureErr(err)
+	}
+}
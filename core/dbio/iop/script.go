@@ -0,0 +1,332 @@
+package iop
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+	starjson "go.starlark.net/lib/json"
+	starmath "go.starlark.net/lib/math"
+	startime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+// ScriptWorkers is the default number of rows transformed in parallel by a script
+var ScriptWorkers = runtime.NumCPU()
+
+// scriptPredeclared are the modules available to scripts
+var scriptPredeclared = starlark.StringDict{
+	"json": starjson.Module,
+	"math": starmath.Module,
+	"time": startime.Module,
+}
+
+// Script is an embedded Starlark script, whose `transform(row)` function
+// is called with each row as a dict of column name to value. It returns
+// the new row as a dict, with keys added or removed, or None to drop the
+// row. Scripts cannot load modules, nor access the file system or network.
+type Script struct {
+	Name    string
+	Workers int // rows transformed in parallel, output in the input order
+
+	transform *starlark.Function
+}
+
+// scriptField is a key / value of a row returned by a script
+type scriptField struct {
+	name  string
+	value any
+}
+
+// NewScript loads the script source, which defines the `transform` function
+func NewScript(name, source string) (s *Script, err error) {
+	thread := &starlark.Thread{Name: name, Print: scriptPrint}
+	globals, err := starlark.ExecFile(thread, name, source, scriptPredeclared)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return nil, g.Error("could not load script %s\n%s", name, evalErr.Backtrace())
+		}
+		return nil, g.Error(err, "could not load script %s", name)
+	}
+
+	transform, ok := globals["transform"].(*starlark.Function)
+	if !ok {
+		return nil, g.Error("script %s does not define a transform(row) function", name)
+	} else if transform.NumParams() != 1 {
+		return nil, g.Error("transform function of script %s should have one parameter (row)", name)
+	}
+	globals.Freeze() // so the threads can share them
+
+	return &Script{Name: name, Workers: ScriptWorkers, transform: transform}, nil
+}
+
+func scriptPrint(thread *starlark.Thread, msg string) {
+	g.Debug("script %s: %s", thread.Name, msg)
+}
+
+// Run calls the transform function on the rows of ds, in parallel,
+// returning the stream of the transformed rows, in the order of ds.
+// New columns are added as they are returned.
+func (s *Script) Run(ds *Datastream) (nDs *Datastream, err error) {
+	type input struct {
+		seq   int64
+		names []string
+		row   []any
+	}
+	type result struct {
+		seq    int64
+		fields []scriptField // nil when the row is dropped
+	}
+
+	workers := lo.Ternary(s.Workers > 0, s.Workers, 1)
+	inputs := make(chan input, workers*10)
+	results := make(chan result, workers*10)
+	window := make(chan struct{}, workers*100) // rows in flight, to bound the reordering
+	nDs = NewDatastreamContext(ds.Context.Ctx, nil)
+	done := nDs.Context.Ctx.Done()
+
+	// worker calls the function with its own thread
+	var wg sync.WaitGroup
+	worker := func(i int) {
+		defer wg.Done()
+		thread := &starlark.Thread{Name: g.F("%s-%d", s.Name, i), Print: scriptPrint}
+
+		for in := range inputs {
+			if nDs.Context.Err() != nil {
+				continue // drain, so the stream does not block
+			}
+
+			fields, err := s.call(thread, in.names, in.row)
+			if err != nil {
+				nDs.Context.CaptureErr(err)
+			}
+
+			// the consumer may have stopped reading
+			select {
+			case results <- result{seq: in.seq, fields: fields}:
+			case <-done:
+			}
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go worker(i + 1)
+	}
+
+	go func() {
+		var seq int64
+		for batch := range ds.BatchChan {
+			names := batch.Columns.Names()
+			for row := range batch.Rows {
+				select {
+				case window <- struct{}{}:
+					inputs <- input{seq: seq, names: names, row: row}
+					seq++
+				case <-done:
+					// drain, so the stream does not block
+				}
+			}
+		}
+		close(inputs)
+
+		wg.Wait()
+		if err := ds.Err(); err != nil {
+			nDs.Context.CaptureErr(err)
+		}
+		close(results)
+	}()
+
+	colMap := map[string]int{} // lower name => index
+	pending := map[int64][]scriptField{}
+	var next int64
+	nextFunc := func(it *Iterator) bool {
+		for {
+			// emit the results in the input order
+			fields, ok := pending[next]
+			if !ok {
+				res, ok := <-results
+				if !ok {
+					return false
+				}
+				pending[res.seq] = res.fields
+				continue
+			}
+			delete(pending, next)
+			next++
+			<-window
+
+			if fields == nil {
+				continue // dropped
+			}
+
+			row := make([]any, len(nDs.Columns))
+			colsToAdd := Columns{}
+			for _, field := range fields {
+				index, ok := colMap[strings.ToLower(field.name)]
+				if !ok {
+					col := Column{
+						Name:     field.name,
+						Type:     nDs.Sp.GetType(field.value),
+						Position: len(nDs.Columns) + len(colsToAdd) + 1,
+					}
+					colsToAdd = append(colsToAdd, col)
+					row = append(row, nil)
+					index = col.Position - 1
+					colMap[strings.ToLower(field.name)] = index
+				}
+				row[index] = field.value
+			}
+
+			if len(colsToAdd) > 0 {
+				nDs.Context.Mux.Lock()
+				nDs.AddColumns(colsToAdd, false)
+				nDs.Context.Mux.Unlock()
+			}
+
+			it.Row = row
+			return true
+		}
+	}
+
+	nDs.it = nDs.NewIterator(nDs.Columns, nextFunc)
+	if err = nDs.Start(); err != nil {
+		return nil, g.Error(err, "could not run script %s", s.Name)
+	}
+
+	return nDs, nil
+}
+
+// call calls the transform function with a row. It returns the
+// fields of the new row, nil when the row is dropped
+func (s *Script) call(thread *starlark.Thread, names []string, row []any) (fields []scriptField, err error) {
+	dict := starlark.NewDict(len(names))
+	for i, name := range names {
+		var val any
+		if i < len(row) {
+			val = row[i]
+		}
+		if err = dict.SetKey(starlark.String(name), toStarlark(val)); err != nil {
+			return nil, g.Error(err, "could not set value of %s", name)
+		}
+	}
+
+	result, err := starlark.Call(thread, s.transform, starlark.Tuple{dict}, nil)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return nil, g.Error("error in script %s\n%s", s.Name, evalErr.Backtrace())
+		}
+		return nil, g.Error(err, "error in script %s", s.Name)
+	}
+
+	switch result := result.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case *starlark.Dict:
+		for _, item := range result.Items() {
+			name, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, g.Error("transform function of script %s returned a non-string key: %s", s.Name, item[0])
+			}
+			fields = append(fields, scriptField{name: name, value: fromStarlark(item[1])})
+		}
+		return fields, nil
+	default:
+		return nil, g.Error("transform function of script %s should return a dict or None, not %s", s.Name, result.Type())
+	}
+}
+
+// toStarlark converts a row value to a starlark value
+func toStarlark(val any) starlark.Value {
+	switch v := val.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(v)
+	case int:
+		return starlark.MakeInt(v)
+	case int8, int16, int32, int64:
+		return starlark.MakeInt64(cast.ToInt64(v))
+	case uint, uint8, uint16, uint32, uint64:
+		return starlark.MakeUint64(cast.ToUint64(v))
+	case float32:
+		return starlark.Float(v)
+	case float64:
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case []byte:
+		return starlark.Bytes(v)
+	case time.Time:
+		return startime.Time(v)
+	case *time.Time:
+		if v == nil {
+			return starlark.None
+		}
+		return startime.Time(*v)
+	default:
+		return starlark.String(cast.ToString(v))
+	}
+}
+
+// fromStarlark converts a starlark value to a row value. Lists and
+// dicts are JSON strings
+func fromStarlark(val starlark.Value) any {
+	switch v := val.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(v)
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i
+		}
+		return v.String()
+	case starlark.Float:
+		return float64(v)
+	case starlark.String:
+		return string(v)
+	case starlark.Bytes:
+		return []byte(v)
+	case startime.Time:
+		return time.Time(v)
+	case *starlark.List, starlark.Tuple, *starlark.Dict:
+		return g.Marshal(fromStarlarkJSON(v))
+	default:
+		return v.String()
+	}
+}
+
+// fromStarlarkJSON converts a starlark value to a value to marshal
+func fromStarlarkJSON(val starlark.Value) any {
+	switch v := val.(type) {
+	case *starlark.List:
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = fromStarlarkJSON(v.Index(i))
+		}
+		return list
+	case starlark.Tuple:
+		list := make([]any, len(v))
+		for i := range v {
+			list[i] = fromStarlarkJSON(v[i])
+		}
+		return list
+	case *starlark.Dict:
+		m := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				key = item[0].String()
+			}
+			m[key] = fromStarlarkJSON(item[1])
+		}
+		return m
+	default:
+		return fromStarlark(v)
+	}
+}
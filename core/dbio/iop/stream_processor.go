@@ -39,8 +39,19 @@ type StreamProcessor struct {
 	dateLayouts      []string
 	Config           *StreamConfig
 	rowBlankValCnt   int
+	skipCurrent      bool // whether to skip the current row (constraint failure)
+	rejectPending    *RejectedRow
 	transformers     Transformers
 	digitString      map[int]string
+	computed         []ComputedColumn // computed columns, in evaluation order
+	computedIndex    map[int]bool
+	computedReplace  bool  // whether a computed column replaces a source column
+	explode          []int // indexes of the columns to explode into rows
+	lookups          []Lookup
+	where            *Expression    // row filter
+	whereIndexes     []int          // indexes of the values to cast for the row filter
+	whereComputed    bool           // whether the row filter references computed columns
+	distinct         map[int]string // indexes of the columns with distinct values counted
 }
 
 type StreamConfig struct {
@@ -62,10 +73,19 @@ type StreamConfig struct {
 	Jmespath          string                 `json:"jmespath"`
 	BoolAsInt         bool                   `json:"-"`
 	Columns           Columns                `json:"columns"` // list of column types. Can be partial list! likely is!
+	ComputedColumns   []ComputedColumn       `json:"computed_columns"`
+	Lookups           []Lookup               `json:"-"`     // registered lookups, see RegisterLookups
+	Where             string                 `json:"where"` // expression to filter rows with
 	transforms        map[string][]Transform // array of transform functions to apply
 	maxDecimalsFormat string                 `json:"-"`
 
 	Map map[string]string `json:"-"`
+
+	// Rejects receives the rows failing casts or constraints, instead of coercing values
+	Rejects *RejectWriter `json:"-"`
+
+	// Distinct counts the distinct values of columns, into the UniqCnt stats
+	Distinct *DistinctCounter `json:"-"`
 }
 
 type Transformers struct {
@@ -310,6 +330,9 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 	if configMap["skip_blank_lines"] != "" {
 		sp.Config.SkipBlankLines = cast.ToBool(configMap["skip_blank_lines"])
 	}
+	if configMap["reject_writer"] != "" {
+		sp.Config.Rejects = GetRejectWriter(configMap["reject_writer"])
+	}
 	if configMap["bool_at_int"] != "" {
 		sp.Config.BoolAsInt = cast.ToBool(configMap["bool_at_int"])
 	}
@@ -319,6 +342,18 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 	if configMap["transforms"] != "" {
 		sp.applyTransforms(configMap["transforms"])
 	}
+	if configMap["computed_columns"] != "" {
+		g.Unmarshal(configMap["computed_columns"], &sp.Config.ComputedColumns)
+	}
+	if configMap["lookups_id"] != "" {
+		sp.Config.Lookups = GetLookups(configMap["lookups_id"])
+	}
+	if configMap["distinct_counter"] != "" {
+		sp.Config.Distinct = GetDistinctCounter(configMap["distinct_counter"])
+	}
+	if configMap["where"] != "" {
+		sp.Config.Where = configMap["where"]
+	}
 	sp.Config.Compression = configMap["compression"]
 
 	if configMap["datetime_format"] != "" {
@@ -330,6 +365,58 @@ func (sp *StreamProcessor) SetConfig(configMap map[string]string) {
 	}
 }
 
+// ExplodeRow returns a row for each element of the array values of the
+// explode columns. The other values are kept, so the rows can be joined
+// to the parent. Empty arrays yield a single row with a null value
+func (sp *StreamProcessor) ExplodeRow(row []any) (rows [][]any) {
+	rows = [][]any{row}
+	for _, i := range sp.explode {
+		exploded := make([][]any, 0, len(rows))
+		for _, r := range rows {
+			elements, ok := Transforms.jsonArray(r, i)
+			if !ok {
+				exploded = append(exploded, r)
+				continue
+			} else if len(elements) == 0 {
+				elements = []any{nil}
+			}
+
+			for _, element := range elements {
+				newRow := make([]any, len(r))
+				copy(newRow, r)
+				switch element.(type) {
+				case map[string]any, []any:
+					element = g.Marshal(element)
+				}
+				newRow[i] = element
+				exploded = append(exploded, newRow)
+			}
+		}
+		rows = exploded
+	}
+	return rows
+}
+
+// transformedType returns the column type set by the transforms of the
+// column, if changed. Masked values are strings
+func (sp *StreamProcessor) transformedType(col Column) (colType ColumnType) {
+	for _, key := range []string{strings.ToLower(col.Name), "*"} {
+		for _, t := range sp.Config.transforms[key] {
+			switch {
+			case t.masking:
+				colType = StringType
+			case t.colType != "" && (t.FuncString != nil || t.FuncTime != nil):
+				colType = t.colType
+			}
+		}
+	}
+
+	if colType == col.Type || (colType == StringType && col.IsString()) {
+		return ""
+	}
+	return colType
+}
+
 func makeColumnTransforms(transformsPayload string) map[string][]string {
 	columnTransforms := map[string][]string{}
 	g.Unmarshal(transformsPayload, &columnTransforms)
@@ -342,37 +429,112 @@ func (sp *StreamProcessor) applyTransforms(transformsPayload string) {
 	for key, names := range columnTransforms {
 		sp.Config.transforms[key] = []Transform{}
 		for _, name := range names {
-			t, ok := TransformsMap[name]
-			if ok {
-				sp.Config.transforms[key] = append(sp.Config.transforms[key], t)
-			} else if n := strings.TrimSpace(string(name)); strings.Contains(n, "(") && strings.HasSuffix(n, ")") {
-				// parse transform with a parameter
-				parts := strings.Split(string(name), "(")
-				if len(parts) != 2 {
-					g.Warn("invalid transform: '%s'", name)
-					continue
-				}
-				tName := parts[0]
-				param := strings.TrimSuffix(parts[1], ")")
-				if t, ok := TransformsMap[tName]; ok {
-					if t.makeFunc == nil {
-						g.Warn("makeFunc not found for transform '%s'. Please contact support", tName)
+			t, err := ParseTransform(name)
+			if err != nil {
+				g.Warn(err.Error())
+				continue
+			}
+			sp.Config.transforms[key] = append(sp.Config.transforms[key], t)
+		}
+	}
+}
+
+// ParseTransform returns the transform for the name, which may have
+// parameters, e.g. `set_timezone(America/New_York)` or `regex_replace('\s+', ' ')`.
+// When a parameter is invalid, the unparameterized transform is returned along with the error
+func ParseTransform(name string) (Transform, error) {
+	t, ok := TransformsMap[name]
+	if ok {
+		return t, nil
+	}
+
+	n := strings.TrimSpace(string(name))
+	if !strings.Contains(n, "(") || !strings.HasSuffix(n, ")") {
+		return t, g.Error("did not find transform named: '%s'", name)
+	}
+
+	// parse transform with parameters
+	tName, paramsStr, _ := strings.Cut(strings.TrimSuffix(n, ")"), "(")
+	tName = strings.TrimSpace(tName)
+	if t, ok = TransformsMap[tName]; !ok {
+		return t, g.Error("did not find transform with params named: '%s'", tName)
+	} else if t.makeFunc == nil {
+		return t, g.Error("makeFunc not found for transform '%s'. Please contact support", tName)
+	}
+
+	params, err := parseTransformParams(paramsStr)
+	if err != nil {
+		return t, g.Error(err, "invalid transform: '%s'", name)
+	}
+
+	pt := t
+	if err := pt.makeFunc(&pt, params...); err != nil {
+		return t, g.Error("invalid parameter for transform '%s' (%s)", tName, err.Error())
+	}
+	pt.params = params
+	return pt, nil
+}
+
+// parseTransformParams splits the comma-separated parameters of a transform.
+// Parameters can be quoted with single or double quotes, in which case commas
+// and parentheses are kept, and a doubled quote escapes the quote
+func parseTransformParams(paramsStr string) (params []any, err error) {
+	runes := []rune(paramsStr)
+	var sb strings.Builder
+	quoted := false
+
+	addParam := func() {
+		if quoted {
+			params = append(params, sb.String())
+		} else {
+			params = append(params, strings.TrimSpace(sb.String()))
+		}
+		sb.Reset()
+		quoted = false
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case (r == '\'' || r == '"') && strings.TrimSpace(sb.String()) == "" && !quoted:
+			quote := r
+			sb.Reset()
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == quote {
+					if i+1 < len(runes) && runes[i+1] == quote {
+						sb.WriteRune(quote)
+						i++
 						continue
 					}
-					err := t.makeFunc(&t, param)
-					if err != nil {
-						g.Warn("invalid parameter for transform '%s' (%s)", tName, err.Error())
-					} else {
-						sp.Config.transforms[key] = append(sp.Config.transforms[key], t)
-					}
-				} else {
-					g.Warn("did find find transform with params named: '%s'", tName)
+					closed = true
+					break
 				}
-			} else {
-				g.Warn("did find find transform named: '%s'", name)
+				sb.WriteRune(runes[i])
 			}
+			if !closed {
+				return nil, g.Error("unterminated quote in parameters: %s", paramsStr)
+			}
+			quoted = true
+
+			// only spaces are allowed until the next comma
+			for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+				i++
+			}
+			if i+1 < len(runes) && runes[i+1] != ',' {
+				return nil, g.Error("unexpected character after quoted parameter: %s", paramsStr)
+			}
+		case r == ',':
+			addParam()
+		default:
+			sb.WriteRune(r)
 		}
 	}
+
+	if len(params) > 0 || quoted || strings.TrimSpace(sb.String()) != "" {
+		addParam()
+	}
+	return params, nil
 }
 
 // CastVal  casts the type of an interface based on its value
@@ -562,6 +724,13 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 	// get transforms
 	key := strings.ToLower(col.Name)
 	transforms := append(sp.Config.transforms[key], sp.Config.transforms["*"]...)
+	for _, t := range transforms {
+		if t.setNull {
+			cs.TotalCnt++
+			cs.NullCnt++
+			return nil
+		}
+	}
 
 	switch {
 	case col.Type.IsString():
@@ -573,10 +742,19 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 			}
 		}
 
-		// apply transforms
+		// apply transforms. Datetime transforms apply when formatted as string after
+		formatted := false
+		for _, t := range transforms {
+			formatted = formatted || t.Name == TransformFormatDatetime.Name
+		}
 		for _, t := range transforms {
 			if t.FuncString != nil {
 				sVal, _ = t.FuncString(sp, sVal)
+			} else if t.FuncTime != nil && formatted {
+				if tVal, err := sp.CastToTime(sVal); err == nil && !tVal.IsZero() {
+					_ = t.FuncTime(sp, &tVal)
+					sVal = tVal.Format(time.RFC3339Nano)
+				}
 			}
 		}
 
@@ -630,6 +808,9 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 	case col.Type == SmallIntType:
 		iVal, err := cast.ToInt32E(val)
 		if err != nil {
+			if cVal, ok := sp.handleCastError(i, col, cs, val); ok {
+				return cVal
+			}
 			fVal, err := sp.toFloat64E(val)
 			if err != nil || sp.ds == nil {
 				// is string
@@ -661,6 +842,9 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 	case col.Type.IsInteger():
 		iVal, err := cast.ToInt64E(val)
 		if err != nil {
+			if cVal, ok := sp.handleCastError(i, col, cs, val); ok {
+				return cVal
+			}
 			fVal, err := sp.toFloat64E(val)
 			if err != nil || sp.ds == nil {
 				// is string
@@ -696,6 +880,9 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 			cs.NullCnt++
 			return nil
 		} else if err != nil {
+			if cVal, ok := sp.handleCastError(i, col, cs, val); ok {
+				return cVal
+			}
 			// is string
 			sp.ds.ChangeColumn(i, StringType)
 			cs.StringCnt++
@@ -720,6 +907,9 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 			cs.NullCnt++
 			return nil
 		} else if err != nil {
+			if cVal, ok := sp.handleCastError(i, col, cs, val); ok {
+				return cVal
+			}
 			// is string
 			sp.ds.ChangeColumn(i, StringType)
 			cs.StringCnt++
@@ -760,6 +950,9 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 		var err error
 		bVal, err := sp.CastToBool(val)
 		if err != nil {
+			if cVal, ok := sp.handleCastError(i, col, cs, val); ok {
+				return cVal
+			}
 			// is string
 			sp.ds.ChangeColumn(i, StringType)
 			cs.StringCnt++
@@ -774,8 +967,22 @@ func (sp *StreamProcessor) CastVal(i int, val interface{}, col *Column) interfac
 
 		cs.BoolCnt++
 	case col.Type.IsDatetime() || col.Type.IsDate():
+		// apply transforms producing datetime strings, e.g. parse_datetime
+		if _, ok := val.(time.Time); !ok {
+			for _, t := range transforms {
+				if t.FuncString != nil && t.colType != "" {
+					if newVal, err := t.FuncString(sp, cast.ToString(val)); err == nil {
+						val = newVal
+					}
+				}
+			}
+		}
+
 		dVal, err := sp.CastToTime(val)
 		if err != nil {
+			if cVal, ok := sp.handleCastError(i, col, cs, val); ok {
+				return cVal
+			}
 			sp.ds.ChangeColumn(i, StringType)
 			cs.StringCnt++
 			sVal = cast.ToString(val)
@@ -1180,10 +1387,16 @@ func (sp *StreamProcessor) CastRow(row []interface{}, columns Columns) []interfa
 	sp.N++
 	// Ensure usable types
 	sp.rowBlankValCnt = 0
+	sp.skipCurrent = false
 	sp.rowChecksum = make([]uint64, len(row))
 	for i, val := range row {
+		if sp.computedIndex[i] {
+			continue // evaluated after the other values are casted
+		}
+
 		col := &columns[i]
 		row[i] = sp.CastVal(i, val, col)
+		sp.countDistinct(i, row[i])
 
 		// evaluate constraint
 		if col.Constraint != nil {
@@ -1195,6 +1408,18 @@ func (sp *StreamProcessor) CastRow(row []interface{}, columns Columns) []interfa
 		row = append(row, nil)
 	}
 
+	if len(sp.computed) > 0 {
+		row = sp.ComputeRow(row, columns)
+	}
+
+	if len(sp.lookups) > 0 {
+		row = sp.LookupRow(row, columns)
+	}
+
+	if sp.rejectPending != nil {
+		sp.collectReject(row)
+	}
+
 	// debug a row, prev
 	if sp.warn {
 		g.Trace("%s -> %#v", sp.unrecognizedDate, row)
@@ -1204,6 +1429,85 @@ func (sp *StreamProcessor) CastRow(row []interface{}, columns Columns) []interfa
 	return row
 }
 
+// countDistinct adds the casted value to the distinct counter, if the
+// distinct values of the column are counted
+func (sp *StreamProcessor) countDistinct(i int, val any) {
+	if name, ok := sp.distinct[i]; ok && val != nil {
+		sp.colStats[i].UniqCnt = sp.Config.Distinct.Add(name, val)
+	}
+}
+
+// ComputeRow evaluates the computed columns of a row.
+// Values failing evaluation are set to null, with a warning.
+func (sp *StreamProcessor) ComputeRow(row []any, columns Columns) []any {
+	if sp.computedReplace {
+		// keep the source values, in case the row is processed again
+		row = append(make([]any, 0, len(columns)), row...)
+	}
+	for len(row) < len(columns) {
+		row = append(row, nil)
+	}
+	for len(sp.rowChecksum) < len(row) {
+		sp.rowChecksum = append(sp.rowChecksum, 0)
+	}
+
+	for i := range sp.computed {
+		cc := &sp.computed[i]
+		val, err := cc.expr.Eval(row)
+		if err != nil {
+			if !cc.warned {
+				g.Warn("could not evaluate computed column %s: %s", cc.Name, err.Error())
+				cc.warned = true
+			}
+			val = nil
+		}
+
+		col := &columns[cc.index]
+		row[cc.index] = sp.CastVal(cc.index, val, col)
+		sp.countDistinct(cc.index, row[cc.index])
+
+		// evaluate constraint
+		if col.Constraint != nil {
+			col.EvaluateConstraint(row[cc.index], sp)
+		}
+	}
+
+	return row
+}
+
+// MatchesWhere returns whether the row satisfies the `where` filter.
+// It is evaluated before the row is casted, so that the rows filtered out
+// are not counted in the column stats nor rejected. The referenced values
+// are casted without stats, and the computed columns evaluated if referenced.
+// Rows for which the filter is null are dropped.
+func (sp *StreamProcessor) MatchesWhere(row []any, columns Columns) (bool, error) {
+	if sp.where == nil {
+		return true, nil
+	}
+
+	values := make([]any, len(columns))
+	for _, i := range sp.whereIndexes {
+		if i < len(row) { // computed values are evaluated after
+			values[i] = sp.CastValWithoutStats(i, row[i], columns[i].Type)
+		}
+	}
+	if sp.whereComputed {
+		for _, cc := range sp.computed {
+			val, err := cc.expr.Eval(values)
+			if err != nil {
+				val = nil // as with ComputeRow
+			}
+			values[cc.index] = sp.CastValWithoutStats(cc.index, val, columns[cc.index].Type)
+		}
+	}
+
+	val, err := sp.where.Eval(values)
+	if err != nil {
+		return false, g.Error(err, "could not evaluate where filter: %s", sp.where.Text)
+	}
+	return val != nil && exprTruthy(val), nil
+}
+
 // ProcessRow processes a row
 func (sp *StreamProcessor) ProcessRow(row []interface{}) []interface{} {
 	// Ensure usable types
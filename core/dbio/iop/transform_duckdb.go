@@ -0,0 +1,132 @@
+package iop
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/flarco/g"
+	"github.com/flarco/g/csv"
+	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/env"
+	"github.com/spf13/cast"
+)
+
+// TransformSQL runs the DuckDB sql on the rows of ds, available as the view
+// `stream`, and returns the stream of the result. Since the sql may aggregate,
+// sort or join all the rows, they are first written to a temporary CSV file.
+func TransformSQL(ds *Datastream, sql string, props ...string) (nDs *Datastream, err error) {
+	folderPath := path.Join(env.GetTempFolder(), "duckdb", "transform", g.NowFileStr())
+	if err = os.MkdirAll(folderPath, 0755); err != nil {
+		return nil, g.Error(err, "could not create temp folder: %s", folderPath)
+	}
+	filePath := path.Join(folderPath, "stream.csv")
+
+	columns, err := writeDuckDbCsv(ds, filePath)
+	if err != nil {
+		env.RemoveAllLocalTempFile(folderPath)
+		return nil, g.Error(err, "could not write stream to %s", filePath)
+	}
+
+	duck := NewDuckDb(ds.Context.Ctx, props...)
+	cleanUp := func() {
+		duck.Close()
+		env.RemoveAllLocalTempFile(folderPath)
+	}
+
+	colsArr := make([]string, len(columns))
+	for i, col := range columns {
+		nativeType, _ := col.GetNativeType(dbio.TypeDbDuckDb)
+		colsArr[i] = g.F("'%s':'%s'", strings.ReplaceAll(col.Name, "'", "''"), nativeType)
+	}
+
+	viewSQL := g.F(
+		`create view stream as select * from read_csv('%s', delim=',', header=True, columns=%s, max_line_size=134217728, parallel=false, quote='"', escape='"', nullstr='\N')`,
+		filePath, "{"+strings.Join(colsArr, ", ")+"}",
+	)
+	if _, err = duck.Exec(viewSQL + env.NoDebugKey); err != nil {
+		cleanUp()
+		return nil, g.Error(err, "could not create stream view")
+	}
+
+	nDs, err = duck.Stream(sql)
+	if err != nil {
+		cleanUp()
+		return nil, g.Error(err, "could not run transform sql")
+	}
+	nDs.Defer(cleanUp)
+
+	return nDs, nil
+}
+
+// writeDuckDbCsv writes the rows of ds to a CSV file readable by DuckDB,
+// with `\N` for nulls. It returns the columns written
+func writeDuckDbCsv(ds *Datastream, filePath string) (columns Columns, err error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, g.Error(err, "could not create file")
+	}
+	defer file.Close()
+
+	bw := bufio.NewWriter(file)
+	w := csv.NewWriter(bw)
+
+	columns = ds.Columns
+	if _, err = w.Write(columns.Names()); err != nil {
+		return nil, g.Error(err, "could not write header")
+	}
+
+	for batch := range ds.BatchChan {
+		if !batch.Columns.IsSimilarTo(columns) {
+			return nil, g.Error("stream columns changed, which is not supported with transform_sql")
+		}
+
+		for row := range batch.Rows {
+			record := make([]string, len(columns))
+			for i, col := range columns {
+				var val any
+				if i < len(row) {
+					val = row[i]
+				}
+				record[i] = duckDbCsvValue(col, val)
+			}
+			if _, err = w.Write(record); err != nil {
+				return nil, g.Error(err, "could not write row")
+			}
+		}
+	}
+	w.Flush()
+
+	if err = ds.Err(); err != nil {
+		return nil, g.Error(err, "could not read stream")
+	}
+
+	if err = bw.Flush(); err != nil {
+		return nil, g.Error(err, "could not flush file")
+	}
+
+	return columns, nil
+}
+
+// duckDbCsvValue returns the CSV value of a row value for DuckDB
+func duckDbCsvValue(col Column, val any) string {
+	switch v := val.(type) {
+	case nil:
+		return `\N`
+	case time.Time:
+		switch {
+		case col.IsDate():
+			return v.Format(time.DateOnly)
+		case col.Type == TimestampzType:
+			return v.Format("2006-01-02 15:04:05.000000-07:00")
+		default:
+			return v.Format("2006-01-02 15:04:05.000000")
+		}
+	case []byte:
+		return string(v)
+	default:
+		return cast.ToString(v)
+	}
+}
@@ -2,12 +2,18 @@ package iop
 
 import (
 	"bufio"
+	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/sha512"
 	"embed"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"os"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -22,6 +28,8 @@ import (
 var TransformsMap = map[string]Transform{}
 
 func init() {
+	TransformsMap[TransformConvertTz.Name] = TransformConvertTz
+	TransformsMap[TransformDateTrunc.Name] = TransformDateTrunc
 	TransformsMap[TransformDecodeLatin1.Name] = TransformDecodeLatin1
 	TransformsMap[TransformDecodeLatin5.Name] = TransformDecodeLatin5
 	TransformsMap[TransformDecodeLatin9.Name] = TransformDecodeLatin9
@@ -39,20 +47,38 @@ func init() {
 	TransformsMap[TransformEncodeUtf16.Name] = TransformEncodeUtf16
 	TransformsMap[TransformEncodeWindows1250.Name] = TransformEncodeWindows1250
 	TransformsMap[TransformEncodeWindows1252.Name] = TransformEncodeWindows1252
+	TransformsMap[TransformEpochToTimestamp.Name] = TransformEpochToTimestamp
+	TransformsMap[TransformFormatDatetime.Name] = TransformFormatDatetime
+	TransformsMap[TransformExplode.Name] = TransformExplode
 	TransformsMap[TransformHashMd5.Name] = TransformHashMd5
 	TransformsMap[TransformHashSha256.Name] = TransformHashSha256
 	TransformsMap[TransformHashSha512.Name] = TransformHashSha512
+	TransformsMap[TransformJSONExtract.Name] = TransformJSONExtract
+	TransformsMap[TransformMaskCard.Name] = TransformMaskCard
+	TransformsMap[TransformMaskEmail.Name] = TransformMaskEmail
+	TransformsMap[TransformMaskLast.Name] = TransformMaskLast
+	TransformsMap[TransformMaskPhone.Name] = TransformMaskPhone
+	TransformsMap[TransformNullOut.Name] = TransformNullOut
 	TransformsMap[TransformParseBit.Name] = TransformParseBit
+	TransformsMap[TransformParseDatetime.Name] = TransformParseDatetime
 	TransformsMap[TransformParseFix.Name] = TransformParseFix
 	TransformsMap[TransformParseUuid.Name] = TransformParseUuid
 	TransformsMap[TransformParseMsUuid.Name] = TransformParseMsUuid
+	TransformsMap[TransformRegexExtract.Name] = TransformRegexExtract
+	TransformsMap[TransformRegexReplace.Name] = TransformRegexReplace
 	TransformsMap[TransformReplace0x00.Name] = TransformReplace0x00
 	TransformsMap[TransformReplaceAccents.Name] = TransformReplaceAccents
 	TransformsMap[TransformReplaceNonPrintable.Name] = TransformReplaceNonPrintable
 	TransformsMap[TransformTrimSpace.Name] = TransformTrimSpace
 	TransformsMap[TransformSetTimezone.Name] = TransformSetTimezone
+	TransformsMap[TransformSplit.Name] = TransformSplit
+	TransformsMap[TransformTokenize.Name] = TransformTokenize
 }
 
+// TokenizeKeyEnv is the default environment variable holding the key
+// for the `tokenize` transform
+const TokenizeKeyEnv = "SLING_TOKENIZE_KEY"
+
 //go:embed templates/*
 var templatesFolder embed.FS
 
@@ -66,9 +92,66 @@ type Transform struct {
 	FuncString func(*StreamProcessor, string) (string, error)
 	FuncTime   func(*StreamProcessor, *time.Time) error
 	makeFunc   func(t *Transform, params ...any) error
+	masking    bool       // values are masked as strings, whatever the column type
+	setNull    bool       // values are replaced with null
+	colType    ColumnType // column type of the transformed values, if changed
+	params     []any
+}
+
+// IsMasking returns true if the transform masks values
+func (t Transform) IsMasking() bool {
+	return t.masking || t.setNull
 }
 
 var (
+	// TransformConvertTz reads the wall clock time in the `from` time zone,
+	// and converts it to the `to` time zone
+	TransformConvertTz = Transform{
+		Name:    "convert_tz",
+		colType: TimestampzType,
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) != 2 {
+				return g.Error("params for 'convert_tz' should be the from and to IANA Time Zones")
+			}
+			from, err := time.LoadLocation(cast.ToString(params[0]))
+			if err != nil {
+				return g.Error(err, "could not load timezone (%s), should be the a compatible IANA Time Zone", params[0])
+			}
+			to, err := time.LoadLocation(cast.ToString(params[1]))
+			if err != nil {
+				return g.Error(err, "could not load timezone (%s), should be the a compatible IANA Time Zone", params[1])
+			}
+
+			t.FuncTime = func(sp *StreamProcessor, val *time.Time) error {
+				*val = time.Date(
+					val.Year(), val.Month(), val.Day(), val.Hour(),
+					val.Minute(), val.Second(), val.Nanosecond(), from,
+				).In(to)
+				return nil
+			}
+			return nil
+		},
+	}
+
+	TransformDateTrunc = Transform{
+		Name: "date_trunc",
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) != 1 {
+				return g.Error("param for 'date_trunc' should be the unit")
+			}
+			unit := strings.ToLower(cast.ToString(params[0]))
+			if !g.In(unit, "year", "quarter", "month", "week", "day", "hour", "minute", "second") {
+				return g.Error("invalid unit for 'date_trunc': %s. Should be one of year, quarter, month, week, day, hour, minute or second", params[0])
+			}
+
+			t.FuncTime = func(sp *StreamProcessor, val *time.Time) error {
+				*val = Transforms.DateTrunc(*val, unit)
+				return nil
+			}
+			return nil
+		},
+	}
+
 	TransformDecodeLatin1 = Transform{
 		Name: "decode_latin1",
 		FuncString: func(sp *StreamProcessor, val string) (string, error) {
@@ -205,6 +288,62 @@ var (
 		},
 	}
 
+	// TransformEpochToTimestamp converts epoch numbers in the unit
+	// (s, ms, us or ns) to UTC timestamps
+	TransformEpochToTimestamp = Transform{
+		Name:    "epoch_to_timestamp",
+		colType: DatetimeType,
+		FuncString: func(sp *StreamProcessor, val string) (string, error) {
+			return Transforms.EpochToTimestamp(val, "s")
+		},
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) != 1 {
+				return g.Error("param for 'epoch_to_timestamp' should be the unit (s, ms, us or ns)")
+			}
+			unit := strings.ToLower(cast.ToString(params[0]))
+			if _, ok := epochUnits[unit]; !ok {
+				return g.Error("invalid unit for 'epoch_to_timestamp': %s. Should be one of s, ms, us or ns", params[0])
+			}
+
+			t.FuncString = func(sp *StreamProcessor, val string) (string, error) {
+				return Transforms.EpochToTimestamp(val, unit)
+			}
+			return nil
+		},
+	}
+
+	// TransformExplode turns a row with an array into a row per element.
+	// See StreamProcessor.ExplodeRow
+	TransformExplode = Transform{
+		Name: "explode",
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) != 1 || cast.ToString(params[0]) == "" {
+				return g.Error("param for 'explode' should be the column name")
+			}
+			return nil
+		},
+	}
+
+	TransformFormatDatetime = Transform{
+		Name:    "format_datetime",
+		colType: StringType,
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) != 1 || cast.ToString(params[0]) == "" {
+				return g.Error("param for 'format_datetime' should be the format")
+			}
+			layout := Iso8601ToGoLayout(cast.ToString(params[0]))
+
+			t.FuncString = func(sp *StreamProcessor, val string) (string, error) {
+				tVal, err := Transforms.castToTime(sp, val)
+				if err != nil || tVal.IsZero() {
+					return val, err
+				}
+				return tVal.Format(layout), nil
+			}
+			return nil
+		},
+	}
+
 	TransformHashMd5 = Transform{
 		Name: "hash_md5",
 		FuncString: func(sp *StreamProcessor, val string) (string, error) {
@@ -226,6 +365,72 @@ var (
 		},
 	}
 
+	// TransformJSONExtract adds a column with the value at the path of the
+	// JSON value, named `<column>_<path>` unless the name is provided.
+	// See Datastream.transformColumns
+	TransformJSONExtract = Transform{
+		Name: "json_extract",
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) == 0 || len(params) > 2 || len(jsonPathKeys(cast.ToString(params[0]))) == 0 {
+				return g.Error("params for 'json_extract' should be the path (e.g. `$.address.city`), and optionally the column name")
+			} else if len(params) == 2 && cast.ToString(params[1]) == "" {
+				return g.Error("column name for 'json_extract' cannot be blank")
+			}
+			return nil
+		},
+	}
+
+	TransformMaskCard = Transform{
+		Name:    "mask_card",
+		masking: true,
+		FuncString: func(sp *StreamProcessor, val string) (string, error) {
+			return Transforms.MaskDigits(val, 4), nil
+		},
+	}
+
+	TransformMaskEmail = Transform{
+		Name:    "mask_email",
+		masking: true,
+		FuncString: func(sp *StreamProcessor, val string) (string, error) {
+			return Transforms.MaskEmail(val), nil
+		},
+	}
+
+	TransformMaskLast = Transform{
+		Name:    "mask_last",
+		masking: true,
+		FuncString: func(sp *StreamProcessor, val string) (string, error) {
+			return Transforms.MaskLast(val, 4), nil
+		},
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) == 0 {
+				return g.Error("param for 'mask_last' should be the number of characters to mask")
+			}
+			n, err := cast.ToIntE(strings.Trim(strings.TrimSpace(cast.ToString(params[0])), `"'`))
+			if err != nil || n < 1 {
+				return g.Error("param for 'mask_last' should be a positive integer, got: %s", params[0])
+			}
+
+			t.FuncString = func(sp *StreamProcessor, val string) (string, error) {
+				return Transforms.MaskLast(val, n), nil
+			}
+			return nil
+		},
+	}
+
+	TransformMaskPhone = Transform{
+		Name:    "mask_phone",
+		masking: true,
+		FuncString: func(sp *StreamProcessor, val string) (string, error) {
+			return Transforms.MaskDigits(val, 4), nil
+		},
+	}
+
+	TransformNullOut = Transform{
+		Name:    "null_out",
+		setNull: true,
+	}
+
 	TransformParseBit = Transform{
 		Name: "parse_bit",
 		FuncString: func(sp *StreamProcessor, val string) (string, error) {
@@ -233,6 +438,35 @@ var (
 		},
 	}
 
+	// TransformParseDatetime parses the values with the format. The column is
+	// a date, a datetime or a timestampz depending on the format
+	TransformParseDatetime = Transform{
+		Name:    "parse_datetime",
+		colType: DatetimeType,
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) != 1 || cast.ToString(params[0]) == "" {
+				return g.Error("param for 'parse_datetime' should be the format")
+			}
+			layout := Iso8601ToGoLayout(cast.ToString(params[0]))
+
+			switch {
+			case strings.Contains(layout, "Z07") || strings.Contains(layout, "-07") || strings.Contains(layout, "MST"):
+				t.colType = TimestampzType
+			case !strings.Contains(layout, "15") && !strings.Contains(layout, "03") && !strings.Contains(layout, "04"):
+				t.colType = DateType
+			}
+
+			t.FuncString = func(sp *StreamProcessor, val string) (string, error) {
+				tVal, err := time.Parse(layout, strings.TrimSpace(val))
+				if err != nil {
+					return val, g.Error(err, "could not parse datetime with format %s", params[0])
+				}
+				return tVal.Format(time.RFC3339Nano), nil
+			}
+			return nil
+		},
+	}
+
 	TransformParseFix = Transform{
 		Name: "parse_fix",
 		FuncString: func(sp *StreamProcessor, val string) (string, error) {
@@ -254,6 +488,58 @@ var (
 		},
 	}
 
+	TransformRegexExtract = Transform{
+		Name: "regex_extract",
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) == 0 || len(params) > 2 {
+				return g.Error("params for 'regex_extract' should be the pattern, and optionally the group number or name")
+			}
+			re, err := regexp.Compile(cast.ToString(params[0]))
+			if err != nil {
+				return g.Error(err, "invalid pattern for 'regex_extract'")
+			}
+
+			group := 0
+			if len(params) == 2 {
+				groupStr := cast.ToString(params[1])
+				if group, err = cast.ToIntE(groupStr); err != nil {
+					if group = re.SubexpIndex(groupStr); group < 0 {
+						return g.Error("group '%s' not found in pattern for 'regex_extract'", groupStr)
+					}
+				} else if group < 0 || group > re.NumSubexp() {
+					return g.Error("group %d not found in pattern for 'regex_extract'", group)
+				}
+			}
+
+			t.FuncString = func(sp *StreamProcessor, val string) (string, error) {
+				if matches := re.FindStringSubmatch(val); len(matches) > group {
+					return matches[group], nil
+				}
+				return "", nil
+			}
+			return nil
+		},
+	}
+
+	TransformRegexReplace = Transform{
+		Name: "regex_replace",
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) != 2 {
+				return g.Error("params for 'regex_replace' should be the pattern and the replacement")
+			}
+			re, err := regexp.Compile(cast.ToString(params[0]))
+			if err != nil {
+				return g.Error(err, "invalid pattern for 'regex_replace'")
+			}
+			repl := cast.ToString(params[1])
+
+			t.FuncString = func(sp *StreamProcessor, val string) (string, error) {
+				return re.ReplaceAllString(val, repl), nil
+			}
+			return nil
+		},
+	}
+
 	TransformReplace0x00 = Transform{
 		Name: "replace_0x00",
 		FuncString: func(sp *StreamProcessor, val string) (string, error) {
@@ -304,8 +590,64 @@ var (
 			return nil
 		},
 	}
+
+	// TransformSplit adds the columns `<column>_1` to `<column>_N` holding the
+	// parts of the value split by the delimiter (default `,`). N is the second
+	// param, or the max number of parts in the sampled rows.
+	// See Datastream.splitColumns
+	TransformSplit = Transform{
+		Name:   "split",
+		params: []any{","},
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) == 0 || len(params) > 2 || cast.ToString(params[0]) == "" {
+				return g.Error("params for 'split' should be the delimiter, and optionally the number of columns")
+			}
+			if len(params) == 2 {
+				if n, err := cast.ToIntE(params[1]); err != nil || n < 1 {
+					return g.Error("number of columns for 'split' should be a positive integer, got: %s", params[1])
+				}
+			}
+			return nil
+		},
+	}
+
+	TransformTokenize = Transform{
+		Name:       "tokenize",
+		masking:    true,
+		FuncString: makeTokenizeFunc(TokenizeKeyEnv),
+		makeFunc: func(t *Transform, params ...any) error {
+			if len(params) == 0 {
+				return g.Error("param for 'tokenize' should be the name of the environment variable holding the key")
+			}
+			envVar := strings.Trim(strings.TrimSpace(cast.ToString(params[0])), `"'`)
+			if envVar == "" {
+				return g.Error("param for 'tokenize' should be the name of the environment variable holding the key")
+			}
+			t.FuncString = makeTokenizeFunc(envVar)
+			return nil
+		},
+	}
 )
 
+// epochUnits are the nanoseconds per epoch unit
+var epochUnits = map[string]int64{"s": 1e9, "ms": 1e6, "us": 1e3, "ns": 1}
+
+// makeTokenizeFunc returns a tokenize func keyed with the value of envVar.
+// A missing key fails the stream, rather than letting values through
+func makeTokenizeFunc(envVar string) func(*StreamProcessor, string) (string, error) {
+	return func(sp *StreamProcessor, val string) (string, error) {
+		key := os.Getenv(envVar)
+		if key == "" {
+			err := g.Error("key for 'tokenize' transform not found, environment variable %s is not set", envVar)
+			if sp != nil && sp.ds != nil {
+				sp.ds.Context.CaptureErr(err)
+			}
+			return "", err
+		}
+		return Transforms.Tokenize(key, val), nil
+	}
+}
+
 var fixDelimiter string
 
 var fixMapping = map[int]string{}
@@ -520,3 +862,223 @@ func (t transformsNS) ReplaceNonPrintable(val string) string {
 
 	return newVal.String()
 }
+
+// Tokenize returns the hex HMAC-SHA256 of the value keyed with key.
+// The same value and key always yield the same token, so joins still work
+func (t transformsNS) Tokenize(key, val string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(val))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MaskLast replaces the last n characters with `*`
+func (t transformsNS) MaskLast(val string, n int) string {
+	runes := []rune(val)
+	for i := max(len(runes)-n, 0); i < len(runes); i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+// MaskDigits replaces all digits except the last `keep` with `*`,
+// preserving the other characters (e.g. `+1 (555) 123-4567` => `+* (***) ***-4567`).
+// If there are no more digits than `keep`, all are masked
+func (t transformsNS) MaskDigits(val string, keep int) string {
+	digits := 0
+	for _, r := range val {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+	if digits <= keep {
+		keep = 0
+	}
+
+	runes := []rune(val)
+	for i := range runes {
+		if unicode.IsDigit(runes[i]) {
+			if digits > keep {
+				runes[i] = '*'
+			}
+			digits--
+		}
+	}
+	return string(runes)
+}
+
+// MaskEmail keeps the first character of the local part and the domain,
+// masking the rest (e.g. `john.doe@example.com` => `j*******@example.com`)
+func (t transformsNS) MaskEmail(val string) string {
+	local, domain := val, ""
+	if i := strings.LastIndex(val, "@"); i > -1 {
+		local, domain = val[:i], val[i:]
+	}
+
+	runes := []rune(local)
+	for i := 1; i < len(runes); i++ {
+		runes[i] = '*'
+	}
+	return string(runes) + domain
+}
+
+// castToTime parses the value as a time with the stream processor layouts
+func (t transformsNS) castToTime(sp *StreamProcessor, val string) (time.Time, error) {
+	if sp == nil {
+		return cast.ToTimeE(val)
+	}
+	return sp.CastToTime(val)
+}
+
+// EpochToTimestamp converts an epoch number in the unit to a RFC3339 UTC timestamp
+func (t transformsNS) EpochToTimestamp(val, unit string) (string, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return val, nil
+	}
+
+	nanos := epochUnits[unit]
+	if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+		tVal := time.Unix(i/(1e9/nanos), (i%(1e9/nanos))*nanos)
+		return tVal.UTC().Format(time.RFC3339Nano), nil
+	}
+
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return val, g.Error(err, "invalid epoch value: %s", val)
+	}
+	return time.Unix(0, int64(f*float64(nanos))).UTC().Format(time.RFC3339Nano), nil
+}
+
+// DateTrunc truncates the time to the unit, in its time zone. Weeks start on Monday
+func (t transformsNS) DateTrunc(val time.Time, unit string) time.Time {
+	year, month, day := val.Date()
+	switch unit {
+	case "year":
+		return time.Date(year, 1, 1, 0, 0, 0, 0, val.Location())
+	case "quarter":
+		return time.Date(year, ((month-1)/3)*3+1, 1, 0, 0, 0, 0, val.Location())
+	case "month":
+		return time.Date(year, month, 1, 0, 0, 0, 0, val.Location())
+	case "week":
+		return time.Date(year, month, day-(int(val.Weekday())+6)%7, 0, 0, 0, 0, val.Location())
+	case "day":
+		return time.Date(year, month, day, 0, 0, 0, 0, val.Location())
+	case "hour":
+		return time.Date(year, month, day, val.Hour(), 0, 0, 0, val.Location())
+	case "minute":
+		return time.Date(year, month, day, val.Hour(), val.Minute(), 0, 0, val.Location())
+	case "second":
+		return time.Date(year, month, day, val.Hour(), val.Minute(), val.Second(), 0, val.Location())
+	}
+	return val
+}
+
+// jsonPathKeys returns the keys of a JSON path, e.g. `$.items[0].id` => [items 0 id]
+func jsonPathKeys(path string) (keys []string) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.NewReplacer("[", ".", "]", "", `"`, "", "'", "").Replace(path)
+	for _, key := range strings.Split(path, ".") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// parseJSON decodes a JSON value, keeping numbers as json.Number for accuracy
+func (t transformsNS) parseJSON(val any) (doc any, err error) {
+	var payload string
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		// e.g. maps or arrays from mongo, encoded to have generic values
+		bytes, err := json.Marshal(v)
+		if err != nil {
+			return nil, g.Error(err, "could not encode value as json")
+		}
+		payload = string(bytes)
+	}
+
+	if strings.TrimSpace(payload) == "" {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, g.Error(err, "could not decode json value")
+	}
+	return doc, nil
+}
+
+// JSONExtract returns the value at the path of the JSON value, e.g.
+// `$.address.city` or `items[0].id`. Objects and arrays are returned as JSON
+func (t transformsNS) JSONExtract(val any, path string) (any, error) {
+	doc, err := t.parseJSON(val)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range jsonPathKeys(path) {
+		switch v := doc.(type) {
+		case map[string]any:
+			doc = v[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			doc = v[i]
+		default:
+			return nil, nil
+		}
+	}
+
+	switch v := doc.(type) {
+	case map[string]any, []any:
+		return g.Marshal(v), nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return doc, nil
+}
+
+// jsonArray returns the elements of the value at index i, if an array
+func (t transformsNS) jsonArray(row []any, i int) (elements []any, ok bool) {
+	if i >= len(row) || row[i] == nil {
+		return nil, false
+	}
+
+	switch v := row[i].(type) {
+	case []any:
+		return v, true
+	case string, []byte:
+		if s := strings.TrimSpace(cast.ToString(v)); !strings.HasPrefix(s, "[") {
+			return nil, false
+		}
+		doc, err := t.parseJSON(v)
+		if elements, ok = doc.([]any); !ok || err != nil {
+			return nil, false
+		}
+		for j, element := range elements {
+			if number, isNumber := element.(json.Number); isNumber {
+				elements[j] = number.String()
+			}
+		}
+		return elements, true
+	}
+
+	if rv := reflect.ValueOf(row[i]); rv.Kind() == reflect.Slice {
+		elements = make([]any, rv.Len())
+		for j := range elements {
+			elements[j] = rv.Index(j).Interface()
+		}
+		return elements, true
+	}
+	return nil, false
+}
@@ -3,6 +3,7 @@ package iop
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/flarco/g"
 	"github.com/spf13/cast"
@@ -68,3 +69,192 @@ func TestTransformMsUUID(t *testing.T) {
 	val, _ := Transforms.ParseMsUUID(sp, cast.ToString(uuidBytes))
 	assert.Equal(t, "12345678-1234-1234-1234-123456789abc", val)
 }
+
+func TestTransformMasking(t *testing.T) {
+	assert.Equal(t, "j*******@example.com", Transforms.MaskEmail("john.doe@example.com"))
+	assert.Equal(t, "+* (***) ***-4567", Transforms.MaskDigits("+1 (555) 123-4567", 4))
+	assert.Equal(t, "**** **** **** 1111", Transforms.MaskDigits("4111 1111 1111 1111", 4))
+	assert.Equal(t, "***", Transforms.MaskDigits("123", 4))
+	assert.Equal(t, "123-45-****", Transforms.MaskLast("123-45-6789", 4))
+
+	// tokens are deterministic per key
+	token := Transforms.Tokenize("secret", "john.doe@example.com")
+	assert.Len(t, token, 64)
+	assert.Equal(t, token, Transforms.Tokenize("secret", "john.doe@example.com"))
+	assert.NotEqual(t, token, Transforms.Tokenize("other", "john.doe@example.com"))
+
+	tr, err := ParseTransform("mask_last(2)")
+	if assert.NoError(t, err) {
+		val, _ := tr.FuncString(nil, "abcdef")
+		assert.Equal(t, "abcd**", val)
+	}
+
+	tr, err = ParseTransform("mask_last(x)")
+	assert.Error(t, err)
+	assert.True(t, tr.IsMasking())
+
+	os.Setenv("TEST_TOKENIZE_KEY", "secret")
+	tr, err = ParseTransform("tokenize(TEST_TOKENIZE_KEY)")
+	if assert.NoError(t, err) {
+		val, _ := tr.FuncString(nil, "john.doe@example.com")
+		assert.Equal(t, token, val)
+	}
+}
+
+func TestTransformParams(t *testing.T) {
+	params, err := parseTransformParams(`'(\d+), (\w+)', "it's", 2 `)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{`(\d+), (\w+)`, "it's", "2"}, params)
+	}
+
+	params, err = parseTransformParams(`'a''b', ''`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []any{"a'b", ""}, params)
+	}
+
+	_, err = parseTransformParams(`'abc`)
+	assert.Error(t, err)
+
+	tr, err := ParseTransform(`regex_replace('[^0-9]+', '-')`)
+	if assert.NoError(t, err) {
+		val, _ := tr.FuncString(nil, "555 (123) 4567")
+		assert.Equal(t, "555-123-4567", val)
+	}
+
+	tr, err = ParseTransform(`regex_extract('(?P<user>[^@]+)@(.+)', user)`)
+	if assert.NoError(t, err) {
+		val, _ := tr.FuncString(nil, "john@example.com")
+		assert.Equal(t, "john", val)
+	}
+
+	_, err = ParseTransform(`regex_extract('(a)', 2)`)
+	assert.Error(t, err)
+}
+
+func TestTransformSplit(t *testing.T) {
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "tags", Type: StringType},
+	})
+	data.Inferred = true
+	data.Append([]any{int64(1), "a;b"}, []any{int64(2), "c;d;e"})
+
+	transforms := map[string][]string{"tags": {"split(';')"}}
+	ds := data.Stream(map[string]string{"transforms": g.Marshal(transforms)})
+	result, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "tags", "tags_1", "tags_2", "tags_3"}, result.Columns.Names())
+	if assert.Len(t, result.Rows, 2) {
+		assert.Equal(t, []any{"a;b", "a", "b"}, result.Rows[0][1:4])
+		assert.Equal(t, "e", result.Rows[1][4])
+	}
+}
+
+func TestTransformDatetime(t *testing.T) {
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "birth_date", Type: StringType},
+		{Name: "created_at", Type: StringType},
+		{Name: "updated_epoch", Type: BigIntType},
+	})
+	data.Append(
+		[]any{int64(1), "18/10/2026", "2026-10-18 10:30:45", int64(1792060245)},
+		[]any{int64(2), "01/02/2020", "2026-02-28 23:59:59", int64(1582934400)},
+	)
+
+	transforms := map[string][]string{
+		"birth_date":    {"parse_datetime(DD/MM/YYYY)"},
+		"created_at":    {"convert_tz(America/New_York, UTC)", "date_trunc(hour)", "format_datetime('YYYY-MM-DD HH')"},
+		"updated_epoch": {"epoch_to_timestamp(s)"},
+	}
+	ds := data.Stream(map[string]string{"transforms": g.Marshal(transforms)})
+	result, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, DateType, result.Columns[1].Type)
+	assert.Equal(t, StringType, result.Columns[2].Type)
+	assert.Equal(t, DatetimeType, result.Columns[3].Type)
+	if assert.Len(t, result.Rows, 2) {
+		assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), result.Rows[0][1])
+		assert.Equal(t, "2026-10-18 14", result.Rows[0][2])
+		assert.Equal(t, time.Date(2026, 10, 15, 10, 30, 45, 0, time.UTC), result.Rows[0][3])
+	}
+
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), Transforms.DateTrunc(time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC), "week"))
+	assert.Equal(t, time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), Transforms.DateTrunc(time.Date(2026, 8, 18, 10, 30, 0, 0, time.UTC), "quarter"))
+
+	val, err := Transforms.EpochToTimestamp("1700000000123", "ms")
+	assert.NoError(t, err)
+	assert.Equal(t, "2023-11-14T22:13:20.123Z", val)
+}
+
+func TestTransformJSON(t *testing.T) {
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "payload", Type: StringType},
+		{Name: "items", Type: StringType},
+	})
+	data.Append(
+		[]any{int64(1), `{"address": {"city": "Paris"}, "age": 30}`, `[{"sku": "a"}, {"sku": "b"}]`},
+		[]any{int64(2), `{"address": {"city": "Rome"}, "age": 41}`, `[]`},
+	)
+
+	transforms := map[string][]string{
+		"payload": {"json_extract('$.address.city')", "json_extract($.age, age)"},
+		"items":   {"explode"},
+	}
+	ds := data.Stream(map[string]string{"transforms": g.Marshal(transforms)})
+	result, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"id", "payload", "items", "payload_address_city", "age"}, result.Columns.Names())
+	assert.True(t, result.Columns[4].Type.IsInteger())
+	if assert.Len(t, result.Rows, 3) {
+		assert.EqualValues(t, 1, result.Rows[1][0])
+		assert.Equal(t, `{"sku":"b"}`, result.Rows[1][2])
+		assert.Equal(t, "Paris", result.Rows[1][3])
+		assert.EqualValues(t, 2, result.Rows[2][0])
+		assert.Nil(t, result.Rows[2][2])
+		assert.EqualValues(t, 41, cast.ToInt(result.Rows[2][4]))
+	}
+
+	val, err := Transforms.JSONExtract(`{"items": [{"id": 12345678901234567890}]}`, "items[0].id")
+	assert.NoError(t, err)
+	assert.Equal(t, "12345678901234567890", val)
+}
+
+func TestTransformExplodeCasted(t *testing.T) {
+	data := NewDataset(Columns{
+		{Name: "id", Type: BigIntType},
+		{Name: "items", Type: JsonType},
+	})
+	data.Inferred = true
+	data.Append(
+		[]any{int64(1), []any{"a", "b", "c"}},
+		[]any{int64(2), []any{}},
+	)
+
+	ds := data.Stream(map[string]string{"transforms": g.Marshal(map[string][]string{"items": {"explode"}})})
+	assert.True(t, ds.it.IsCasted)
+	result, err := ds.Collect(0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, result.Rows, 4) {
+		assert.Equal(t, []any{"a", "b", "c"}, []any{result.Rows[0][1], result.Rows[1][1], result.Rows[2][1]})
+		assert.EqualValues(t, 1, result.Rows[2][0])
+		assert.EqualValues(t, 2, result.Rows[3][0])
+		assert.Nil(t, result.Rows[3][1])
+	}
+
+	// the source rows are kept
+	assert.Equal(t, []any{"a", "b", "c"}, data.Rows[0][1])
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -71,6 +72,23 @@ var AllColumnCasing = []struct {
 	{SnakeColumnCasing, "SnakeColumnCasing"},
 }
 
+// DedupeMode is the method to remove duplicate primary key records in a batch
+type DedupeMode string
+
+const (
+	DedupeLatest DedupeMode = "latest" // keeps the record with the greatest update key per primary key
+)
+
+// SchemaEvolution is the policy for schema differences between the stream and the target table
+type SchemaEvolution string
+
+const (
+	SchemaEvolutionFail   SchemaEvolution = "fail"   // fails on any new or changed column
+	SchemaEvolutionAdd    SchemaEvolution = "add"    // adds new columns, keeps existing column types
+	SchemaEvolutionWiden  SchemaEvolution = "widen"  // adds new columns and widens column types
+	SchemaEvolutionIgnore SchemaEvolution = "ignore" // drops unknown columns, keeps existing column types
+)
+
 // NewConfig return a config object from a YAML / JSON string
 func NewConfig(cfgStr string) (cfg *Config, err error) {
 	// set default, unmarshalling will overwrite
@@ -140,6 +158,12 @@ func (cfg *Config) SetDefault() {
 		cfg.Target.Options.AdjustColumnType = g.Bool(false)
 	}
 
+	// schema evolution policy takes precedence over add_new_columns & adjust_column_type
+	if se := cfg.Target.Options.SchemaEvolution; se != nil && *se != "" {
+		cfg.Target.Options.AddNewColumns = g.Bool(g.In(*se, SchemaEvolutionAdd, SchemaEvolutionWiden))
+		cfg.Target.Options.AdjustColumnType = g.Bool(*se == SchemaEvolutionWiden)
+	}
+
 	// set max_decimals
 	switch cfg.TgtConn.Type {
 	case dbio.TypeDbBigQuery, dbio.TypeDbBigTable:
@@ -292,7 +316,7 @@ func (cfg *Config) DetermineType() (Type JobType, err error) {
 	g.Trace(summary)
 
 	if cfg.Mode == "" {
-		if cfg.Source.PrimaryKeyI != nil || cfg.Source.UpdateKey != "" {
+		if cfg.Source.PrimaryKeyI != nil || cfg.Source.HasUpdateKey() {
 			cfg.Mode = IncrementalMode
 		} else {
 			cfg.Mode = FullRefreshMode
@@ -305,6 +329,100 @@ func (cfg *Config) DetermineType() (Type JobType, err error) {
 		return
 	}
 
+	if cfg.Source.HasUpdateKeyExpr() {
+		if srcFileProvided {
+			err = g.Error("a list of columns or an expression for 'update_key' is only supported for database sources")
+			return
+		} else if g.In(cfg.SrcConn.Info().Type, dbio.TypeDbMongoDB, dbio.TypeDbBigTable, dbio.TypeDbPrometheus) {
+			err = g.Error("a list of columns or an expression for 'update_key' is not supported for %s sources", cfg.SrcConn.Info().Type)
+			return
+		}
+	}
+
+	if cfg.Target.Options != nil && cfg.Target.Options.Dedupe != nil && *cfg.Target.Options.Dedupe != "" {
+		if dedupe := *cfg.Target.Options.Dedupe; dedupe != DedupeLatest {
+			err = g.Error("invalid value for 'dedupe': %s. Accepted value is 'latest'", dedupe)
+			return
+		} else if len(cfg.Source.PrimaryKey()) == 0 {
+			err = g.Error("must specify value for 'primary_key' when using 'dedupe'")
+			return
+		}
+	}
+
+	if cfg.Target.Options != nil && cfg.Target.Options.SchemaEvolution != nil && *cfg.Target.Options.SchemaEvolution != "" {
+		if se := *cfg.Target.Options.SchemaEvolution; !g.In(se, SchemaEvolutionFail, SchemaEvolutionAdd, SchemaEvolutionWiden, SchemaEvolutionIgnore) {
+			err = g.Error("invalid value for 'schema_evolution': %s. Accepted values are 'fail', 'add', 'widen' and 'ignore'", se)
+			return
+		}
+	}
+
+	if lv, err := cfg.LoadValidation(); err != nil {
+		return Type, err
+	} else if lv.Enabled() && !tgtDbProvided {
+		g.Warn("option 'validate' only applies to database targets, ignoring")
+	}
+
+	if _, err := cfg.DatasetExpectations(); err != nil {
+		return Type, err
+	}
+
+	if _, err := cfg.RowAnomalyCheck(); err != nil {
+		return Type, err
+	}
+
+	if _, err := cfg.ComputedColumns(); err != nil {
+		return Type, err
+	}
+
+	for _, col := range cfg.ColumnsPrepared() {
+		if err := col.OnCastError.Validate(col.Type); err != nil {
+			return Type, g.Error(err, "invalid on_cast_error for column %s", col.Name)
+		}
+	}
+
+	if _, err := cfg.ColumnRenames(); err != nil {
+		return Type, err
+	}
+
+	if _, err := cfg.ColumnOrder(); err != nil {
+		return Type, err
+	}
+
+	if _, err := cfg.ColumnLookups(); err != nil {
+		return Type, err
+	}
+
+	if _, err := cfg.TransformScript(); err != nil {
+		return Type, err
+	}
+
+	if _, err := cfg.TransformPlugin(); err != nil {
+		return Type, err
+	}
+
+	if _, err := cfg.TransformSQLText(); err != nil {
+		return Type, err
+	}
+
+	// transforms must be valid, so values (e.g. to mask) are not let through untransformed
+	for _, names := range cfg.TransformsPrepared() {
+		for _, name := range names {
+			if _, err := iop.ParseTransform(name); err != nil {
+				return Type, g.Error(err, "invalid transform")
+			}
+		}
+	}
+
+	if cfg.Source.Options != nil && cfg.Source.Options.Where != nil {
+		if _, err := iop.ParseExpression(*cfg.Source.Options.Where); err != nil {
+			return Type, g.Error(err, "invalid value for source option 'where'")
+		}
+	}
+
+	if cfg.Target.Options != nil && cfg.Target.Options.SwapTable != nil && *cfg.Target.Options.SwapTable && cfg.Mode != FullRefreshMode {
+		g.Warn("target option 'swap_table' only applies to full-refresh mode, ignoring")
+	}
+
 	if cfg.Mode == IncrementalMode {
 		if cfg.SrcConn.Info().Type == dbio.TypeDbBigTable {
 			// use default keys if none are provided
@@ -417,7 +535,8 @@ func (cfg *Config) AsReplication() (rc ReplicationConfig) {
 			Object:        cfg.Target.Object,
 			Mode:          cfg.Mode,
 			PrimaryKeyI:   cfg.Source.PrimaryKeyI,
-			UpdateKey:     cfg.Source.UpdateKey,
+			UpdateKey:     lo.Ternary(cfg.Source.UpdateKeyExpr != "", cfg.Source.UpdateKeyExpr, cfg.Source.UpdateKey),
+			UpdateKeyCols: cfg.Source.UpdateKeyCols,
 		},
 		Streams: map[string]*ReplicationStreamConfig{
 			cfg.Source.Stream: {},
@@ -601,6 +720,9 @@ func (cfg *Config) Prepare() (err error) {
 	cfg.SrcConn.Data["_source_options_md5"] = g.MD5(g.Marshal(cfg.Source.Options))
 	cfg.TgtConn.Data["_target_options_md5"] = g.MD5(g.Marshal(cfg.Target.Options))
 
+	// resolve update key column
+	cfg.Source.SetUpdateKey()
+
 	// set conn types
 	cfg.Source.Type = cfg.SrcConn.Type
 	cfg.Target.Type = cfg.TgtConn.Type
@@ -964,12 +1086,22 @@ func (cfg *Config) GetFormatMap() (m map[string]any, err error) {
 
 // Config is the new config struct
 type Config struct {
-	Source     Source            `json:"source,omitempty" yaml:"source,omitempty"`
-	Target     Target            `json:"target" yaml:"target"`
-	Mode       Mode              `json:"mode,omitempty" yaml:"mode,omitempty"`
-	Transforms any               `json:"transforms,omitempty" yaml:"transforms,omitempty"`
-	Options    ConfigOptions     `json:"options,omitempty" yaml:"options,omitempty"`
-	Env        map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Source       Source            `json:"source,omitempty" yaml:"source,omitempty"`
+	Target       Target            `json:"target" yaml:"target"`
+	Mode         Mode              `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Transforms   any               `json:"transforms,omitempty" yaml:"transforms,omitempty"`
+	Validate     any               `json:"validate,omitempty" yaml:"validate,omitempty"`
+	Expectations any               `json:"expectations,omitempty" yaml:"expectations,omitempty"`
+	AnomalyCheck any               `json:"anomaly_check,omitempty" yaml:"anomaly_check,omitempty"`
+	Computed     any               `json:"computed_columns,omitempty" yaml:"computed_columns,omitempty"`
+	Rename       any               `json:"rename,omitempty" yaml:"rename,omitempty"`
+	Order        any               `json:"order,omitempty" yaml:"order,omitempty"`
+	Lookups      any               `json:"lookups,omitempty" yaml:"lookups,omitempty"`
+	Script       any               `json:"script,omitempty" yaml:"script,omitempty"`
+	Plugin       any               `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	TransformSQL string            `json:"transform_sql,omitempty" yaml:"transform_sql,omitempty"`
+	Options      ConfigOptions     `json:"options,omitempty" yaml:"options,omitempty"`
+	Env          map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
 
 	StreamName        string                   `json:"stream_name,omitempty" yaml:"stream_name,omitempty"`
 	ReplicationStream *ReplicationStreamConfig `json:"replication_stream,omitempty" yaml:"replication_stream,omitempty"`
@@ -1043,8 +1175,9 @@ func (cfg *Config) ColumnsPrepared() (columns iop.Columns) {
 			g.Warn("Config.Source.Options.Columns not handled: %T", cfg.Source.Options.Columns)
 		}
 
-		// parse constraint, length, precision, scale
+		// parse cast error policy, constraint, length, precision, scale
 		for i := range columns {
+			columns[i].SetCastErrorPolicy()
 			columns[i].SetConstraint()
 			columns[i].SetLengthPrecisionScale()
 		}
@@ -1121,6 +1254,737 @@ func (cfg *Config) TransformsPrepared() (colTransforms map[string][]string) {
 	return
 }
 
+// LoadValidation are the post-load reconciliation checks to run
+type LoadValidation struct {
+	Count    bool   `json:"count"`
+	Checksum bool   `json:"checksum"`
+	OnFail   string `json:"on_fail"` // fail (default) or warn
+}
+
+// Enabled returns true if any check is enabled
+func (lv LoadValidation) Enabled() bool {
+	return lv.Count || lv.Checksum
+}
+
+// LoadValidation parses the `validate` option. Accepts a string (`count`, `checksum`
+// or `count,checksum`), a list, or a map with keys `checks` and `on_fail` (fail / warn).
+func (cfg *Config) LoadValidation() (lv LoadValidation, err error) {
+	var checks []string
+	lv.OnFail = "fail"
+
+	switch val := stringKeyed(cfg.Validate).(type) {
+	case nil:
+		return
+	case string:
+		checks = strings.Split(val, ",")
+	case []string:
+		checks = val
+	case []any:
+		for _, v := range val {
+			checks = append(checks, cast.ToString(v))
+		}
+	case map[string]any, map[string]string:
+		m := g.M()
+		g.Unmarshal(g.Marshal(val), &m)
+		cfgCopy := Config{Validate: m["checks"]}
+		if lv, err = cfgCopy.LoadValidation(); err != nil {
+			return
+		}
+		if onFail := cast.ToString(m["on_fail"]); onFail != "" {
+			lv.OnFail = strings.ToLower(onFail)
+		}
+	default:
+		return lv, g.Error("invalid value for 'validate': %#v", cfg.Validate)
+	}
+
+	for _, check := range checks {
+		switch strings.ToLower(strings.TrimSpace(check)) {
+		case "count":
+			lv.Count = true
+		case "checksum":
+			lv.Checksum = true
+		case "":
+		default:
+			return lv, g.Error("invalid check for 'validate': %s. Accepted values are 'count' and 'checksum'", check)
+		}
+	}
+
+	if !g.In(lv.OnFail, "fail", "warn") {
+		return lv, g.Error("invalid value for 'validate.on_fail': %s. Accepted values are 'fail' and 'warn'", lv.OnFail)
+	}
+
+	return
+}
+
+// ComputedColumns parses the `computed_columns` option. Accepts a map of column
+// name to expression (or to a map with keys `expression` and `type`), or a list
+// of maps with keys `name`, `expression` and `type`. The columns are added in the
+// order of the list, or in name order for a map.
+func (cfg *Config) ComputedColumns() (computed []iop.ComputedColumn, err error) {
+	parseItem := func(name string, item any) (cc iop.ComputedColumn, err error) {
+		cc.Name = name
+		switch v := item.(type) {
+		case string:
+			cc.Expression = v
+		case map[string]any:
+			if n := cast.ToString(v["name"]); n != "" {
+				cc.Name = n
+			}
+			cc.Expression = cast.ToString(lo.Ternary(v["expression"] != nil, v["expression"], v["expr"]))
+			cc.Type = iop.ColumnType(strings.ToLower(cast.ToString(v["type"])))
+		default:
+			return cc, g.Error("invalid computed column: %#v", item)
+		}
+
+		if cc.Name == "" {
+			return cc, g.Error("computed column requires a name: %#v", item)
+		} else if cc.Expression == "" {
+			return cc, g.Error("computed column %s requires an expression", cc.Name)
+		} else if cc.Type != "" && !cc.Type.IsValid() {
+			return cc, g.Error("invalid type for computed column %s: %s", cc.Name, cc.Type)
+		}
+		return
+	}
+
+	switch val := stringKeyed(cfg.Computed).(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		names := lo.Keys(val)
+		sort.Strings(names)
+		for _, name := range names {
+			cc, err := parseItem(name, val[name])
+			if err != nil {
+				return nil, err
+			}
+			computed = append(computed, cc)
+		}
+	case []any:
+		for _, item := range val {
+			cc, err := parseItem("", item)
+			if err != nil {
+				return nil, err
+			}
+			computed = append(computed, cc)
+		}
+	default:
+		return nil, g.Error("invalid value for 'computed_columns': %#v", cfg.Computed)
+	}
+
+	// validates the expressions and references between computed columns
+	if _, err = iop.PrepareComputedColumns(computed); err != nil {
+		return nil, g.Error(err, "invalid value for 'computed_columns'")
+	}
+
+	return computed, nil
+}
+
+// ColumnRenames parses the `rename` option, a map of source column name to new name
+func (cfg *Config) ColumnRenames() (renames map[string]string, err error) {
+	switch val := stringKeyed(cfg.Rename).(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		renames = map[string]string{}
+		newNames := map[string]string{}
+		for name, newName := range val {
+			newNameStr := strings.TrimSpace(cast.ToString(newName))
+			if newNameStr == "" {
+				return nil, g.Error("invalid value for 'rename': no new name for column %s", name)
+			} else if other, ok := newNames[strings.ToLower(newNameStr)]; ok {
+				return nil, g.Error("invalid value for 'rename': columns %s and %s are both renamed to %s", other, name, newNameStr)
+			}
+			newNames[strings.ToLower(newNameStr)] = name
+			renames[name] = newNameStr
+		}
+		return renames, nil
+	}
+	return nil, g.Error("invalid value for 'rename': %#v. Expected a map of column name to new name", cfg.Rename)
+}
+
+// ColumnOrder parses the `order` option, a list of (renamed) column names
+// to put first, in that order. Other columns follow in their original order.
+func (cfg *Config) ColumnOrder() (order []string, err error) {
+	switch val := cfg.Order.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		order = strings.Split(val, ",")
+	case []string:
+		order = val
+	case []any:
+		order = cast.ToStringSlice(val)
+	default:
+		return nil, g.Error("invalid value for 'order': %#v. Expected a list of column names", cfg.Order)
+	}
+
+	order = lo.Map(order, func(name string, i int) string { return strings.TrimSpace(name) })
+	if dups := lo.FindDuplicatesBy(order, strings.ToLower); len(dups) > 0 {
+		return nil, g.Error("invalid value for 'order': duplicate columns %s", strings.Join(dups, ", "))
+	}
+	return order, nil
+}
+
+// TargetColumnName returns the name of a source column after the `rename` mapping
+func (cfg *Config) TargetColumnName(name string) string {
+	renames, _ := cfg.ColumnRenames()
+	for srcName, newName := range renames {
+		if strings.EqualFold(srcName, name) {
+			return newName
+		}
+	}
+	return name
+}
+
+// TargetPrimaryKey returns the primary key columns after the `rename` mapping
+func (cfg *Config) TargetPrimaryKey() []string {
+	return lo.Map(cfg.Source.PrimaryKey(), func(name string, i int) string {
+		return cfg.TargetColumnName(name)
+	})
+}
+
+// TargetUpdateKey returns the update key column after the `rename` mapping
+func (cfg *Config) TargetUpdateKey() string {
+	if cfg.Source.UpdateKey == "" {
+		return ""
+	}
+	return cfg.TargetColumnName(cfg.Source.UpdateKey)
+}
+
+// ColumnLookup is a reference stream loaded in memory, whose columns are
+// appended to the rows with matching keys
+type ColumnLookup struct {
+	Name        string         `json:"name"`
+	Connection  string         `json:"connection"`   // blank for a file path / URL
+	Stream      string         `json:"stream"`       // table, SQL query or file path / URL
+	Keys        []string       `json:"keys"`         // stream key columns
+	RefKeys     []string       `json:"ref_keys"`     // reference key columns, matching Keys
+	Columns     []string       `json:"columns"`      // reference columns to append
+	Names       []string       `json:"names"`        // names of the appended columns
+	Default     map[string]any `json:"default"`      // values for unmatched keys, by appended column name
+	OnUnmatched string         `json:"on_unmatched"` // default (default) or reject
+}
+
+// ColumnLookups parses the `lookups` option, a list (or a map by name) of
+// lookups with keys `connection`, `stream`, `keys`, `columns`, `default`
+// and `on_unmatched`. `keys` is a column name, a list of column names or a
+// map of stream column to reference column. `columns` is a column name, a list
+// of column names or a map of reference column to new column name.
+func (cfg *Config) ColumnLookups() (lookups []ColumnLookup, err error) {
+	items := []map[string]any{}
+	switch val := stringKeyed(cfg.Lookups).(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		for _, item := range val {
+			itemMap, ok := item.(map[string]any)
+			if !ok {
+				return nil, g.Error("invalid value for 'lookups': %#v. Expected a map", item)
+			}
+			items = append(items, itemMap)
+		}
+	case map[string]any:
+		names := lo.Keys(val)
+		sort.Strings(names)
+		for _, name := range names {
+			itemMap, ok := val[name].(map[string]any)
+			if !ok {
+				return nil, g.Error("invalid value for lookup %s: %#v. Expected a map", name, val[name])
+			}
+			if _, ok := itemMap["name"]; !ok {
+				itemMap["name"] = name
+			}
+			items = append(items, itemMap)
+		}
+	default:
+		return nil, g.Error("invalid value for 'lookups': %#v. Expected a list", cfg.Lookups)
+	}
+
+	names := map[string]string{} // appended column => lookup
+	for i, item := range items {
+		lookup := ColumnLookup{
+			Name:        strings.TrimSpace(cast.ToString(item["name"])),
+			Connection:  strings.TrimSpace(cast.ToString(item["connection"])),
+			Stream:      strings.TrimSpace(cast.ToString(item["stream"])),
+			OnUnmatched: strings.ToLower(strings.TrimSpace(cast.ToString(item["on_unmatched"]))),
+		}
+		if lookup.Name == "" {
+			lookup.Name = g.F("lookup_%d", i+1)
+		}
+		if lookup.OnUnmatched == "" {
+			lookup.OnUnmatched = "default"
+		}
+
+		if lookup.Keys, lookup.RefKeys, err = lookupColumnPairs(item["keys"]); err != nil {
+			return nil, g.Error(err, "invalid value for 'keys' of lookup %s", lookup.Name)
+		}
+		if lookup.Columns, lookup.Names, err = lookupColumnPairs(item["columns"]); err != nil {
+			return nil, g.Error(err, "invalid value for 'columns' of lookup %s", lookup.Name)
+		}
+
+		if v, ok := item["default"]; ok {
+			if lookup.Default, ok = v.(map[string]any); !ok {
+				return nil, g.Error("invalid value for 'default' of lookup %s: %#v. Expected a map of column name to value", lookup.Name, v)
+			}
+		}
+
+		switch {
+		case lookup.Stream == "":
+			return nil, g.Error("lookup %s has no stream (table, SQL query or file path)", lookup.Name)
+		case len(lookup.Keys) == 0:
+			return nil, g.Error("lookup %s has no keys", lookup.Name)
+		case len(lookup.Columns) == 0:
+			return nil, g.Error("lookup %s has no columns", lookup.Name)
+		case !g.In(lookup.OnUnmatched, "default", "reject"):
+			return nil, g.Error("invalid value for 'on_unmatched' of lookup %s: %s. Accepted values are 'default' and 'reject'", lookup.Name, lookup.OnUnmatched)
+		}
+
+		for _, name := range lookup.Names {
+			if other, ok := names[strings.ToLower(name)]; ok {
+				return nil, g.Error("column %s is appended by lookups %s and %s", name, other, lookup.Name)
+			}
+			names[strings.ToLower(name)] = lookup.Name
+		}
+		for name := range lookup.Default {
+			if !lo.ContainsBy(lookup.Names, func(n string) bool { return strings.EqualFold(n, name) }) {
+				return nil, g.Error("default value of lookup %s is for column %s, which is not appended", lookup.Name, name)
+			}
+		}
+
+		lookups = append(lookups, lookup)
+	}
+
+	return lookups, nil
+}
+
+// lookupColumnPairs parses a column name, a list of column names (mapped to
+// themselves) or a map of column names (sorted)
+func lookupColumnPairs(value any) (from, to []string, err error) {
+	switch val := value.(type) {
+	case nil:
+		return nil, nil, nil
+	case string:
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				from, to = append(from, name), append(to, name)
+			}
+		}
+	case []any:
+		for _, v := range val {
+			name := strings.TrimSpace(cast.ToString(v))
+			if name == "" {
+				return nil, nil, g.Error("blank column name")
+			}
+			from, to = append(from, name), append(to, name)
+		}
+	case map[string]any:
+		names := lo.Keys(val)
+		sort.Strings(names)
+		for _, name := range names {
+			target := strings.TrimSpace(cast.ToString(val[name]))
+			if target == "" {
+				return nil, nil, g.Error("blank column name for %s", name)
+			}
+			from, to = append(from, name), append(to, target)
+		}
+	default:
+		return nil, nil, g.Error("expected a column name, a list or a map: %#v", value)
+	}
+	return from, to, nil
+}
+
+// TransformScript parses and loads the `script` option, a Starlark script
+// defining a `transform(row)` function. It is the path of the script file,
+// its source, or a map with keys `path` (or `source`) and `workers`.
+func (cfg *Config) TransformScript() (script *iop.Script, err error) {
+	item := map[string]any{}
+	switch val := stringKeyed(cfg.Script).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.Contains(val, "\n") {
+			item["source"] = val
+		} else {
+			item["path"] = val
+		}
+	case map[string]any:
+		item = val
+	default:
+		return nil, g.Error("invalid value for 'script': %#v. Expected a file path or a map", cfg.Script)
+	}
+
+	name := "script"
+	source := cast.ToString(item["source"])
+	if path := strings.TrimSpace(cast.ToString(item["path"])); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, g.Error(err, "could not read script file: %s", path)
+		}
+		name, source = path, string(content)
+	}
+	if strings.TrimSpace(source) == "" {
+		return nil, g.Error("invalid value for 'script': no path or source")
+	}
+
+	if script, err = iop.NewScript(name, source); err != nil {
+		return nil, err
+	}
+
+	if v, ok := item["workers"]; ok {
+		if script.Workers, err = cast.ToIntE(v); err != nil || script.Workers <= 0 {
+			return nil, g.Error("invalid value for 'workers' of script: %#v. Expected a positive integer", v)
+		}
+	}
+
+	return script, nil
+}
+
+// TransformPlugin parses the `plugin` option, the external process the
+// stream rows are piped through. It is a command line, a list of command
+// arguments, or a map with keys `command`, `format` (jsonlines or arrow),
+// `batch_size` and `env`.
+func (cfg *Config) TransformPlugin() (plugin *iop.Plugin, err error) {
+	item := map[string]any{}
+	switch val := stringKeyed(cfg.Plugin).(type) {
+	case nil:
+		return nil, nil
+	case string, []any:
+		item["command"] = val
+	case map[string]any:
+		item = val
+	default:
+		return nil, g.Error("invalid value for 'plugin': %#v. Expected a command or a map", cfg.Plugin)
+	}
+
+	plugin = &iop.Plugin{
+		Format: iop.PluginFormat(strings.ToLower(strings.TrimSpace(cast.ToString(item["format"])))),
+		Env:    map[string]string{},
+	}
+
+	switch command := item["command"].(type) {
+	case string:
+		plugin.Command = strings.Fields(command)
+	case []any:
+		plugin.Command = cast.ToStringSlice(command)
+	}
+	if len(plugin.Command) == 0 || plugin.Command[0] == "" {
+		return nil, g.Error("invalid value for 'plugin': no command")
+	}
+
+	if plugin.Format == "" {
+		plugin.Format = iop.PluginFormatJsonLines
+	} else if !g.In(plugin.Format, iop.PluginFormatJsonLines, iop.PluginFormatArrow) {
+		return nil, g.Error("invalid value for 'format' of plugin: %s. Accepted values are 'jsonlines' and 'arrow'", plugin.Format)
+	}
+
+	if v, ok := item["batch_size"]; ok {
+		if plugin.BatchSize, err = cast.ToIntE(v); err != nil || plugin.BatchSize <= 0 {
+			return nil, g.Error("invalid value for 'batch_size' of plugin: %#v. Expected a positive integer", v)
+		}
+	}
+
+	if v, ok := item["env"]; ok {
+		env, ok := v.(map[string]any)
+		if !ok {
+			return nil, g.Error("invalid value for 'env' of plugin: %#v. Expected a map", v)
+		}
+		for key, value := range env {
+			plugin.Env[key] = cast.ToString(value)
+		}
+	}
+
+	return plugin, nil
+}
+
+// TransformSQLText returns the DuckDB SQL of the `transform_sql` option,
+// which selects from the `stream` view. It is the SQL text or a file path.
+func (cfg *Config) TransformSQLText() (sql string, err error) {
+	if strings.TrimSpace(cfg.TransformSQL) == "" {
+		return "", nil
+	}
+
+	sql, err = GetSQLText(strings.TrimSpace(cfg.TransformSQL))
+	if err != nil {
+		return "", g.Error(err, "could not get SQL of 'transform_sql'")
+	}
+
+	sql = strings.TrimSuffix(strings.TrimSpace(sql), ";")
+	if sql == "" {
+		return "", g.Error("invalid value for 'transform_sql': empty SQL")
+	}
+	return sql, nil
+}
+
+// RowAnomalyCheck compares the row count of the run with the history of the stream
+type RowAnomalyCheck struct {
+	Enabled    bool    `json:"enabled"`
+	Method     string  `json:"method"`      // mad (default) or pct_change
+	Threshold  float64 `json:"threshold"`   // number of MADs (default 3), or percent change (default 50)
+	History    int     `json:"history"`     // number of previous successful runs to compare with (default 10)
+	MinHistory int     `json:"min_history"` // minimum number of previous runs required (default 3)
+	Severity   string  `json:"severity"`    // warn (default) or fail
+}
+
+// RowAnomalyCheck parses the `anomaly_check` option. Accepts a boolean, a method
+// name (`mad` or `pct_change`), or a map with keys `method`, `threshold`,
+// `history`, `min_history` and `severity`.
+func (cfg *Config) RowAnomalyCheck() (ac RowAnomalyCheck, err error) {
+	ac = RowAnomalyCheck{Method: "mad", History: 10, MinHistory: 3, Severity: "warn"}
+
+	switch val := stringKeyed(cfg.AnomalyCheck).(type) {
+	case nil:
+		return
+	case bool:
+		ac.Enabled = val
+	case string:
+		if ac.Enabled, err = cast.ToBoolE(val); err != nil {
+			ac.Enabled, ac.Method, err = true, strings.ToLower(val), nil
+		}
+	case map[string]any:
+		ac.Enabled = true
+		if v, ok := val["enabled"]; ok {
+			ac.Enabled = cast.ToBool(v)
+		}
+		if v := cast.ToString(val["method"]); v != "" {
+			ac.Method = strings.ToLower(v)
+		}
+		if v, ok := val["threshold"]; ok {
+			ac.Threshold = cast.ToFloat64(v)
+		}
+		if v, ok := val["history"]; ok {
+			ac.History = cast.ToInt(v)
+		}
+		if v, ok := val["min_history"]; ok {
+			ac.MinHistory = cast.ToInt(v)
+		}
+		if v := cast.ToString(val["severity"]); v != "" {
+			ac.Severity = strings.ToLower(v)
+		}
+	default:
+		return ac, g.Error("invalid value for 'anomaly_check': %#v", cfg.AnomalyCheck)
+	}
+
+	if ac.Threshold == 0 {
+		ac.Threshold = lo.Ternary(ac.Method == "pct_change", 50.0, 3.0)
+	}
+
+	switch {
+	case !g.In(ac.Method, "mad", "pct_change"):
+		err = g.Error("invalid value for 'anomaly_check.method': %s. Accepted values are 'mad' and 'pct_change'", ac.Method)
+	case !g.In(ac.Severity, "warn", "fail"):
+		err = g.Error("invalid value for 'anomaly_check.severity': %s. Accepted values are 'warn' and 'fail'", ac.Severity)
+	case ac.Threshold < 0:
+		err = g.Error("invalid value for 'anomaly_check.threshold': %v. Must be positive", ac.Threshold)
+	case ac.MinHistory < 1 || ac.History < ac.MinHistory:
+		err = g.Error("invalid values for 'anomaly_check': history (%d) must be at least min_history (%d), which must be at least 1", ac.History, ac.MinHistory)
+	}
+
+	return
+}
+
+// stringKeyed converts the nested map[any]any values from yaml into map[string]any
+func stringKeyed(value any) any {
+	switch val := value.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(val))
+		for k, v := range val {
+			m[cast.ToString(k)] = stringKeyed(v)
+		}
+		return m
+	case map[string]any:
+		m := make(map[string]any, len(val))
+		for k, v := range val {
+			m[k] = stringKeyed(v)
+		}
+		return m
+	case []any:
+		list := make([]any, len(val))
+		for i, v := range val {
+			list[i] = stringKeyed(v)
+		}
+		return list
+	}
+	return value
+}
+
+// ExpectationType is the type of a dataset expectation
+type ExpectationType string
+
+const (
+	ExpectUnique         ExpectationType = "unique"
+	ExpectNotNull        ExpectationType = "not_null"
+	ExpectRowCount       ExpectationType = "row_count"
+	ExpectFreshness      ExpectationType = "freshness"
+	ExpectAcceptedValues ExpectationType = "accepted_values"
+)
+
+// Expectation is a dataset-level expectation, evaluated after the load
+type Expectation struct {
+	Type     ExpectationType `json:"type"`
+	Columns  []string        `json:"columns,omitempty"`
+	Min      *int64          `json:"min,omitempty"`
+	Max      *int64          `json:"max,omitempty"`
+	MaxAge   time.Duration   `json:"max_age,omitempty"`
+	Values   []string        `json:"values,omitempty"`
+	Severity string          `json:"severity"` // fail (default) or warn
+}
+
+// Name returns the expectation name, such as `unique(id)`
+func (e Expectation) Name() string {
+	if len(e.Columns) > 0 {
+		return g.F("%s(%s)", e.Type, strings.Join(e.Columns, ", "))
+	}
+	return string(e.Type)
+}
+
+// DatasetExpectations parses the `expectations` option. Accepts a map keyed by
+// expectation type, or a list of such maps (to repeat a type). The value is a
+// column list (`unique: [id]`) or a map of settings with an optional `severity`.
+func (cfg *Config) DatasetExpectations() (expectations []Expectation, err error) {
+	if cfg.Expectations == nil {
+		return
+	}
+
+	var items []map[string]any
+	switch val := stringKeyed(cfg.Expectations).(type) {
+	case map[string]any:
+		// a top-level severity is the default for all
+		keys := lo.Without(lo.Keys(val), "severity")
+		sort.Strings(keys)
+		for _, key := range keys {
+			items = append(items, map[string]any{key: val[key], "severity": val["severity"]})
+		}
+	case []any:
+		for _, item := range val {
+			m, ok := item.(map[string]any)
+			if !ok {
+				return nil, g.Error("invalid value for 'expectations': %#v", item)
+			}
+			items = append(items, m)
+		}
+	default:
+		return nil, g.Error("invalid value for 'expectations': %#v", cfg.Expectations)
+	}
+
+	for _, item := range items {
+		severity := cast.ToString(item["severity"])
+		for key, val := range item {
+			if key == "severity" {
+				continue
+			}
+			e, err := parseExpectation(ExpectationType(strings.ToLower(key)), val, severity)
+			if err != nil {
+				return nil, g.Error(err, "invalid expectation: %s", key)
+			}
+			expectations = append(expectations, e)
+		}
+	}
+
+	return
+}
+
+// DistinctColumns returns the columns of the single-column `unique`
+// expectations, whose distinct values are counted while streaming
+func (cfg *Config) DistinctColumns() (columns []string) {
+	expectations, _ := cfg.DatasetExpectations()
+	for _, e := range expectations {
+		if e.Type == ExpectUnique && len(e.Columns) == 1 {
+			columns = append(columns, e.Columns[0])
+		}
+	}
+	return lo.Uniq(columns)
+}
+
+func parseExpectation(eType ExpectationType, value any, severity string) (e Expectation, err error) {
+	e = Expectation{Type: eType, Severity: "fail"}
+
+	toList := func(val any) (list []string) {
+		switch v := val.(type) {
+		case nil:
+		case string:
+			for _, part := range strings.Split(v, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					list = append(list, part)
+				}
+			}
+		case []any:
+			for _, item := range v {
+				list = append(list, cast.ToString(item))
+			}
+		default:
+			list = []string{cast.ToString(v)}
+		}
+		return
+	}
+
+	settings, isMap := value.(map[string]any)
+	if !isMap {
+		settings = g.M()
+	}
+	if val := cast.ToString(settings["severity"]); val != "" {
+		severity = val
+	}
+	if severity != "" {
+		e.Severity = strings.ToLower(severity)
+	}
+
+	switch eType {
+	case ExpectUnique, ExpectNotNull:
+		if isMap {
+			e.Columns = toList(lo.Ternary(settings["columns"] != nil, settings["columns"], settings["column"]))
+		} else {
+			e.Columns = toList(value)
+		}
+		if len(e.Columns) == 0 {
+			return e, g.Error("must provide columns for '%s'", eType)
+		}
+	case ExpectRowCount:
+		if !isMap {
+			return e, g.Error("'row_count' must be a map with keys 'min' and/or 'max'")
+		}
+		if val, ok := settings["min"]; ok {
+			e.Min = g.Int64(cast.ToInt64(val))
+		}
+		if val, ok := settings["max"]; ok {
+			e.Max = g.Int64(cast.ToInt64(val))
+		}
+		if e.Min == nil && e.Max == nil {
+			return e, g.Error("must provide 'min' and/or 'max' for 'row_count'")
+		}
+	case ExpectFreshness:
+		if !isMap {
+			return e, g.Error("'freshness' must be a map with keys 'column' and 'max_age'")
+		}
+		e.Columns = toList(settings["column"])
+		if len(e.Columns) != 1 {
+			return e, g.Error("must provide one 'column' for 'freshness'")
+		}
+		if e.MaxAge, err = parseLookbackDuration(cast.ToString(settings["max_age"])); err != nil {
+			return e, g.Error(err, "invalid 'max_age' for 'freshness'")
+		}
+	case ExpectAcceptedValues:
+		if !isMap {
+			return e, g.Error("'accepted_values' must be a map with keys 'column' and 'values'")
+		}
+		e.Columns = toList(settings["column"])
+		if len(e.Columns) != 1 {
+			return e, g.Error("must provide one 'column' for 'accepted_values'")
+		}
+		e.Values = toList(settings["values"])
+		if len(e.Values) == 0 {
+			return e, g.Error("must provide 'values' for 'accepted_values'")
+		}
+	default:
+		return e, g.Error("unknown expectation type: %s. Accepted values are 'unique', 'not_null', 'row_count', 'freshness' and 'accepted_values'", eType)
+	}
+
+	if !g.In(e.Severity, "fail", "warn") {
+		return e, g.Error("invalid severity for '%s': %s. Accepted values are 'fail' and 'warn'", eType, e.Severity)
+	}
+
+	return
+}
+
 // Value return json value, implement driver.Valuer interface
 func (cfg Config) Value() (driver.Value, error) {
 	jBytes, err := json.Marshal(cfg)
@@ -1184,10 +2048,71 @@ type Source struct {
 	Select      []string       `json:"select,omitempty" yaml:"select,omitempty"` // Select or exclude columns. Exclude with prefix "-".
 	SQL         string         `json:"sql,omitempty" yaml:"sql,omitempty"`
 	PrimaryKeyI any            `json:"primary_key,omitempty" yaml:"primary_key,omitempty"`
-	UpdateKey   string         `json:"update_key,omitempty" yaml:"update_key,omitempty"`
+	UpdateKey   string         `json:"update_key,omitempty" yaml:"update_key,omitempty"` // column name or SQL expression
 	Options     *SourceOptions `json:"options,omitempty" yaml:"options,omitempty"`
 
-	Data map[string]interface{} `json:"-" yaml:"-"`
+	UpdateKeyCols []string               `json:"-" yaml:"-"` // when update_key is a list of columns
+	UpdateKeyExpr string                 `json:"-" yaml:"-"` // when update_key is a SQL expression
+	Data          map[string]interface{} `json:"-" yaml:"-"`
+}
+
+// UnmarshalJSON accepts a column name, a list of columns or a SQL expression as update_key
+func (s *Source) UnmarshalJSON(data []byte) error {
+	type source Source
+	aux := struct {
+		*source
+		UpdateKey any `json:"update_key,omitempty"`
+	}{source: (*source)(s)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	s.UpdateKey, s.UpdateKeyCols = parseUpdateKey(aux.UpdateKey)
+	return nil
+}
+
+// UnmarshalYAML accepts a column name, a list of columns or a SQL expression as update_key
+func (s *Source) UnmarshalYAML(unmarshal func(any) error) error {
+	// decode update_key separately, since it may be a list
+	items := yaml.MapSlice{}
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+
+	var updateKey any
+	for i, item := range items {
+		if cast.ToString(item.Key) == "update_key" {
+			updateKey = item.Value
+			items = append(items[:i], items[i+1:]...)
+			break
+		}
+	}
+
+	content, err := yaml.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	type source Source
+	if err = yaml.Unmarshal(content, (*source)(s)); err != nil {
+		return err
+	}
+	s.UpdateKey, s.UpdateKeyCols = parseUpdateKey(updateKey)
+	return nil
+}
+
+// MarshalJSON writes update_key as provided
+func (s Source) MarshalJSON() ([]byte, error) {
+	type source Source
+	updateKey := updateKeyValue(s.UpdateKey, s.UpdateKeyCols)
+	if s.UpdateKeyExpr != "" {
+		updateKey = s.UpdateKeyExpr
+	}
+
+	return json.Marshal(struct {
+		source
+		UpdateKey any `json:"update_key,omitempty"`
+	}{source: source(s), UpdateKey: updateKey})
 }
 
 func (s *Source) Limit() int {
@@ -1212,6 +2137,86 @@ func (s *Source) HasUpdateKey() bool {
 	return s.UpdateKey != ""
 }
 
+// HasUpdateKeyExpr returns true if the update key is a list of columns
+// or a SQL expression, which is materialized into the `_sling_update_key` column
+func (s *Source) HasUpdateKeyExpr() bool {
+	return len(s.UpdateKeyCols) > 1 || s.UpdateKeyExpr != "" || isSQLExpression(s.UpdateKey)
+}
+
+// SetUpdateKey resolves the update key column name. A list of columns or
+// a SQL expression is kept aside, and materialized as `_sling_update_key`
+func (s *Source) SetUpdateKey() {
+	if isSQLExpression(s.UpdateKey) {
+		s.UpdateKeyExpr = strings.TrimSpace(s.UpdateKey)
+	} else if len(s.UpdateKeyCols) == 1 {
+		s.UpdateKey = s.UpdateKeyCols[0]
+	}
+
+	if s.HasUpdateKeyExpr() {
+		s.UpdateKey = slingUpdateKeyColumn
+	}
+}
+
+// UpdateKeySQL returns the SQL expression to compute the update key on the source.
+// A list of columns is rendered as the greatest non-null value of the columns.
+func (s *Source) UpdateKeySQL(conn database.Connection) string {
+	cols := s.UpdateKeyCols
+	if s.UpdateKeyExpr != "" {
+		return s.UpdateKeyExpr
+	} else if isSQLExpression(s.UpdateKey) {
+		return strings.TrimSpace(s.UpdateKey)
+	} else if len(cols) == 0 {
+		return lo.Ternary(s.UpdateKey == "", "", conn.Quote(s.UpdateKey, false))
+	} else if len(cols) == 1 {
+		return conn.Quote(cols[0], false)
+	}
+
+	quoted := lo.Map(cols, func(col string, i int) string {
+		return conn.Quote(col, false)
+	})
+
+	// rotate the coalesce arguments so that null values are skipped
+	args := make([]string, len(quoted))
+	values := make([]string, len(quoted))
+	for i := range quoted {
+		rotated := append(append([]string{}, quoted[i:]...), quoted[:i]...)
+		args[i] = g.F("coalesce(%s)", strings.Join(rotated, ", "))
+		values[i] = "(" + args[i] + ")"
+	}
+
+	return g.R(
+		conn.GetTemplateValue("function.greatest"),
+		"fields", strings.Join(args, ", "),
+		"values", strings.Join(values, ", "),
+	)
+}
+
+// parseUpdateKey returns the update_key column name or SQL expression,
+// or the columns when a list is provided
+func parseUpdateKey(val any) (key string, cols []string) {
+	switch val.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return cast.ToString(val), nil
+	}
+
+	cols = castKeyArray(val)
+	if len(cols) == 1 {
+		return cols[0], nil
+	}
+	return "", cols
+}
+
+// updateKeyValue returns the update_key value to marshal
+func updateKeyValue(key string, cols []string) any {
+	if len(cols) > 1 {
+		return cols
+	} else if key != "" {
+		return key
+	}
+	return nil
+}
 func (s *Source) HasPrimaryKey() bool {
 	return strings.Join(s.PrimaryKey(), "") != ""
 }
@@ -1282,6 +2287,8 @@ type SourceOptions struct {
 	Range          *string             `json:"range,omitempty" yaml:"range,omitempty"`
 	Limit          *int                `json:"limit,omitempty" yaml:"limit,omitempty"`
 	Offset         *int                `json:"offset,omitempty" yaml:"offset,omitempty"`
+	Lookback       *string             `json:"lookback,omitempty" yaml:"lookback,omitempty"`
+	Where          *string             `json:"where,omitempty" yaml:"where,omitempty"`
 
 	// columns & transforms were moved out of source_options
 	// https://github.com/slingdata-io/sling-cli/issues/348
@@ -1306,6 +2313,11 @@ type TargetOptions struct {
 	AddNewColumns    *bool               `json:"add_new_columns,omitempty" yaml:"add_new_columns,omitempty"`
 	AdjustColumnType *bool               `json:"adjust_column_type,omitempty" yaml:"adjust_column_type,omitempty"`
 	ColumnCasing     *ColumnCasing       `json:"column_casing,omitempty" yaml:"column_casing,omitempty"`
+	Dedupe           *DedupeMode         `json:"dedupe,omitempty" yaml:"dedupe,omitempty"`
+	SwapTable        *bool               `json:"swap_table,omitempty" yaml:"swap_table,omitempty"`
+	RejectTo         *string             `json:"reject_to,omitempty" yaml:"reject_to,omitempty"`
+	MaxRejects       *int64              `json:"max_rejects,omitempty" yaml:"max_rejects,omitempty"`
+	SchemaEvolution  *SchemaEvolution    `json:"schema_evolution,omitempty" yaml:"schema_evolution,omitempty"`
 
 	TableKeys database.TableKeys `json:"table_keys,omitempty" yaml:"table_keys,omitempty"`
 	TableTmp  string             `json:"table_tmp,omitempty" yaml:"table_tmp,omitempty"`
@@ -1427,6 +2439,12 @@ func (o *SourceOptions) SetDefaults(sourceOptions SourceOptions) {
 	if o.MaxDecimals == nil {
 		o.MaxDecimals = sourceOptions.MaxDecimals
 	}
+	if o.Lookback == nil {
+		o.Lookback = sourceOptions.Lookback
+	}
+	if o.Where == nil {
+		o.Where = sourceOptions.Where
+	}
 	if o.Columns == nil {
 		o.Columns = sourceOptions.Columns // legacy
 	}
@@ -1499,6 +2517,21 @@ func (o *TargetOptions) SetDefaults(targetOptions TargetOptions) {
 	if o.ColumnCasing == nil {
 		o.ColumnCasing = targetOptions.ColumnCasing
 	}
+	if o.Dedupe == nil {
+		o.Dedupe = targetOptions.Dedupe
+	}
+	if o.SwapTable == nil {
+		o.SwapTable = targetOptions.SwapTable
+	}
+	if o.RejectTo == nil {
+		o.RejectTo = targetOptions.RejectTo
+	}
+	if o.MaxRejects == nil {
+		o.MaxRejects = targetOptions.MaxRejects
+	}
+	if o.SchemaEvolution == nil {
+		o.SchemaEvolution = targetOptions.SchemaEvolution
+	}
 	if o.TableKeys == nil {
 		o.TableKeys = targetOptions.TableKeys
 		if o.TableKeys == nil {
@@ -1539,6 +2572,15 @@ func castKeyArray(keyI any) (key []string) {
 	return
 }
 
+var sqlExpressionRegex = regexp.MustCompile(`(?s)^\s*(\w+\s*)?\(.*\)\s*$`)
+
+// isSQLExpression returns true if the value is a function call, such as
+// `coalesce(updated_at, created_at)`, or is wrapped in parentheses,
+// such as `(updated_at + 1)`. Any other value is a column name.
+func isSQLExpression(val string) bool {
+	return sqlExpressionRegex.MatchString(val)
+}
+
 // expandEnvVars replaces $KEY or ${KEY} with its environment variable value
 // only if the variable is present in the environment.
 // If not present, $KEY or ${KEY} will remain in the config text.
@@ -2,11 +2,16 @@ package sling
 
 import (
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
 	"testing"
 	"time"
 
 	"github.com/flarco/g"
 	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/spf13/cast"
 	"github.com/stretchr/testify/assert"
@@ -86,3 +91,486 @@ func TestColumnCasing(t *testing.T) {
 	applyColumnCasingToDf(df, dbio.TypeDbDuckDb, &snakeCasing)
 	assert.Equal(t, "dhl_original_tracking_number", df.Columns[0].Name)
 }
+
+func TestApplyLookback(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	cfg := &Config{Source: Source{Options: &SourceOptions{Lookback: g.String("2h")}}}
+
+	cfg.IncrementalVal = ts
+	err := applyLookback(cfg, iop.DatetimeType)
+	assert.NoError(t, err)
+	assert.Equal(t, ts.Add(-2*time.Hour), cfg.IncrementalVal)
+
+	cfg.Source.Options.Lookback = g.String("1d")
+	cfg.IncrementalVal = ts
+	err = applyLookback(cfg, iop.DateType)
+	assert.NoError(t, err)
+	assert.Equal(t, ts.Add(-24*time.Hour), cfg.IncrementalVal)
+
+	cfg.Source.Options.Lookback = g.String("500")
+	cfg.IncrementalVal = int64(1500)
+	err = applyLookback(cfg, iop.BigIntType)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1000, cfg.IncrementalVal)
+
+	cfg.Source.Options.Lookback = g.String("1m")
+	cfg.IncrementalVal = int64(1500)
+	err = applyLookback(cfg, iop.BigIntType)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1440, cfg.IncrementalVal)
+
+	cfg.Source.Options.Lookback = g.String("abc")
+	cfg.IncrementalVal = ts
+	err = applyLookback(cfg, iop.DatetimeType)
+	assert.Error(t, err)
+}
+
+func TestSourceUpdateKey(t *testing.T) {
+	s := Source{UpdateKey: "updated_at"}
+	s.SetUpdateKey()
+	assert.False(t, s.HasUpdateKeyExpr())
+	assert.Equal(t, "updated_at", s.UpdateKey)
+
+	s = Source{UpdateKeyCols: []string{"updated_at", "created_at"}}
+	s.SetUpdateKey()
+	assert.True(t, s.HasUpdateKeyExpr())
+	assert.Equal(t, slingUpdateKeyColumn, s.UpdateKey)
+
+	s = Source{UpdateKey: "coalesce(updated_at, created_at)"}
+	s.SetUpdateKey()
+	assert.True(t, s.HasUpdateKeyExpr())
+	assert.Equal(t, slingUpdateKeyColumn, s.UpdateKey)
+	assert.Equal(t, "coalesce(updated_at, created_at)", s.UpdateKeyExpr)
+	s.SetUpdateKey()
+	assert.Equal(t, "coalesce(updated_at, created_at)", s.UpdateKeyExpr)
+
+	// quoted or spaced column names are not expressions
+	for _, name := range []string{`"my col"`, "my col", "`updated at`", "[updated at]"} {
+		s = Source{UpdateKey: name}
+		s.SetUpdateKey()
+		assert.False(t, s.HasUpdateKeyExpr(), name)
+		assert.Equal(t, name, s.UpdateKey)
+	}
+
+	s = Source{UpdateKey: "(updated_at + 1)"}
+	assert.True(t, s.HasUpdateKeyExpr())
+
+	// a list is accepted in json and yaml, and marshalled back
+	s = Source{}
+	err := g.Unmarshal(`{"stream": "t1", "update_key": ["updated_at", "created_at"]}`, &s)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"updated_at", "created_at"}, s.UpdateKeyCols)
+		assert.Equal(t, "t1", s.Stream)
+		assert.Contains(t, g.Marshal(s), `"update_key":["updated_at","created_at"]`)
+	}
+
+	cfg := Config{}
+	err = cfg.Unmarshal("source:\n  stream: t1\n  update_key: [updated_at]\n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "updated_at", cfg.Source.UpdateKey)
+		assert.Equal(t, "t1", cfg.Source.Stream)
+	}
+
+	stream := ReplicationStreamConfig{}
+	err = g.Unmarshal(`{"mode": "incremental", "update_key": ["updated_at", "created_at"]}`, &stream)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"updated_at", "created_at"}, stream.UpdateKeyCols)
+		assert.Equal(t, IncrementalMode, stream.Mode)
+		assert.Contains(t, g.Marshal(stream), `"update_key":["updated_at","created_at"]`)
+	}
+}
+
+func TestRejectToIsFile(t *testing.T) {
+	assert.True(t, rejectToIsFile("s3://bucket/rejects/"))
+	assert.True(t, rejectToIsFile("/tmp/rejects.csv"))
+	assert.True(t, rejectToIsFile("./rejects"))
+	assert.True(t, rejectToIsFile(`C:\data\rejects.csv`))
+	assert.True(t, rejectToIsFile("rejects.jsonl"))
+	assert.False(t, rejectToIsFile("public.my_table_rejects"))
+	assert.False(t, rejectToIsFile("my_rejects"))
+}
+
+func TestLoadValidation(t *testing.T) {
+	cfg := Config{}
+	lv, err := cfg.LoadValidation()
+	assert.NoError(t, err)
+	assert.False(t, lv.Enabled())
+
+	cfg.Validate = "count"
+	lv, err = cfg.LoadValidation()
+	assert.NoError(t, err)
+	assert.True(t, lv.Count)
+	assert.False(t, lv.Checksum)
+	assert.Equal(t, "fail", lv.OnFail)
+
+	cfg.Validate = []any{"count", "checksum"}
+	lv, err = cfg.LoadValidation()
+	assert.NoError(t, err)
+	assert.True(t, lv.Count && lv.Checksum)
+
+	cfg.Validate = map[string]any{"checks": "count, checksum", "on_fail": "warn"}
+	lv, err = cfg.LoadValidation()
+	assert.NoError(t, err)
+	assert.True(t, lv.Count && lv.Checksum)
+	assert.Equal(t, "warn", lv.OnFail)
+
+	cfg.Validate = "rows"
+	_, err = cfg.LoadValidation()
+	assert.Error(t, err)
+
+	cfg.Validate = map[string]any{"checks": "count", "on_fail": "skip"}
+	_, err = cfg.LoadValidation()
+	assert.Error(t, err)
+}
+
+func TestDatasetExpectations(t *testing.T) {
+	cfg := Config{}
+	expectations, err := cfg.DatasetExpectations()
+	assert.NoError(t, err)
+	assert.Empty(t, expectations)
+
+	cfg.Expectations = map[any]any{
+		"unique":    []any{"id"},
+		"not_null":  "id, name",
+		"row_count": map[any]any{"min": 1, "severity": "warn"},
+		"freshness": map[any]any{"column": "updated_at", "max_age": "24h"},
+	}
+	expectations, err = cfg.DatasetExpectations()
+	if assert.NoError(t, err) && assert.Len(t, expectations, 4) {
+		// sorted by type
+		assert.Equal(t, ExpectFreshness, expectations[0].Type)
+		assert.Equal(t, 24*time.Hour, expectations[0].MaxAge)
+		assert.Equal(t, "not_null(id, name)", expectations[1].Name())
+		assert.Equal(t, ExpectRowCount, expectations[2].Type)
+		assert.EqualValues(t, 1, *expectations[2].Min)
+		assert.Nil(t, expectations[2].Max)
+		assert.Equal(t, "warn", expectations[2].Severity)
+		assert.Equal(t, "unique(id)", expectations[3].Name())
+		assert.Equal(t, "fail", expectations[3].Severity)
+	}
+	assert.Equal(t, []string{"id"}, cfg.DistinctColumns())
+
+	// evaluated from the stream stats, also without a target connection
+	columns := iop.Columns{
+		{Name: "id", Stats: iop.ColumnStats{TotalCnt: 4, UniqCnt: 3}},
+		{Name: "name", Stats: iop.ColumnStats{TotalCnt: 4, NullCnt: 2, UniqCnt: 2}},
+	}
+	passed, observed, err := evaluateExpectation(expectations[3], nil, database.Table{}, columns, 4)
+	assert.NoError(t, err)
+	assert.False(t, passed)
+	assert.Equal(t, "1 duplicate values", observed)
+	passed, observed, err = evaluateExpectation(expectations[1], nil, database.Table{}, columns, 4)
+	assert.NoError(t, err)
+	assert.False(t, passed)
+	assert.Equal(t, "name: 2 nulls", observed)
+	passed, _, err = evaluateExpectation(Expectation{Type: ExpectUnique, Columns: []string{"name"}}, nil, database.Table{}, columns, 4)
+	assert.NoError(t, err)
+	assert.False(t, passed) // two nulls
+
+	// without stats, the target is queried
+	_, _, err = evaluateExpectation(expectations[1], nil, database.Table{}, nil, 4)
+	assert.Equal(t, errExpectationSkipped, err)
+
+	cfg.Expectations = []any{
+		map[string]any{"accepted_values": map[string]any{"column": "status", "values": []any{"open", "closed"}}, "severity": "warn"},
+		map[string]any{"accepted_values": map[string]any{"column": "kind", "values": "a,b"}},
+	}
+	expectations, err = cfg.DatasetExpectations()
+	if assert.NoError(t, err) && assert.Len(t, expectations, 2) {
+		assert.Equal(t, []string{"open", "closed"}, expectations[0].Values)
+		assert.Equal(t, "warn", expectations[0].Severity)
+		assert.Equal(t, []string{"a", "b"}, expectations[1].Values)
+		assert.Equal(t, "fail", expectations[1].Severity)
+	}
+
+	cfg.Expectations = map[string]any{"unique": []any{}}
+	_, err = cfg.DatasetExpectations()
+	assert.Error(t, err)
+
+	cfg.Expectations = map[string]any{"freshness": map[string]any{"column": "updated_at", "max_age": "soon"}}
+	_, err = cfg.DatasetExpectations()
+	assert.Error(t, err)
+
+	cfg.Expectations = map[string]any{"not_null": []any{"id"}, "severity": "info"}
+	_, err = cfg.DatasetExpectations()
+	assert.Error(t, err)
+
+	cfg.Expectations = map[string]any{"between": []any{"id"}}
+	_, err = cfg.DatasetExpectations()
+	assert.Error(t, err)
+}
+
+func TestRowAnomalyCheck(t *testing.T) {
+	cfg := Config{}
+	ac, err := cfg.RowAnomalyCheck()
+	assert.NoError(t, err)
+	assert.False(t, ac.Enabled)
+
+	cfg.AnomalyCheck = true
+	ac, err = cfg.RowAnomalyCheck()
+	assert.NoError(t, err)
+	assert.True(t, ac.Enabled)
+	assert.Equal(t, "mad", ac.Method)
+	assert.EqualValues(t, 3, ac.Threshold)
+	assert.Equal(t, "warn", ac.Severity)
+
+	cfg.AnomalyCheck = "pct_change"
+	ac, err = cfg.RowAnomalyCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, "pct_change", ac.Method)
+	assert.EqualValues(t, 50, ac.Threshold)
+
+	cfg.AnomalyCheck = map[any]any{"method": "mad", "threshold": 5, "history": 20, "severity": "fail"}
+	ac, err = cfg.RowAnomalyCheck()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, ac.Threshold)
+	assert.Equal(t, 20, ac.History)
+	assert.Equal(t, "fail", ac.Severity)
+
+	cfg.AnomalyCheck = "zscore"
+	_, err = cfg.RowAnomalyCheck()
+	assert.Error(t, err)
+
+	cfg.AnomalyCheck = map[string]any{"history": 2, "min_history": 5}
+	_, err = cfg.RowAnomalyCheck()
+	assert.Error(t, err)
+
+	// median ± MADs
+	mad := RowAnomalyCheck{Method: "mad", Threshold: 3}
+	history := []uint64{1000, 1050, 980, 1020, 990}
+	anomalous, _ := detectRowAnomaly(mad, 1010, history)
+	assert.False(t, anomalous)
+	anomalous, _ = detectRowAnomaly(mad, 600, history)
+	assert.True(t, anomalous)
+	anomalous, observed := detectRowAnomaly(mad, 0, history)
+	assert.True(t, anomalous)
+	assert.Contains(t, observed, "median of last 5 runs: 1000")
+
+	// steady history still allows small variations
+	anomalous, _ = detectRowAnomaly(mad, 1100, []uint64{1000, 1000, 1000})
+	assert.False(t, anomalous)
+
+	// percent change
+	pct := RowAnomalyCheck{Method: "pct_change", Threshold: 1000}
+	anomalous, _ = detectRowAnomaly(pct, 5000, history)
+	assert.False(t, anomalous)
+	anomalous, _ = detectRowAnomaly(pct, 10000, history) // 10x spike
+	assert.True(t, anomalous)
+	anomalous, _ = detectRowAnomaly(pct, 0, history) // drop to zero
+	assert.True(t, anomalous)
+}
+
+func TestNotifyAnomaly(t *testing.T) {
+	events := make(chan map[string]any, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]any{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		events <- payload
+	}))
+	defer server.Close()
+
+	pc := ProjectConfig{NotificationTags: map[string]NotificationConfig{
+		"anomalies": {WebhookURLs: []string{server.URL}, OnAnomaly: true},
+		"failures":  {WebhookURLs: []string{server.URL}, OnFailure: true},
+	}}
+
+	// a passing anomaly check does not notify
+	task := &TaskExecution{ExecID: "exec1", Config: &Config{StreamName: "public.orders"}}
+	task.Expectations = []ExpectationResult{{Type: "anomaly_check", Status: "pass"}}
+	assert.Equal(t, "", pc.NotificationTags["anomalies"].Event(task))
+	pc.Notify(task)
+	assert.Len(t, events, 0)
+
+	// a warn anomaly notifies, without failing the task
+	task.Expectations = []ExpectationResult{{Type: "anomaly_check", Status: "warn", Message: "row count anomaly: 0"}}
+	pc.Notify(task)
+	if assert.Len(t, events, 1) {
+		payload := <-events
+		assert.Equal(t, "anomaly", payload["event"])
+		assert.Equal(t, "public.orders", payload["stream"])
+		assert.Contains(t, g.Marshal(payload["expectations"]), "row count anomaly")
+	}
+
+	// failures take priority over anomalies
+	task.Err = g.Error("anomaly check failed")
+	assert.Equal(t, "failure", pc.NotificationTags["failures"].Event(task))
+	assert.Equal(t, "anomaly", pc.NotificationTags["anomalies"].Event(task))
+	assert.Equal(t, "failure", NotificationConfig{OnFailure: true, OnAnomaly: true}.Event(task))
+}
+
+func TestComputedColumns(t *testing.T) {
+	cfg := Config{}
+	computed, err := cfg.ComputedColumns()
+	assert.NoError(t, err)
+	assert.Empty(t, computed)
+
+	cfg.Computed = map[any]any{
+		"full_name": "first_name || ' ' || last_name",
+		"total":     map[any]any{"expression": "amount * qty", "type": "decimal"},
+	}
+	computed, err = cfg.ComputedColumns()
+	if assert.NoError(t, err) && assert.Len(t, computed, 2) {
+		assert.Equal(t, "full_name", computed[0].Name)
+		assert.Equal(t, "amount * qty", computed[1].Expression)
+		assert.Equal(t, iop.DecimalType, computed[1].Type)
+	}
+
+	cfg.Computed = []any{map[any]any{"name": "is_big", "expr": "amount > 100"}}
+	computed, err = cfg.ComputedColumns()
+	if assert.NoError(t, err) && assert.Len(t, computed, 1) {
+		assert.Equal(t, "is_big", computed[0].Name)
+	}
+
+	cfg.Computed = map[string]any{"bad": "amount +"}
+	_, err = cfg.ComputedColumns()
+	assert.Error(t, err)
+
+	cfg.Computed = map[string]any{"a": "b + 1", "b": "a + 1"}
+	_, err = cfg.ComputedColumns()
+	assert.Error(t, err)
+}
+
+func TestColumnRenameOrder(t *testing.T) {
+	cfg := Config{
+		Source: Source{PrimaryKeyI: []string{"cust_id"}, UpdateKey: "upd_ts"},
+		Rename: map[any]any{"cust_id": "customer_id", "cust_nm": "customer_name"},
+		Order:  []any{"customer_name", "customer_id"},
+	}
+
+	renames, err := cfg.ColumnRenames()
+	assert.NoError(t, err)
+	assert.Len(t, renames, 2)
+	assert.Equal(t, []string{"customer_id"}, cfg.TargetPrimaryKey())
+	assert.Equal(t, "upd_ts", cfg.TargetUpdateKey())
+
+	data := iop.NewDataset(iop.NewColumnsFromFields("cust_id", "upd_ts", "cust_nm"))
+	data.Append([]any{1, "2024-01-01", "Jane"}, []any{2, "2024-01-02", "John"})
+	df, err := iop.MakeDataFlow(data.Stream())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, applyColumnRenameToDf(df, renames))
+	df, err = applyColumnOrderToDf(df, []string{"customer_name", "customer_id"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	result, err := df.Collect()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"customer_name", "customer_id", "upd_ts"}, result.Columns.Names())
+		if assert.Len(t, result.Rows, 2) {
+			assert.Equal(t, "Jane", result.Rows[0][0])
+			assert.EqualValues(t, 1, result.Rows[0][1])
+		}
+	}
+
+	cfg.Rename = map[string]any{"a": "c", "b": "C"}
+	_, err = cfg.ColumnRenames()
+	assert.Error(t, err)
+
+	cfg.Order = "a, b, A"
+	_, err = cfg.ColumnOrder()
+	assert.Error(t, err)
+}
+
+func TestColumnLookups(t *testing.T) {
+	cfg := Config{
+		Lookups: []any{
+			map[any]any{
+				"connection":   "MY_PG",
+				"stream":       "select code, name, rate from currencies",
+				"keys":         map[any]any{"currency": "code"},
+				"columns":      map[any]any{"name": "currency_name", "rate": "fx_rate"},
+				"default":      map[any]any{"fx_rate": 1},
+				"on_unmatched": "default",
+			},
+		},
+	}
+
+	lookups, err := cfg.ColumnLookups()
+	if assert.NoError(t, err) && assert.Len(t, lookups, 1) {
+		assert.Equal(t, "lookup_1", lookups[0].Name)
+		assert.Equal(t, []string{"currency"}, lookups[0].Keys)
+		assert.Equal(t, []string{"code"}, lookups[0].RefKeys)
+		assert.Equal(t, []string{"name", "rate"}, lookups[0].Columns)
+		assert.Equal(t, []string{"currency_name", "fx_rate"}, lookups[0].Names)
+	}
+
+	cfg.Lookups = map[any]any{"currencies": map[any]any{"stream": "currencies.csv", "keys": "code", "on_unmatched": "skip"}}
+	_, err = cfg.ColumnLookups()
+	assert.ErrorContains(t, err, "no columns")
+
+	cfg.Lookups = map[any]any{"currencies": map[any]any{"stream": "currencies.csv", "keys": "code", "columns": "rate", "on_unmatched": "skip"}}
+	_, err = cfg.ColumnLookups()
+	assert.ErrorContains(t, err, "on_unmatched")
+}
+
+func TestTransformPlugin(t *testing.T) {
+	cfg := Config{Plugin: "python3 transform.py --strict"}
+	plugin, err := cfg.TransformPlugin()
+	if assert.NoError(t, err) && assert.NotNil(t, plugin) {
+		assert.Equal(t, []string{"python3", "transform.py", "--strict"}, plugin.Command)
+		assert.Equal(t, iop.PluginFormatJsonLines, plugin.Format)
+	}
+
+	cfg.Plugin = map[any]any{
+		"command":    []any{"./enrich", "--mode", "fast mode"},
+		"format":     "arrow",
+		"batch_size": 500,
+		"env":        map[any]any{"LEVEL": 2},
+	}
+	plugin, err = cfg.TransformPlugin()
+	if assert.NoError(t, err) && assert.NotNil(t, plugin) {
+		assert.Equal(t, []string{"./enrich", "--mode", "fast mode"}, plugin.Command)
+		assert.Equal(t, iop.PluginFormatArrow, plugin.Format)
+		assert.Equal(t, 500, plugin.BatchSize)
+		assert.Equal(t, "2", plugin.Env["LEVEL"])
+	}
+
+	cfg.Plugin = map[any]any{"command": "./enrich", "format": "csv"}
+	_, err = cfg.TransformPlugin()
+	assert.ErrorContains(t, err, "format")
+
+	cfg.Plugin = map[any]any{"format": "arrow"}
+	_, err = cfg.TransformPlugin()
+	assert.ErrorContains(t, err, "no command")
+}
+
+func TestTransformScript(t *testing.T) {
+	cfg := Config{Script: map[any]any{
+		"source":  "def transform(row):\n    row['total'] = row['qty'] * row['price']\n    return row\n",
+		"workers": 2,
+	}}
+	script, err := cfg.TransformScript()
+	if assert.NoError(t, err) && assert.NotNil(t, script) {
+		assert.Equal(t, 2, script.Workers)
+	}
+
+	cfg.Script = "def other(row):\n    return row\n"
+	_, err = cfg.TransformScript()
+	assert.ErrorContains(t, err, "transform(row)")
+
+	cfg.Script = "does-not-exist.star"
+	_, err = cfg.TransformScript()
+	assert.ErrorContains(t, err, "could not read script file")
+}
+
+func TestTransformSQLText(t *testing.T) {
+	cfg := Config{TransformSQL: " select region, count(*) as cnt from stream group by region; "}
+	sql, err := cfg.TransformSQLText()
+	assert.NoError(t, err)
+	assert.Equal(t, "select region, count(*) as cnt from stream group by region", sql)
+
+	sqlPath := path.Join(t.TempDir(), "transform.sql")
+	assert.NoError(t, os.WriteFile(sqlPath, []byte("select * from stream where amount > 0\n"), 0644))
+	cfg.TransformSQL = "file://" + sqlPath
+	sql, err = cfg.TransformSQLText()
+	assert.NoError(t, err)
+	assert.Equal(t, "select * from stream where amount > 0", sql)
+
+	cfg.TransformSQL = ""
+	sql, err = cfg.TransformSQLText()
+	assert.NoError(t, err)
+	assert.Empty(t, sql)
+}
@@ -1,5 +1,13 @@
 package sling
 
+import (
+	"bytes"
+
+	"github.com/flarco/g"
+	"github.com/flarco/g/net"
+	"github.com/samber/lo"
+)
+
 type Project struct {
 	Config      ProjectConfig
 	TaskConfigs map[string]Config
@@ -14,6 +22,20 @@ type ProjectConfig struct {
 	NotificationTags map[string]NotificationConfig `json:"notification_tags" yaml:"notification_tags"`
 }
 
+// Notify sends the notifications of the task execution, for each
+// notification tag with an enabled event
+func (pc ProjectConfig) Notify(t *TaskExecution) {
+	for tag, nc := range pc.NotificationTags {
+		event := nc.Event(t)
+		if event == "" {
+			continue
+		}
+		if err := nc.Send(t, event); err != nil {
+			g.Warn("could not send %s notification for tag %s: %s", event, tag, err.Error())
+		}
+	}
+}
+
 type NotificationConfig struct {
 	Name        string   `json:"name"`
 	Emails      []string `json:"emails"`
@@ -24,4 +46,59 @@ type NotificationConfig struct {
 	OnFailure   bool     `json:"on_failure"`
 	OnLinger    bool     `json:"on_linger"`
 	OnEmpty     bool     `json:"on_empty"`
+	OnAnomaly   bool     `json:"on_anomaly"`
+}
+
+// Event returns the event of the task execution to notify, if enabled:
+// failure, anomaly, empty or success, by order of priority.
+// Anomalies are the anomaly check results which did not pass.
+func (nc NotificationConfig) Event(t *TaskExecution) string {
+	anomalous := lo.ContainsBy(t.Expectations, func(r ExpectationResult) bool {
+		return r.Type == "anomaly_check" && g.In(r.Status, "warn", "fail")
+	})
+
+	switch {
+	case nc.OnFailure && t.Err != nil:
+		return "failure"
+	case nc.OnAnomaly && anomalous:
+		return "anomaly"
+	case nc.OnEmpty && t.Err == nil && t.GetCount() == 0:
+		return "empty"
+	case nc.OnSuccess && t.Err == nil:
+		return "success"
+	}
+	return ""
+}
+
+// Send posts the event of the task execution to the webhook URLs
+func (nc NotificationConfig) Send(t *TaskExecution, event string) (err error) {
+	payload := g.M(
+		"event", event,
+		"exec_id", t.ExecID,
+		"status", t.Status,
+		"rows", t.GetCount(),
+		"expectations", t.Expectations,
+	)
+	if t.Config != nil {
+		payload["stream"] = t.Config.StreamName
+		payload["object"] = t.Config.Target.Object
+	}
+	if t.Err != nil {
+		payload["error"] = t.Err.Error()
+	}
+
+	eG := g.ErrorGroup{}
+	for _, url := range nc.WebhookURLs {
+		_, _, err = net.ClientDo(
+			"POST",
+			url,
+			bytes.NewBuffer([]byte(g.Marshal(payload))),
+			map[string]string{"Content-Type": "application/json"},
+			10, // seconds
+		)
+		if err != nil {
+			eG.Capture(g.Error(err, "could not post notification to webhook"))
+		}
+	}
+	return eG.Err()
 }
@@ -433,11 +433,13 @@ func (rd ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...strin
 				stream.SourceOptions.Offset = cfgOverwrite.Source.Options.Offset
 			}
 
-			if cfgOverwrite.Source.UpdateKey != "" && stream.UpdateKey != cfgOverwrite.Source.UpdateKey {
-				if stream.UpdateKey != "" {
-					g.Debug("stream update_key overwritten for `%s`: %s => %s", name, stream.UpdateKey, cfgOverwrite.Source.UpdateKey)
+			overwriteKey := updateKeyValue(cfgOverwrite.Source.UpdateKey, cfgOverwrite.Source.UpdateKeyCols)
+			if streamKey := updateKeyValue(stream.UpdateKey, stream.UpdateKeyCols); overwriteKey != nil && g.Marshal(streamKey) != g.Marshal(overwriteKey) {
+				if streamKey != nil {
+					g.Debug("stream update_key overwritten for `%s`: %v => %v", name, streamKey, overwriteKey)
 				}
 				stream.UpdateKey = cfgOverwrite.Source.UpdateKey
+				stream.UpdateKeyCols = cfgOverwrite.Source.UpdateKeyCols
 			}
 
 			if cfgOverwrite.Source.PrimaryKeyI != nil && stream.PrimaryKeyI != cfgOverwrite.Source.PrimaryKeyI {
@@ -459,12 +461,13 @@ func (rd ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...strin
 
 		cfg := Config{
 			Source: Source{
-				Conn:        rd.Source,
-				Stream:      name,
-				SQL:         stream.SQL,
-				Select:      stream.Select,
-				PrimaryKeyI: stream.PrimaryKey(),
-				UpdateKey:   stream.UpdateKey,
+				Conn:          rd.Source,
+				Stream:        name,
+				SQL:           stream.SQL,
+				Select:        stream.Select,
+				PrimaryKeyI:   stream.PrimaryKey(),
+				UpdateKey:     stream.UpdateKey,
+				UpdateKeyCols: stream.UpdateKeyCols,
 			},
 			Target: Target{
 				Conn:    rd.Target,
@@ -473,6 +476,16 @@ func (rd ReplicationConfig) Compile(cfgOverwrite *Config, selectStreams ...strin
 			},
 			Mode:              stream.Mode,
 			Transforms:        stream.Transforms,
+			Validate:          stream.Validate,
+			Expectations:      stream.Expectations,
+			AnomalyCheck:      stream.AnomalyCheck,
+			Computed:          stream.Computed,
+			Rename:            stream.Rename,
+			Order:             stream.Order,
+			Lookups:           stream.Lookups,
+			Script:            stream.Script,
+			Plugin:            stream.Plugin,
+			TransformSQL:      stream.TransformSQL,
 			Env:               g.ToMapString(rd.Env),
 			StreamName:        name,
 			ReplicationStream: &stream,
@@ -517,8 +530,44 @@ type ReplicationStreamConfig struct {
 	Single        *bool          `json:"single,omitempty" yaml:"single,omitempty"`
 	Transforms    any            `json:"transforms,omitempty" yaml:"transforms,omitempty"`
 	Columns       any            `json:"columns,omitempty" yaml:"columns,omitempty"`
+	Validate      any            `json:"validate,omitempty" yaml:"validate,omitempty"`
+	Expectations  any            `json:"expectations,omitempty" yaml:"expectations,omitempty"`
+	AnomalyCheck  any            `json:"anomaly_check,omitempty" yaml:"anomaly_check,omitempty"`
+	Computed      any            `json:"computed_columns,omitempty" yaml:"computed_columns,omitempty"`
+	Rename        any            `json:"rename,omitempty" yaml:"rename,omitempty"`
+	Order         any            `json:"order,omitempty" yaml:"order,omitempty"`
+	Lookups       any            `json:"lookups,omitempty" yaml:"lookups,omitempty"`
+	Script        any            `json:"script,omitempty" yaml:"script,omitempty"`
+	Plugin        any            `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	TransformSQL  string         `json:"transform_sql,omitempty" yaml:"transform_sql,omitempty"`
 
 	State *StreamIncrementalState `json:"state,omitempty" yaml:"state,omitempty"`
+
+	UpdateKeyCols []string `json:"-" yaml:"-"` // when update_key is a list of columns
+}
+
+// UnmarshalJSON accepts a column name, a list of columns or a SQL expression as update_key
+func (s *ReplicationStreamConfig) UnmarshalJSON(data []byte) error {
+	type streamConfig ReplicationStreamConfig
+	aux := struct {
+		*streamConfig
+		UpdateKey any `json:"update_key,omitempty"`
+	}{streamConfig: (*streamConfig)(s)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	s.UpdateKey, s.UpdateKeyCols = parseUpdateKey(aux.UpdateKey)
+	return nil
+}
+
+// MarshalJSON writes update_key as provided
+func (s ReplicationStreamConfig) MarshalJSON() ([]byte, error) {
+	type streamConfig ReplicationStreamConfig
+	return json.Marshal(struct {
+		streamConfig
+		UpdateKey any `json:"update_key,omitempty"`
+	}{streamConfig: streamConfig(s), UpdateKey: updateKeyValue(s.UpdateKey, s.UpdateKeyCols)})
 }
 
 type StreamIncrementalState struct {
@@ -539,17 +588,27 @@ func SetStreamDefaults(name string, stream *ReplicationStreamConfig, replication
 
 	// the keys to check if provided in map
 	defaultSet := map[string]func(){
-		"mode":        func() { stream.Mode = replicationCfg.Defaults.Mode },
-		"object":      func() { stream.Object = replicationCfg.Defaults.Object },
-		"select":      func() { stream.Select = replicationCfg.Defaults.Select },
-		"primary_key": func() { stream.PrimaryKeyI = replicationCfg.Defaults.PrimaryKeyI },
-		"update_key":  func() { stream.UpdateKey = replicationCfg.Defaults.UpdateKey },
-		"sql":         func() { stream.SQL = replicationCfg.Defaults.SQL },
-		"schedule":    func() { stream.Schedule = replicationCfg.Defaults.Schedule },
-		"disabled":    func() { stream.Disabled = replicationCfg.Defaults.Disabled },
-		"single":      func() { stream.Single = replicationCfg.Defaults.Single },
-		"transforms":  func() { stream.Transforms = replicationCfg.Defaults.Transforms },
-		"columns":     func() { stream.Columns = replicationCfg.Defaults.Columns },
+		"mode":             func() { stream.Mode = replicationCfg.Defaults.Mode },
+		"object":           func() { stream.Object = replicationCfg.Defaults.Object },
+		"select":           func() { stream.Select = replicationCfg.Defaults.Select },
+		"primary_key":      func() { stream.PrimaryKeyI = replicationCfg.Defaults.PrimaryKeyI },
+		"update_key":       func() { stream.UpdateKey = replicationCfg.Defaults.UpdateKey },
+		"sql":              func() { stream.SQL = replicationCfg.Defaults.SQL },
+		"schedule":         func() { stream.Schedule = replicationCfg.Defaults.Schedule },
+		"disabled":         func() { stream.Disabled = replicationCfg.Defaults.Disabled },
+		"single":           func() { stream.Single = replicationCfg.Defaults.Single },
+		"transforms":       func() { stream.Transforms = replicationCfg.Defaults.Transforms },
+		"columns":          func() { stream.Columns = replicationCfg.Defaults.Columns },
+		"validate":         func() { stream.Validate = replicationCfg.Defaults.Validate },
+		"expectations":     func() { stream.Expectations = replicationCfg.Defaults.Expectations },
+		"anomaly_check":    func() { stream.AnomalyCheck = replicationCfg.Defaults.AnomalyCheck },
+		"computed_columns": func() { stream.Computed = replicationCfg.Defaults.Computed },
+		"rename":           func() { stream.Rename = replicationCfg.Defaults.Rename },
+		"order":            func() { stream.Order = replicationCfg.Defaults.Order },
+		"lookups":          func() { stream.Lookups = replicationCfg.Defaults.Lookups },
+		"script":           func() { stream.Script = replicationCfg.Defaults.Script },
+		"plugin":           func() { stream.Plugin = replicationCfg.Defaults.Plugin },
+		"transform_sql":    func() { stream.TransformSQL = replicationCfg.Defaults.TransformSQL },
 	}
 
 	for key, setFunc := range defaultSet {
@@ -558,6 +617,11 @@ func SetStreamDefaults(name string, stream *ReplicationStreamConfig, replication
 		}
 	}
 
+	// update_key may be a list of columns
+	if _, found := streamMap["update_key"]; !found {
+		stream.UpdateKeyCols = replicationCfg.Defaults.UpdateKeyCols
+	}
+
 	// set default options
 	if stream.SourceOptions == nil {
 		stream.SourceOptions = replicationCfg.Defaults.SourceOptions
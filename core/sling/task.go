@@ -9,6 +9,7 @@ import (
 
 	"github.com/dustin/go-humanize"
 	"github.com/flarco/g"
+	"github.com/samber/lo"
 	"github.com/segmentio/ksuid"
 	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
@@ -19,6 +20,7 @@ import (
 // Set in the store/store.go file for history keeping
 var StoreInsert = func(t *TaskExecution) error { return nil }
 var StoreUpdate = func(t *TaskExecution) error { return nil }
+var StoreRowHistory = func(t *TaskExecution, limit int) ([]uint64, error) { return nil, nil }
 
 // TaskExecution is a sling ELT task run, synonymous to an execution
 type TaskExecution struct {
@@ -41,11 +43,17 @@ type TaskExecution struct {
 	Output        strings.Builder `json:"-"`
 	OutputLines   chan *g.LogLine
 
-	Replication    *ReplicationConfig `json:"replication"`
-	ProgressHist   []string           `json:"progress_hist"`
-	PBar           *ProgressBar       `json:"-"`
-	ProcStatsStart g.ProcStats        `json:"-"` // process stats at beginning
+	Replication    *ReplicationConfig  `json:"replication"`
+	Project        *ProjectConfig      `json:"-"` // for the notifications
+	ProgressHist   []string            `json:"progress_hist"`
+	PBar           *ProgressBar        `json:"-"`
+	ProcStatsStart g.ProcStats         `json:"-"` // process stats at beginning
+	SchemaChanges  []SchemaChange      `json:"schema_changes,omitempty"`
+	Expectations   []ExpectationResult `json:"expectations,omitempty"`
 	cleanupFuncs   []func()
+	lookupsID      string               // ID of the registered lookups of the `lookups` option
+	rejects        *iop.RejectWriter    // writer of the rejected rows, into `reject_to`
+	distinct       *iop.DistinctCounter // distinct values of the `unique` expectations columns
 }
 
 // ExecutionStatus is an execution status object
@@ -320,7 +328,7 @@ func (t *TaskExecution) setGetMetadata() (metadata iop.Metadata) {
 
 		if addRowIDCol && t.Config.Source.HasPrimaryKey() {
 			// set primary key for StarRocks
-			t.Config.Target.Options.TableKeys[iop.PrimaryKey] = t.Config.Source.PrimaryKey()
+			t.Config.Target.Options.TableKeys[iop.PrimaryKey] = t.Config.TargetPrimaryKey()
 			addRowIDCol = false
 		}
 
@@ -405,6 +413,23 @@ func (t *TaskExecution) getOptionsMap() (options map[string]any) {
 		// set as string so that StreamProcessor parses it
 		options["transforms"] = g.Marshal(colTransforms)
 	}
+
+	if computed, _ := t.Config.ComputedColumns(); len(computed) > 0 {
+		// set as string so that StreamProcessor parses it
+		options["computed_columns"] = g.Marshal(computed)
+	}
+
+	if t.lookupsID != "" {
+		options["lookups_id"] = t.lookupsID
+	}
+
+	if t.rejects != nil {
+		options["reject_writer"] = t.rejects.ID
+	}
+
+	if t.distinct != nil {
+		options["distinct_counter"] = t.distinct.ID
+	}
 	return
 }
 
@@ -434,6 +459,88 @@ func applyColumnCasingToDf(df *iop.Dataflow, connType dbio.Type, casing *ColumnC
 	}
 }
 
+// applyColumnRenameToDf renames the dataflow columns, with a map of
+// source column name to new name
+func applyColumnRenameToDf(df *iop.Dataflow, renames map[string]string) error {
+	renamed := map[string]string{} // lower source name => new name
+	for name, newName := range renames {
+		renamed[strings.ToLower(name)] = newName
+	}
+
+	fieldMap := df.Columns.FieldMap(true)
+	for name, newName := range renames {
+		if _, ok := fieldMap[strings.ToLower(name)]; !ok {
+			g.Warn("column %s not found in source stream, cannot rename to %s", name, newName)
+		} else if i, exists := fieldMap[strings.ToLower(newName)]; exists && i != fieldMap[strings.ToLower(name)] {
+			if _, alsoRenamed := renamed[strings.ToLower(df.Columns[i].Name)]; !alsoRenamed {
+				return g.Error("cannot rename column %s to %s, column already exists", name, newName)
+			}
+		}
+	}
+
+	// rename each column once, since stream and batch columns may share the same array
+	done := map[*iop.Column]bool{}
+	rename := func(columns iop.Columns) {
+		for i := range columns {
+			if done[&columns[i]] {
+				continue
+			}
+			done[&columns[i]] = true
+			if newName, ok := renamed[strings.ToLower(columns[i].Name)]; ok {
+				columns[i].Name = newName
+			}
+		}
+	}
+
+	rename(df.Columns)
+
+	// propagate names
+	for _, ds := range df.Streams {
+		rename(ds.Columns)
+		if ds.CurrentBatch != nil {
+			rename(ds.CurrentBatch.Columns)
+		}
+	}
+
+	return nil
+}
+
+// applyColumnOrderToDf returns a dataflow with the columns in the provided order.
+// Listed columns come first, the others follow in their original order.
+// Streams are merged, so that rows are shaped into the new order.
+func applyColumnOrderToDf(df *iop.Dataflow, order []string) (*iop.Dataflow, error) {
+	fieldMap := df.Columns.FieldMap(true)
+	for _, name := range order {
+		if _, ok := fieldMap[strings.ToLower(name)]; !ok {
+			return df, g.Error("column %s in 'order' not found in source stream", name)
+		}
+	}
+
+	ordered := make(iop.Columns, 0, len(df.Columns))
+	for _, name := range order {
+		ordered = append(ordered, df.Columns[fieldMap[strings.ToLower(name)]])
+	}
+	for _, col := range df.Columns {
+		if !lo.ContainsBy(order, func(name string) bool { return strings.EqualFold(name, col.Name) }) {
+			ordered = append(ordered, col)
+		}
+	}
+	for i := range ordered {
+		ordered[i].Position = i + 1
+	}
+
+	// new slice, since the streams may share the previous one
+	df.Columns = ordered
+
+	merged, err := iop.MakeDataFlow(iop.MergeDataflow(df))
+	if err != nil {
+		return df, g.Error(err, "could not merge streams")
+	}
+	merged.Defer(df.CleanUp)
+
+	return merged, nil
+}
+
 func applyColumnCasing(name string, toSnake bool, connType dbio.Type) string {
 	// convert to snake case
 	if toSnake {
@@ -1,8 +1,10 @@
 package sling
 
 import (
+	"errors"
 	"math"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -126,6 +128,13 @@ func insertFromTemp(cfg *Config, tgtConn database.Connection) (err error) {
 		}
 	}
 
+	// columns not in the target table are not loaded
+	if se := cfg.Target.Options.SchemaEvolution; se != nil && *se == SchemaEvolutionIgnore {
+		tmpColumns = lo.Filter(tmpColumns, func(c iop.Column, i int) bool {
+			return tgtColumns.GetColumn(c.Name) != nil
+		})
+	}
+
 	// TODO: need to validate the source table types are casted
 	// into the target column type
 	tgtCols, err := tgtConn.ValidateColumnNames(
@@ -168,6 +177,645 @@ func insertFromTemp(cfg *Config, tgtConn database.Connection) (err error) {
 	return
 }
 
+// dedupeTempTable copies the temp table into a new table, keeping only the record
+// with the greatest update key for each primary key. Returns the new table and
+// the number of duplicate records dropped.
+func dedupeTempTable(cfg *Config, tgtConn database.Connection, tableTmp database.Table) (dedupeTable database.Table, dropped uint64, err error) {
+	tmpColumns, err := tgtConn.GetColumns(tableTmp.FullName())
+	if err != nil {
+		err = g.Error(err, "could not get column list for "+tableTmp.FullName())
+		return
+	}
+
+	fieldCols, err := tgtConn.ValidateColumnNames(tmpColumns, tmpColumns.Names(), true)
+	if err != nil {
+		err = g.Error(err, "could not validate columns of "+tableTmp.FullName())
+		return
+	}
+
+	pkCols, err := tgtConn.ValidateColumnNames(tmpColumns, cfg.TargetPrimaryKey(), true)
+	if err != nil {
+		err = g.Error(err, "primary key columns not found in "+tableTmp.FullName())
+		return
+	}
+
+	// nulls are sorted last, so that any value wins over a null update key
+	orderBy := pkCols.Names()
+	if cfg.Source.UpdateKey != "" {
+		ukCols, err := tgtConn.ValidateColumnNames(tmpColumns, []string{cfg.TargetUpdateKey()}, true)
+		if err != nil {
+			return dedupeTable, 0, g.Error(err, "update key column not found in "+tableTmp.FullName())
+		}
+		ukField := ukCols[0].Name
+		orderBy = []string{g.F("case when %s is null then 1 else 0 end", ukField), ukField + " desc"}
+	} else {
+		g.Warn("no update_key specified to dedupe with, keeping an arbitrary record per primary key")
+	}
+
+	dedupeTable = tableTmp
+	if tgtConn.GetType() == dbio.TypeDbOracle && len(dedupeTable.Name) > 27 {
+		dedupeTable.Name = dedupeTable.Name[:27] // max is 30 chars
+	}
+	dedupeTable.Name = dedupeTable.Name + lo.Ternary(tgtConn.GetType().DBNameUpperCase(), "_DD", "_dd")
+	dedupeTable.DDL = strings.Replace(tableTmp.DDL, tableTmp.FullName(), dedupeTable.FullName(), 1)
+	dedupeTable.Raw = dedupeTable.FullName()
+
+	err = tgtConn.DropTable(dedupeTable.FullName())
+	if err != nil {
+		err = g.Error(err, "could not drop table "+dedupeTable.FullName())
+		return
+	}
+
+	_, err = tgtConn.ExecMulti(dedupeTable.DDL)
+	if err != nil {
+		err = g.Error(err, "could not create table "+dedupeTable.FullName())
+		return
+	}
+
+	fields := strings.Join(fieldCols.Names(), ", ")
+	sql := g.R(
+		tgtConn.GetTemplateValue("core.insert_from_table_dedupe"),
+		"tgt_table", dedupeTable.FullName(),
+		"src_table", tableTmp.FullName(),
+		"tgt_fields", fields,
+		"src_fields", fields,
+		"pk_fields", strings.Join(pkCols.Names(), ", "),
+		"order_by", strings.Join(orderBy, ", "),
+		"rn_field", tgtConn.Quote("_sling_row_rank"),
+	)
+	_, err = tgtConn.Exec(sql)
+	if err != nil {
+		err = g.Error(err, "could not dedupe temp table")
+		return
+	}
+
+	srcCnt, err := tgtConn.GetCount(tableTmp.FullName())
+	if err != nil {
+		err = g.Error(err, "could not get count of "+tableTmp.FullName())
+		return
+	}
+
+	dedupeCnt, err := tgtConn.GetCount(dedupeTable.FullName())
+	if err != nil {
+		err = g.Error(err, "could not get count of "+dedupeTable.FullName())
+		return
+	}
+
+	if srcCnt > dedupeCnt {
+		dropped = srcCnt - dedupeCnt
+	}
+
+	return
+}
+
+// evolveSchema compares the stream columns with the columns of the existing target table,
+// and applies the schema evolution policy. New columns and type widening are applied when
+// preparing the final table, according to `add_new_columns` and `adjust_column_type`, which
+// are set from the policy. The changes not applied are recorded on the task execution, the
+// applied ones are recorded with recordSchemaChanges.
+func (t *TaskExecution) evolveSchema(cfg *Config, tgtConn database.Connection, targetTable database.Table, tgtColumns iop.Columns, df *iop.Dataflow) (err error) {
+	policy := *cfg.Target.Options.SchemaEvolution
+	changes := []SchemaChange{}
+
+	// new columns
+	added := tgtColumns.GetMissing(df.Columns...)
+	for _, col := range added {
+		change := SchemaChange{Table: targetTable.FullName(), Column: col.Name, Change: "added", NewType: string(col.Type)}
+		change.Action = lo.Ternary(g.In(policy, SchemaEvolutionAdd, SchemaEvolutionWiden), "applied", "ignored")
+		changes = append(changes, change)
+	}
+
+	// type changes, determined with a dry run of the table optimization
+	tgtColMap := lo.KeyBy(tgtColumns, func(c iop.Column) string { return strings.ToLower(c.Name) })
+	commonColumns := lo.Filter(df.Columns, func(c iop.Column, i int) bool {
+		_, ok := tgtColMap[strings.ToLower(c.Name)]
+		return ok
+	})
+
+	dryTable := targetTable
+	dryTable.Columns = tgtColumns.Clone()
+	_, _, err = database.GetOptimizeTableStatements(tgtConn, &dryTable, commonColumns, false)
+	if err != nil {
+		return g.Error(err, "could not determine column type changes for "+targetTable.FullName())
+	}
+
+	for i, col := range dryTable.Columns {
+		if col.Type == tgtColumns[i].Type {
+			continue
+		}
+		change := SchemaChange{Table: targetTable.FullName(), Column: col.Name, Change: "changed", OldType: string(tgtColumns[i].Type), NewType: string(col.Type)}
+		// a full-refresh recreates the table with the new types
+		change.Action = lo.Ternary(policy == SchemaEvolutionWiden || cfg.Mode == FullRefreshMode, "applied", "ignored")
+		changes = append(changes, change)
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	descriptions := lo.Map(changes, func(c SchemaChange, i int) string {
+		if c.Change == "added" {
+			return g.F("column %s (%s) added", c.Column, c.NewType)
+		}
+		return g.F("column %s changed from %s to %s", c.Column, c.OldType, c.NewType)
+	})
+
+	if policy == SchemaEvolutionFail {
+		for i := range changes {
+			changes[i].Action = "failed"
+		}
+		t.SchemaChanges = append(t.SchemaChanges, changes...)
+		return g.Error("schema_evolution is 'fail', and the stream schema differs from %s:\n  - %s", targetTable.FullName(), strings.Join(descriptions, "\n  - "))
+	}
+
+	for i, change := range changes {
+		g.Debug("schema change on %s: %s [%s]", targetTable.FullName(), descriptions[i], change.Action)
+		if change.Action != "applied" {
+			t.SchemaChanges = append(t.SchemaChanges, change)
+		}
+	}
+	t.SetProgress("detected %d schema changes on %s (schema_evolution: %s)", len(changes), targetTable.FullName(), policy)
+
+	// unknown columns stay in the temp table, but are not loaded into the target
+	if policy == SchemaEvolutionIgnore && len(added) > 0 {
+		addedMap := lo.KeyBy(added, func(c iop.Column) string { return strings.ToLower(c.Name) })
+		df.Columns = lo.Filter(df.Columns, func(c iop.Column, i int) bool {
+			_, ok := addedMap[strings.ToLower(c.Name)]
+			return !ok
+		})
+
+		// for upserts
+		tgtConn.SetProp("ignore_new_columns", "true")
+
+		// the temp table is swapped into place, so it must not have them
+		if cfg.Mode == FullRefreshMode && cfg.Target.Options.SwapTable != nil && *cfg.Target.Options.SwapTable {
+			for _, col := range added {
+				sql := g.R(
+					tgtConn.GetTemplateValue("core.drop_column"),
+					"table", cfg.Target.Options.TableTmp,
+					"column", tgtConn.Quote(col.Name),
+				)
+				if _, err = tgtConn.Exec(sql); err != nil {
+					return g.Error(err, "could not drop column %s from %s", col.Name, cfg.Target.Options.TableTmp)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordSchemaChanges records the schema changes applied to the target table, by comparing
+// its columns before and after the load. This covers the columns added or changed with
+// `add_new_columns` and `adjust_column_type`, while streaming or when preparing the final
+// table, as well as the tables recreated or swapped into place. Only called with the
+// `schema_evolution` option, to spare the column queries of the other loads.
+func (t *TaskExecution) recordSchemaChanges(tgtConn database.Connection, targetTable database.Table, preColumns iop.Columns) (err error) {
+	postColumns, err := tgtConn.GetColumns(targetTable.FullName())
+	if err != nil {
+		return g.Error(err, "could not get column list for "+targetTable.FullName())
+	}
+
+	preColMap := lo.KeyBy(preColumns, func(c iop.Column) string { return strings.ToLower(c.Name) })
+	postColMap := lo.KeyBy(postColumns, func(c iop.Column) string { return strings.ToLower(c.Name) })
+
+	changes := []SchemaChange{}
+	for _, col := range postColumns {
+		preCol, ok := preColMap[strings.ToLower(col.Name)]
+		if !ok {
+			changes = append(changes, SchemaChange{Column: col.Name, Change: "added", NewType: string(col.Type)})
+		} else if preCol.Type != col.Type {
+			changes = append(changes, SchemaChange{Column: col.Name, Change: "changed", OldType: string(preCol.Type), NewType: string(col.Type)})
+		}
+	}
+	for _, col := range preColumns {
+		if _, ok := postColMap[strings.ToLower(col.Name)]; !ok {
+			changes = append(changes, SchemaChange{Column: col.Name, Change: "dropped", OldType: string(col.Type)})
+		}
+	}
+
+	for _, change := range changes {
+		change.Table = targetTable.FullName()
+		change.Action = "applied"
+		g.Debug("schema change on %s: column %s %s [%s]", change.Table, change.Column, change.Change, change.Action)
+		t.SchemaChanges = append(t.SchemaChanges, change)
+	}
+
+	return nil
+}
+
+// validateLoad reconciles the records loaded into the target table with the
+// source stream. `expected` is the number of records loaded from the temp table
+// and `preCnt` the target count before the load (for append modes).
+// Checksums are only compared when the target holds exactly the stream records.
+func validateLoad(cfg *Config, tgtConn database.Connection, targetTable database.Table, columns iop.Columns, lv LoadValidation, expected, preCnt uint64) (err error) {
+	replaced := cfg.Mode == FullRefreshMode || cfg.Mode == TruncateMode
+	appended := (cfg.Mode == IncrementalMode && len(cfg.Source.PrimaryKey()) == 0) || cfg.Mode == SnapshotMode
+
+	if lv.Count {
+		var tgtCnt uint64
+		switch {
+		case replaced:
+			tgtCnt, err = tgtConn.GetCount(targetTable.FullName())
+		case appended:
+			tgtCnt, err = tgtConn.GetCount(targetTable.FullName())
+			tgtCnt = tgtCnt - lo.Ternary(tgtCnt > preCnt, preCnt, tgtCnt)
+		default:
+			// merged, count the target records matching the primary keys of the temp table
+			tgtCnt, expected, err = countMergedRecords(cfg, tgtConn, targetTable)
+		}
+		if err != nil {
+			return g.Error(err, "could not get count for validation")
+		}
+
+		if tgtCnt != expected {
+			return g.Error("count validation failed for %s: target count (%d) != source count (%d)", targetTable.FullName(), tgtCnt, expected)
+		}
+		g.Debug("count validation passed for %s (%d records)", targetTable.FullName(), tgtCnt)
+	}
+
+	if lv.Checksum {
+		if !replaced {
+			g.Warn("checksum validation is only supported for full-refresh and truncate modes, skipping")
+			return nil
+		} else if len(columns) == 0 || uint64(columns[0].Stats.TotalCnt) != expected {
+			// rows were skipped, rejected or deduped after stats were collected
+			g.Warn("checksum validation skipped for %s: stream stats do not match the loaded records", targetTable.FullName())
+			return nil
+		}
+
+		err = tgtConn.CompareChecksums(targetTable.FullName(), columns)
+		if err != nil {
+			return g.Error(err, "checksum validation failed for %s", targetTable.FullName())
+		}
+		g.Debug("checksum validation passed for %s", targetTable.FullName())
+	}
+
+	return nil
+}
+
+// countMergedRecords returns the number of target records with a primary key
+// in the temp table, and the number of distinct primary keys in the temp table
+func countMergedRecords(cfg *Config, tgtConn database.Connection, targetTable database.Table) (tgtCnt, tmpCnt uint64, err error) {
+	tmpColumns, err := tgtConn.GetColumns(cfg.Target.Options.TableTmp)
+	if err != nil {
+		err = g.Error(err, "could not get column list for "+cfg.Target.Options.TableTmp)
+		return
+	}
+
+	pkCols, err := tgtConn.ValidateColumnNames(tmpColumns, cfg.TargetPrimaryKey(), true)
+	if err != nil {
+		err = g.Error(err, "primary key columns not found in "+cfg.Target.Options.TableTmp)
+		return
+	}
+
+	pkFields := pkCols.Names()
+	joins := lo.Map(pkFields, func(f string, i int) string { return g.F("t.%s = s.%s", f, f) })
+
+	sql := g.F(
+		"select count(*) cnt from %s t where exists (select 1 from %s s where %s)",
+		targetTable.FullName(), cfg.Target.Options.TableTmp, strings.Join(joins, " and "),
+	)
+	data, err := tgtConn.Query(sql)
+	if err != nil {
+		err = g.Error(err, "could not count merged records")
+		return
+	} else if len(data.Rows) > 0 {
+		tgtCnt = cast.ToUint64(data.Rows[0][0])
+	}
+
+	sql = g.F(
+		"select count(*) cnt from (select distinct %s from %s) t",
+		strings.Join(pkFields, ", "), cfg.Target.Options.TableTmp,
+	)
+	data, err = tgtConn.Query(sql)
+	if err != nil {
+		err = g.Error(err, "could not count distinct primary keys")
+		return
+	} else if len(data.Rows) > 0 {
+		tmpCnt = cast.ToUint64(data.Rows[0][0])
+	}
+
+	return
+}
+
+// checkExpectations evaluates the dataset expectations of the stream. Row counts,
+// nulls and distinct values are taken from the stream column stats when available,
+// the other expectations are queried from the target table (database targets only,
+// tgtConn is nil otherwise).
+// Returns an error if an expectation with severity `fail` did not pass.
+func (t *TaskExecution) checkExpectations(expectations []Expectation, tgtConn database.Connection, targetTable database.Table, columns iop.Columns, cnt uint64) (err error) {
+	tableName := lo.Ternary(tgtConn != nil, targetTable.FullName(), t.Config.Target.Object)
+
+	t.Expectations = []ExpectationResult{}
+	failed := []string{}
+	for _, e := range expectations {
+		result := ExpectationResult{Table: tableName, Name: e.Name(), Type: string(e.Type), Severity: e.Severity}
+
+		passed, observed, evalErr := evaluateExpectation(e, tgtConn, targetTable, columns, cnt)
+		switch {
+		case evalErr == errExpectationSkipped:
+			result.Status = "skipped"
+			result.Message = g.F("'%s' only applies to database targets", e.Type)
+			g.Warn("expectation %s skipped: %s", result.Name, result.Message)
+		case evalErr != nil:
+			result.Status = e.Severity
+			result.Message = evalErr.Error()
+		case passed:
+			result.Status = "pass"
+		default:
+			result.Status = e.Severity
+			result.Message = g.F("expectation %s not met (observed %s)", result.Name, observed)
+		}
+		result.Observed = observed
+
+		if result.Status == "warn" {
+			g.Warn("expectation %s on %s: %s", result.Name, tableName, result.Message)
+		} else if result.Status == "fail" {
+			failed = append(failed, result.Name)
+			g.Warn("expectation %s on %s failed: %s", result.Name, tableName, result.Message)
+		} else {
+			g.Debug("expectation %s on %s: %s (observed %s)", result.Name, tableName, result.Status, observed)
+		}
+
+		t.Expectations = append(t.Expectations, result)
+	}
+
+	counts := lo.CountValuesBy(t.Expectations, func(r ExpectationResult) string { return r.Status })
+	t.SetProgress(
+		"expectations: %d passed, %d warned, %d failed",
+		counts["pass"], counts["warn"], counts["fail"],
+	)
+
+	if len(failed) > 0 {
+		return g.Error("expectations failed for %s: %s", tableName, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// checkRowAnomaly compares the number of rows loaded with the row counts of the
+// previous successful runs of the stream, and adds the result to the expectation
+// results. Returns an error if the count is anomalous and the severity is `fail`.
+func (t *TaskExecution) checkRowAnomaly(ac RowAnomalyCheck, tableName string, cnt uint64) (err error) {
+	history, err := StoreRowHistory(t, ac.History)
+	if err != nil {
+		g.Warn("could not get row history for anomaly check: %s", err.Error())
+		return nil
+	} else if len(history) < ac.MinHistory {
+		g.Debug("skipping anomaly check: found %d previous runs, need %d", len(history), ac.MinHistory)
+		return nil
+	}
+
+	anomalous, observed := detectRowAnomaly(ac, cnt, history)
+	result := ExpectationResult{
+		Table:    tableName,
+		Name:     g.F("anomaly_check(%s)", ac.Method),
+		Type:     "anomaly_check",
+		Severity: ac.Severity,
+		Status:   "pass",
+		Observed: observed,
+	}
+
+	if anomalous {
+		result.Status = ac.Severity
+		result.Message = "row count anomaly: " + observed
+		g.Warn("%s on %s", result.Message, tableName)
+	} else {
+		g.Debug("anomaly check passed on %s: %s", tableName, observed)
+	}
+	t.Expectations = append(t.Expectations, result)
+
+	if anomalous && ac.Severity == "fail" {
+		return g.Error("anomaly check failed for %s: %s", tableName, observed)
+	}
+	return nil
+}
+
+// detectRowAnomaly determines if the current row count is outside the range
+// expected from the history: the median ± threshold * MAD (scaled, with a floor
+// of 10% of the median for steady histories), or the median ± threshold percent.
+// A drop to zero or a 10x spike is always anomalous.
+func detectRowAnomaly(ac RowAnomalyCheck, current uint64, history []uint64) (anomalous bool, observed string) {
+	median := func(values []float64) float64 {
+		sorted := append([]float64{}, values...)
+		sort.Float64s(sorted)
+		if n := len(sorted); n == 0 {
+			return 0
+		} else if n%2 == 1 {
+			return sorted[n/2]
+		} else {
+			return (sorted[n/2-1] + sorted[n/2]) / 2
+		}
+	}
+
+	values := lo.Map(history, func(v uint64, i int) float64 { return float64(v) })
+	baseline := median(values)
+	cur := float64(current)
+
+	var low, high float64
+	switch ac.Method {
+	case "pct_change":
+		low = baseline * (1 - ac.Threshold/100)
+		high = baseline * (1 + ac.Threshold/100)
+	default:
+		deviations := lo.Map(values, func(v float64, i int) float64 { return math.Abs(v - baseline) })
+		spread := math.Max(1.4826*median(deviations), 0.1*baseline)
+		low = baseline - ac.Threshold*spread
+		high = baseline + ac.Threshold*spread
+	}
+	low = math.Max(low, 0)
+
+	anomalous = cur < low || cur > high
+	if baseline > 0 && (cur == 0 || cur >= 10*baseline) {
+		anomalous = true
+	}
+
+	observed = g.F(
+		"%d rows, expected %d to %d (median of last %d runs: %d)",
+		current, int64(math.Ceil(low)), int64(math.Floor(high)), len(history), int64(baseline),
+	)
+	return
+}
+
+// checkDataQuality evaluates the dataset expectations and the row anomaly check
+// after the load, with the synced stats of the stream columns. tgtConn is nil
+// for file targets.
+func (t *TaskExecution) checkDataQuality(cfg *Config, tgtConn database.Connection, targetTable database.Table, columns iop.Columns, cnt uint64) (err error) {
+	expectations, _ := cfg.DatasetExpectations()
+	anomalyCheck, _ := cfg.RowAnomalyCheck()
+	if len(expectations) == 0 && !anomalyCheck.Enabled {
+		return nil
+	}
+
+	setStage("5 - data-quality")
+	tableName := lo.Ternary(tgtConn != nil, targetTable.FullName(), cfg.Target.Object)
+
+	eG := g.ErrorGroup{}
+	if len(expectations) > 0 {
+		eG.Capture(t.checkExpectations(expectations, tgtConn, targetTable, columns, cnt))
+	}
+	if anomalyCheck.Enabled {
+		eG.Capture(t.checkRowAnomaly(anomalyCheck, tableName, cnt))
+	}
+
+	return eG.Err()
+}
+
+var errExpectationSkipped = errors.New("expectation skipped")
+
+// statsColumns returns the stream columns with the provided names, if they
+// all have stats collected while streaming
+func statsColumns(columns iop.Columns, names []string) (cols iop.Columns, ok bool) {
+	for _, name := range names {
+		col := columns.GetColumn(name)
+		if col == nil || col.Stats.TotalCnt == 0 {
+			return nil, false
+		}
+		cols = append(cols, *col)
+	}
+	return cols, len(cols) > 0
+}
+
+// evaluateExpectation evaluates one expectation, returning whether it passed
+// and the observed value
+func evaluateExpectation(e Expectation, tgtConn database.Connection, targetTable database.Table, columns iop.Columns, cnt uint64) (passed bool, observed string, err error) {
+	// stream stats based
+	switch e.Type {
+	case ExpectRowCount:
+		observed = cast.ToString(cnt)
+		passed = (e.Min == nil || int64(cnt) >= *e.Min) && (e.Max == nil || int64(cnt) <= *e.Max)
+		return
+
+	case ExpectNotNull:
+		if cols, ok := statsColumns(columns, e.Columns); ok {
+			nullCounts := []string{}
+			for _, col := range cols {
+				if col.Stats.NullCnt > 0 {
+					nullCounts = append(nullCounts, g.F("%s: %d nulls", col.Name, col.Stats.NullCnt))
+				}
+			}
+			observed = lo.Ternary(len(nullCounts) > 0, strings.Join(nullCounts, ", "), "0 nulls")
+			return len(nullCounts) == 0, observed, nil
+		}
+
+	case ExpectUnique:
+		// distinct values are only counted for single columns, see DistinctColumns
+		if cols, ok := statsColumns(columns, e.Columns); ok && len(cols) == 1 && cols[0].Stats.UniqCnt > 0 {
+			stats := cols[0].Stats
+			distinctCnt := stats.UniqCnt + lo.Ternary(stats.NullCnt > 0, int64(1), 0) // nulls are one group
+			dupCnt := stats.TotalCnt - distinctCnt
+			return dupCnt == 0, g.F("%d duplicate values", dupCnt), nil
+		}
+	}
+
+	// target query based
+	if tgtConn == nil {
+		return false, "", errExpectationSkipped
+	}
+
+	tgtColumns, err := tgtConn.GetColumns(targetTable.FullName())
+	if err != nil {
+		return false, "", g.Error(err, "could not get columns of %s", targetTable.FullName())
+	}
+
+	cols, err := tgtConn.ValidateColumnNames(tgtColumns, e.Columns, true)
+	if err != nil {
+		return false, "", g.Error(err, "columns not found for expectation %s", e.Name())
+	}
+	fields := cols.Names()
+
+	queryValue := func(sql string) (val any, err error) {
+		data, err := tgtConn.Query(sql)
+		if err != nil {
+			return nil, g.Error(err, "could not evaluate expectation %s", e.Name())
+		} else if len(data.Rows) > 0 && len(data.Rows[0]) > 0 {
+			val = data.Rows[0][0]
+		}
+		return
+	}
+
+	switch e.Type {
+	case ExpectNotNull:
+		nullExprs := lo.Map(fields, func(field string, i int) string {
+			return g.F("sum(case when %s is null then 1 else 0 end) as null_cnt_%d", field, i)
+		})
+		data, err := tgtConn.Query(g.F("select %s from %s", strings.Join(nullExprs, ", "), targetTable.FullName()))
+		if err != nil {
+			return false, "", g.Error(err, "could not evaluate expectation %s", e.Name())
+		}
+
+		nullCounts := []string{}
+		for i, name := range e.Columns {
+			if len(data.Rows) > 0 && i < len(data.Rows[0]) {
+				if nullCnt := cast.ToInt64(data.Rows[0][i]); nullCnt > 0 {
+					nullCounts = append(nullCounts, g.F("%s: %d nulls", name, nullCnt))
+				}
+			}
+		}
+		observed = lo.Ternary(len(nullCounts) > 0, strings.Join(nullCounts, ", "), "0 nulls")
+		return len(nullCounts) == 0, observed, nil
+
+	case ExpectUnique:
+		sql := g.F(
+			"select count(*) cnt from (select %s from %s group by %s having count(*) > 1) t",
+			strings.Join(fields, ", "), targetTable.FullName(), strings.Join(fields, ", "),
+		)
+		val, err := queryValue(sql)
+		if err != nil {
+			return false, "", err
+		}
+		dupCnt := cast.ToInt64(val)
+		return dupCnt == 0, g.F("%d duplicate keys", dupCnt), nil
+
+	case ExpectAcceptedValues:
+		values := lo.Map(e.Values, func(v string, i int) string {
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		})
+		where := g.F(
+			"%s is not null and cast(%s as %s) not in (%s)",
+			fields[0], fields[0], tgtConn.Template().Function["string_type"], strings.Join(values, ", "),
+		)
+		val, err := queryValue(g.F("select count(*) cnt from %s where %s", targetTable.FullName(), where))
+		if err != nil {
+			return false, "", err
+		}
+
+		invalidCnt := cast.ToInt64(val)
+		observed = g.F("%d invalid values", invalidCnt)
+		if invalidCnt > 0 {
+			sql := g.R(
+				tgtConn.GetTemplateValue("core.limit_sql"),
+				"sql", g.F("select distinct %s as val from %s where %s", fields[0], targetTable.FullName(), where),
+				"limit", "5",
+				"offset", "0",
+			)
+			if data, err := tgtConn.Query(sql); err == nil {
+				samples := lo.Map(data.Rows, func(row []any, i int) string { return cast.ToString(row[0]) })
+				observed = g.F("%s, such as: %s", observed, strings.Join(samples, ", "))
+			}
+		}
+		return invalidCnt == 0, observed, nil
+
+	case ExpectFreshness:
+		val, err := queryValue(g.F("select max(%s) max_val from %s", fields[0], targetTable.FullName()))
+		if err != nil {
+			return false, "", err
+		} else if val == nil {
+			return false, "no values", nil
+		}
+
+		maxTime, err := cast.ToTimeE(val)
+		if err != nil {
+			return false, cast.ToString(val), g.Error(err, "could not parse max value of %s as a timestamp", e.Columns[0])
+		}
+
+		age := time.Since(maxTime).Round(time.Second)
+		return age <= e.MaxAge, g.F("latest %s (age %s)", maxTime.Format(time.RFC3339), age), nil
+	}
+
+	return false, "", g.Error("unhandled expectation type: %s", e.Type)
+}
+
 func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnVarMap map[string]string) (err error) {
 	// get table columns type for table creation if not exists
 	// in order to get max value
@@ -179,7 +827,7 @@ func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnVarMap
 		return
 	}
 
-	tgtUpdateKey := cfg.Source.UpdateKey
+	tgtUpdateKey := cfg.TargetUpdateKey()
 	if cc := cfg.Target.Options.ColumnCasing; cc != nil && *cc != SourceColumnCasing {
 		tgtUpdateKey = applyColumnCasing(tgtUpdateKey, *cc == SnakeColumnCasing, tgtConn.GetType())
 	}
@@ -230,7 +878,15 @@ func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnVarMap
 	if cfg.IncrementalVal == nil {
 		// if is null, don't set IncrementalValStr
 		return nil
-	} else if colType.IsDate() || isOracleDate {
+	}
+
+	// apply lookback window to watermark, to re-read late-arriving records
+	err = applyLookback(cfg, colType)
+	if err != nil {
+		return g.Error(err, "could not apply lookback")
+	}
+
+	if colType.IsDate() || isOracleDate {
 		cfg.IncrementalValStr = g.R(
 			srcConnVarMap["date_layout_str"],
 			"value", cast.ToTime(cfg.IncrementalVal).Format(srcConnVarMap["date_layout"]),
@@ -265,6 +921,80 @@ func getIncrementalValue(cfg *Config, tgtConn database.Connection, srcConnVarMap
 	return
 }
 
+// applyLookback subtracts the `lookback` source option from the incremental value.
+// For date/datetime keys, the lookback is a duration (e.g. `30m`, `2h`, `7d`).
+// For numeric keys, the lookback is a number (e.g. `500`), or a duration which
+// is converted to seconds (useful for unix epoch keys such as `_sling_loaded_at`).
+func applyLookback(cfg *Config, colType iop.ColumnType) (err error) {
+	if cfg.Source.Options == nil || cfg.Source.Options.Lookback == nil || cfg.IncrementalVal == nil {
+		return nil
+	}
+
+	lookback := strings.TrimSpace(*cfg.Source.Options.Lookback)
+	if lookback == "" {
+		return nil
+	}
+
+	switch {
+	case colType.IsDate() || colType.IsDatetime():
+		duration, err := parseLookbackDuration(lookback)
+		if err != nil {
+			return g.Error(err, "invalid lookback duration for update_key %s: %s", cfg.Source.UpdateKey, lookback)
+		}
+
+		valTime, err := cast.ToTimeE(cfg.IncrementalVal)
+		if err != nil {
+			return g.Error(err, "could not cast incremental value to time: %#v", cfg.IncrementalVal)
+		}
+		cfg.IncrementalVal = valTime.Add(-duration)
+
+	case colType.IsNumber():
+		var delta float64
+		if num, err := cast.ToFloat64E(lookback); err == nil {
+			delta = num
+		} else if duration, err := parseLookbackDuration(lookback); err == nil {
+			delta = duration.Seconds()
+		} else {
+			return g.Error("invalid lookback value for numeric update_key %s: %s", cfg.Source.UpdateKey, lookback)
+		}
+
+		if colType.IsInteger() {
+			cfg.IncrementalVal = cast.ToInt64(cfg.IncrementalVal) - cast.ToInt64(math.Ceil(delta))
+		} else {
+			cfg.IncrementalVal = cast.ToFloat64(cfg.IncrementalVal) - delta
+		}
+
+	default:
+		g.Warn("lookback is not supported for update_key %s of type %s, ignoring", cfg.Source.UpdateKey, colType)
+		return nil
+	}
+
+	g.Debug("applied lookback of %s to incremental value => %#v", lookback, cfg.IncrementalVal)
+
+	return nil
+}
+
+// parseLookbackDuration parses a duration, adding support for days (`d`) and weeks (`w`)
+func parseLookbackDuration(s string) (duration time.Duration, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			num, err := cast.ToFloat64E(strings.TrimSuffix(s, suffix))
+			if err != nil {
+				return 0, g.Error(err, "invalid duration: %s", s)
+			}
+			return time.Duration(num * float64(unit)), nil
+		}
+	}
+
+	duration, err = time.ParseDuration(s)
+	if err != nil {
+		return 0, g.Error(err, "invalid duration: %s", s)
+	}
+	return duration, nil
+}
+
 func getRate(cnt uint64) string {
 	return humanize.Commaf(math.Round(cast.ToFloat64(cnt) / time.Since(start).Seconds()))
 }
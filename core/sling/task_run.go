@@ -16,6 +16,7 @@ import (
 	"github.com/flarco/g"
 	"github.com/slingdata-io/sling-cli/core/dbio"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
+	"github.com/slingdata-io/sling-cli/core/dbio/iop"
 	"github.com/slingdata-io/sling-cli/core/env"
 	"github.com/spf13/cast"
 )
@@ -29,6 +30,7 @@ var slingLoadedAtColumn = "_sling_loaded_at"
 var slingStreamURLColumn = "_sling_stream_url"
 var slingRowNumColumn = "_sling_row_num"
 var slingRowIDColumn = "_sling_row_id"
+var slingUpdateKeyColumn = "_sling_update_key"
 
 func init() {
 	// we need a webserver to get the pprof webserver
@@ -90,6 +92,29 @@ func (t *TaskExecution) Execute() error {
 		g.Debug("using source options: %s", g.Marshal(t.Config.Source.Options))
 		g.Debug("using target options: %s", g.Marshal(t.Config.Target.Options))
 
+		// load the reference streams of lookups
+		if lookups, err := t.loadLookups(); err != nil {
+			t.Err = g.Error(err, "could not load lookups")
+			StoreUpdate(t)
+			return
+		} else if len(lookups) > 0 {
+			t.lookupsID = iop.RegisterLookups(lookups)
+			defer iop.UnregisterLookups(t.lookupsID)
+		}
+
+		// count the distinct values of the `unique` expectations columns
+		if columns := t.Config.DistinctColumns(); len(columns) > 0 {
+			t.distinct = iop.NewDistinctCounter(columns)
+			defer t.distinct.Close()
+		}
+
+		// stream the rejected rows into `reject_to`
+		if t.rejects, t.Err = t.makeRejectWriter(); t.Err != nil {
+			t.Err = g.Error(t.Err, "could not prepare reject_to")
+			StoreUpdate(t)
+			return
+		}
+
 		switch t.Type {
 		case DbSQL:
 			t.Err = t.runDbSQL()
@@ -106,6 +131,18 @@ func (t *TaskExecution) Execute() error {
 			t.Err = g.Error("Cannot Execute. Task Type is not specified")
 		}
 
+		// finish writing rejected rows, also if the task failed
+		if err := t.closeRejects(); err != nil {
+			if t.Err == nil {
+				t.Err = g.Error(err, "could not write rejected rows")
+			} else {
+				g.Warn("could not write rejected rows: %s", err.Error())
+			}
+		}
+
+		// summarize the values which could not be cast
+		t.warnCastErrors()
+
 		// update into store
 		StoreUpdate(t)
 	}()
@@ -144,6 +181,11 @@ func (t *TaskExecution) Execute() error {
 	now2 := time.Now()
 	t.EndTime = &now2
 
+	// send the notifications of the project, e.g. on failure or anomaly
+	if t.Project != nil {
+		t.Project.Notify(t)
+	}
+
 	return t.Err
 }
 
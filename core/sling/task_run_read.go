@@ -2,12 +2,14 @@ package sling
 
 import (
 	"bufio"
+	"context"
 	"os"
 	"strings"
 
 	"github.com/flarco/g"
 	"github.com/samber/lo"
 	"github.com/slingdata-io/sling-cli/core/dbio"
+	"github.com/slingdata-io/sling-cli/core/dbio/connection"
 	"github.com/slingdata-io/sling-cli/core/dbio/database"
 	"github.com/slingdata-io/sling-cli/core/dbio/filesys"
 	"github.com/slingdata-io/sling-cli/core/dbio/iop"
@@ -72,6 +74,23 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		selectFieldsStr = strings.Join(fields, ", ")
 	}
 
+	// materialize a composite / expression update key into a column
+	// so that the target max value can be read in later runs
+	tableStr, fieldsStr := sTable.FDQN(), selectFieldsStr
+	materializeUpdateKey := cfg.Source.HasUpdateKeyExpr() && !sTable.IsQuery()
+	if materializeUpdateKey {
+		tableStr = tableStr + " t"
+		fieldsStr = g.F(
+			"%s, %s as %s",
+			lo.Ternary(selectFieldsStr == "*", "t.*", selectFieldsStr),
+			cfg.Source.UpdateKeySQL(srcConn),
+			srcConn.Quote(cfg.Source.UpdateKey, false),
+		)
+	} else if cfg.Source.HasUpdateKeyExpr() && sTable.Columns.GetColumn(cfg.Source.UpdateKey) == nil {
+		err = g.Error("Since using custom SQL with a list or expression as `update_key`, the SQL text needs to select the expression as column `%s`", cfg.Source.UpdateKey)
+		return t.df, err
+	}
+
 	if t.isIncrementalWithUpdateKey() || t.Config.Mode == BackfillMode {
 		// default true value
 		incrementalWhereCond := "1=1"
@@ -83,11 +102,32 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 			cfg.Source.UpdateKey = updateCol.Name // overwrite with correct casing
 		}
 
+		// the update key expression to use in where conditions
+		updateKeyExpr := srcConn.Quote(cfg.Source.UpdateKey, false)
+		if cfg.Source.HasUpdateKeyExpr() {
+			updateKeyExpr = cfg.Source.UpdateKeySQL(srcConn)
+		}
+
+		if materializeUpdateKey {
+			// get the type of the computed update key
+			keyTable := database.Table{
+				SQL:     g.F("select %s as %s from %s", updateKeyExpr, srcConn.Quote(cfg.Source.UpdateKey, false), tableStr),
+				Dialect: srcConn.GetType(),
+			}
+			keyCols, err := srcConn.GetSQLColumns(keyTable)
+			if err != nil {
+				err = g.Error(err, "Could not get type of update_key expression: %s", updateKeyExpr)
+				return t.df, err
+			} else if len(keyCols) > 0 {
+				updateCol = &keyCols[0]
+			}
+		}
+
 		// select only records that have been modified after last max value
 		if cfg.IncrementalVal != nil {
 			incrementalWhereCond = g.R(
 				srcConn.GetTemplateValue("core.incremental_where"),
-				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
+				"update_key", updateKeyExpr,
 				"value", cfg.IncrementalValStr,
 				"gt", ">",
 			)
@@ -98,6 +138,11 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		}
 
 		if t.Config.Mode == BackfillMode {
+			if updateCol == nil {
+				err = g.Error("Could not find update_key column %s in source stream", cfg.Source.UpdateKey)
+				return t.df, err
+			}
+
 			rangeArr := strings.Split(*cfg.Source.Options.Range, ",")
 			startValue := rangeArr[0]
 			endValue := rangeArr[1]
@@ -124,7 +169,7 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 
 			incrementalWhereCond = g.R(
 				srcConn.GetTemplateValue("core.backfill_where"),
-				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
+				"update_key", updateKeyExpr,
 				"start_value", startValue,
 				"end_value", endValue,
 			)
@@ -133,10 +178,10 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		if sTable.SQL == "" {
 			sTable.SQL = g.R(
 				srcConn.GetTemplateValue("core.incremental_select"),
-				"fields", selectFieldsStr,
-				"table", sTable.FDQN(),
+				"fields", fieldsStr,
+				"table", tableStr,
 				"incremental_where_cond", incrementalWhereCond,
-				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
+				"update_key", updateKeyExpr,
 			)
 		} else {
 			if !(strings.Contains(sTable.SQL, "{incremental_where_cond}") || strings.Contains(sTable.SQL, "{incremental_value}")) {
@@ -147,12 +192,16 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 			sTable.SQL = g.R(
 				sTable.SQL,
 				"incremental_where_cond", incrementalWhereCond,
-				"update_key", srcConn.Quote(cfg.Source.UpdateKey, false),
+				"update_key", updateKeyExpr,
 				"incremental_value", cfg.IncrementalValStr,
 			)
 		}
 	}
 
+	if materializeUpdateKey && sTable.SQL == "" {
+		sTable.SQL = g.F("select %s from %s", fieldsStr, tableStr)
+	}
+
 	if srcConn.GetType() == dbio.TypeDbBigTable {
 		srcConn.SetProp("start_time", t.Config.IncrementalValStr)
 	}
@@ -162,13 +211,18 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 
 	// construct select statement for selected fields
 	if selectFieldsStr != "*" || cfg.Source.Limit() > 0 {
-		sTable.SQL = sTable.Select(cfg.Source.Limit(), cfg.Source.Offset(), strings.Split(selectFieldsStr, ",")...)
+		fields := strings.Split(selectFieldsStr, ",")
+		if materializeUpdateKey && selectFieldsStr != "*" {
+			fields = append(fields, cfg.Source.UpdateKey) // keep materialized column
+		}
+		sTable.SQL = sTable.Select(cfg.Source.Limit(), cfg.Source.Offset(), fields...)
 	}
 
-	// set constraints
+	// set constraints and cast error policies
 	for _, col := range cfg.ColumnsPrepared() {
 		if c := sTable.Columns.GetColumn(col.Name); c != nil {
 			sTable.Columns[c.Position-1].Constraint = col.Constraint
+			sTable.Columns[c.Position-1].OnCastError = col.OnCastError
 		}
 	}
 
@@ -178,6 +232,30 @@ func (t *TaskExecution) ReadFromDB(cfg *Config, srcConn database.Connection) (df
 		return t.df, err
 	}
 
+	df, err = t.applyScript(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply script")
+		return t.df, err
+	}
+
+	df, err = t.applyPlugin(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply plugin")
+		return t.df, err
+	}
+
+	df, err = t.applyTransformSQL(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply transform sql")
+		return t.df, err
+	}
+
+	df, err = t.applyColumnMapping(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply column mapping")
+		return t.df, err
+	}
+
 	err = t.setColumnKeys(df)
 	if err != nil {
 		err = g.Error(err, "Could not set column keys")
@@ -247,6 +325,9 @@ func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error)
 		if ffmt := cfg.Source.Options.Format; ffmt != nil {
 			fsCfg.Format = *ffmt
 		}
+		if where := cfg.Source.Options.Where; where != nil {
+			fsCfg.Where = *where
+		}
 		df, err = fs.ReadDataflow(uri, fsCfg)
 		if err != nil {
 			err = g.Error(err, "Could not FileSysReadDataflow for %s", cfg.SrcConn.Type)
@@ -272,6 +353,30 @@ func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error)
 		return df, g.Error("Could not read columns")
 	}
 
+	df, err = t.applyScript(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply script")
+		return t.df, err
+	}
+
+	df, err = t.applyPlugin(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply plugin")
+		return t.df, err
+	}
+
+	df, err = t.applyTransformSQL(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply transform sql")
+		return t.df, err
+	}
+
+	df, err = t.applyColumnMapping(df)
+	if err != nil {
+		err = g.Error(err, "Could not apply column mapping")
+		return t.df, err
+	}
+
 	err = t.setColumnKeys(df)
 	if err != nil {
 		err = g.Error(err, "Could not set column keys")
@@ -284,6 +389,91 @@ func (t *TaskExecution) ReadFromFile(cfg *Config) (df *iop.Dataflow, err error)
 	return
 }
 
+// applyScript transforms the dataflow rows with the `script` option
+func (t *TaskExecution) applyScript(df *iop.Dataflow) (*iop.Dataflow, error) {
+	script, err := t.Config.TransformScript()
+	if err != nil || script == nil {
+		return df, err
+	}
+
+	ds, err := script.Run(iop.MergeDataflow(df))
+	if err != nil {
+		return df, g.Error(err, "could not run script")
+	}
+
+	scriptDf, err := iop.MakeDataFlow(ds)
+	if err != nil {
+		return df, g.Error(err, "could not make script dataflow")
+	}
+	scriptDf.Defer(df.CleanUp)
+
+	return scriptDf, nil
+}
+
+// applyPlugin pipes the dataflow rows through the `plugin` process
+func (t *TaskExecution) applyPlugin(df *iop.Dataflow) (*iop.Dataflow, error) {
+	plugin, err := t.Config.TransformPlugin()
+	if err != nil || plugin == nil {
+		return df, err
+	}
+
+	ds, err := plugin.Run(iop.MergeDataflow(df))
+	if err != nil {
+		return df, g.Error(err, "could not run plugin")
+	}
+
+	pluginDf, err := iop.MakeDataFlow(ds)
+	if err != nil {
+		return df, g.Error(err, "could not make plugin dataflow")
+	}
+	pluginDf.Defer(df.CleanUp)
+
+	return pluginDf, nil
+}
+
+// applyTransformSQL runs the `transform_sql` query on the dataflow rows
+func (t *TaskExecution) applyTransformSQL(df *iop.Dataflow) (*iop.Dataflow, error) {
+	sql, err := t.Config.TransformSQLText()
+	if err != nil || sql == "" {
+		return df, err
+	}
+
+	ds, err := iop.TransformSQL(iop.MergeDataflow(df), sql)
+	if err != nil {
+		return df, g.Error(err, "could not run transform sql")
+	}
+
+	sqlDf, err := iop.MakeDataFlow(ds)
+	if err != nil {
+		return df, g.Error(err, "could not make transform sql dataflow")
+	}
+	sqlDf.Defer(df.CleanUp)
+
+	return sqlDf, nil
+}
+
+// applyColumnMapping renames and reorders the dataflow columns
+// with the `rename` and `order` stream options
+func (t *TaskExecution) applyColumnMapping(df *iop.Dataflow) (*iop.Dataflow, error) {
+	renames, err := t.Config.ColumnRenames()
+	if err != nil {
+		return df, err
+	} else if len(renames) > 0 {
+		if err = applyColumnRenameToDf(df, renames); err != nil {
+			return df, err
+		}
+	}
+
+	order, err := t.Config.ColumnOrder()
+	if err != nil {
+		return df, err
+	} else if len(order) > 0 && len(df.Columns) > 0 {
+		return applyColumnOrderToDf(df, order)
+	}
+
+	return df, nil
+}
+
 // setColumnKeys sets the column keys
 func (t *TaskExecution) setColumnKeys(df *iop.Dataflow) (err error) {
 	eG := g.ErrorGroup{}
@@ -291,13 +481,13 @@ func (t *TaskExecution) setColumnKeys(df *iop.Dataflow) (err error) {
 	if t.Config.Source.HasPrimaryKey() {
 		// set true PK only when StarRocks, we don't want to create PKs on target table implicitly
 		if t.Config.Source.Type == dbio.TypeDbStarRocks {
-			eG.Capture(df.Columns.SetKeys(iop.PrimaryKey, t.Config.Source.PrimaryKey()...))
+			eG.Capture(df.Columns.SetKeys(iop.PrimaryKey, t.Config.TargetPrimaryKey()...))
 		}
-		eG.Capture(df.Columns.SetMetadata(iop.PrimaryKey.MetadataKey(), "source", t.Config.Source.PrimaryKey()...))
+		eG.Capture(df.Columns.SetMetadata(iop.PrimaryKey.MetadataKey(), "source", t.Config.TargetPrimaryKey()...))
 	}
 
 	if t.Config.Source.HasUpdateKey() {
-		eG.Capture(df.Columns.SetMetadata(iop.UpdateKey.MetadataKey(), "source", t.Config.Source.UpdateKey))
+		eG.Capture(df.Columns.SetMetadata(iop.UpdateKey.MetadataKey(), "source", t.Config.TargetUpdateKey()))
 	}
 
 	if tkMap := t.Config.Target.Options.TableKeys; tkMap != nil {
@@ -308,3 +498,89 @@ func (t *TaskExecution) setColumnKeys(df *iop.Dataflow) (err error) {
 
 	return eG.Err()
 }
+
+// loadLookups loads the reference streams of the `lookups` option in memory
+func (t *TaskExecution) loadLookups() (lookups []iop.Lookup, err error) {
+	columnLookups, err := t.Config.ColumnLookups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cl := range columnLookups {
+		data, err := readLookupData(cl)
+		if err != nil {
+			return nil, g.Error(err, "could not read reference stream of lookup %s", cl.Name)
+		}
+
+		lookup, err := iop.NewLookup(cl.Name, data, cl.Keys, cl.RefKeys, cl.Columns, cl.Names)
+		if err != nil {
+			return nil, g.Error(err, "could not prepare lookup %s", cl.Name)
+		}
+
+		lookup.OnUnmatched = cl.OnUnmatched
+		lookup.Defaults = make([]any, len(cl.Names))
+		for name, value := range cl.Default {
+			for i := range cl.Names {
+				if strings.EqualFold(cl.Names[i], name) {
+					lookup.Defaults[i] = value
+				}
+			}
+		}
+
+		g.Debug("loaded lookup %s with %d entries", cl.Name, len(lookup.Entries))
+		lookups = append(lookups, lookup)
+	}
+
+	return lookups, nil
+}
+
+// readLookupData reads the reference stream of a lookup: a table or SQL
+// query of a database connection, or a file
+func readLookupData(cl ColumnLookup) (data iop.Dataset, err error) {
+	ctx := context.Background()
+
+	var fs filesys.FileSysClient
+	uri := cl.Stream
+	if cl.Connection == "" {
+		if !strings.Contains(uri, "://") {
+			uri = "file://" + uri
+		}
+		if fs, err = filesys.NewFileSysClientFromURLContext(ctx, uri); err != nil {
+			return data, g.Error(err, "could not obtain client for %s", uri)
+		}
+	} else {
+		entry := connection.GetLocalConns().Get(cl.Connection)
+		if entry.Name == "" {
+			return data, g.Error("could not find connection %s", cl.Connection)
+		}
+		conn := entry.Connection
+
+		if conn.Type.IsDb() {
+			dbConn, err := conn.AsDatabase(false)
+			if err != nil {
+				return data, g.Error(err, "could not initialize connection %s", cl.Connection)
+			}
+			if err = dbConn.Connect(); err != nil {
+				return data, g.Error(err, "could not connect to %s", cl.Connection)
+			}
+			defer dbConn.Close()
+
+			table, err := database.ParseTableName(cl.Stream, dbConn.GetType())
+			if err != nil {
+				return data, g.Error(err, "could not parse stream %s", cl.Stream)
+			}
+			return dbConn.QueryContext(ctx, lo.Ternary(table.IsQuery(), table.SQL, table.Select(0, 0)))
+		}
+
+		if fs, err = conn.AsFile(false); err != nil {
+			return data, g.Error(err, "could not initialize connection %s", cl.Connection)
+		}
+		uri = filesys.NormalizeURI(fs, cl.Stream)
+	}
+
+	df, err := fs.ReadDataflow(uri)
+	if err != nil {
+		return data, g.Error(err, "could not read %s", uri)
+	}
+	return df.Collect()
+}
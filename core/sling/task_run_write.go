@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -30,6 +31,7 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 
 		if len(df.Buffer) == 0 && !cast.ToBool(os.Getenv("SLING_ALLOW_EMPTY")) {
 			g.Warn("No data or records found in stream. Nothing to do. To allow Sling to create empty files, set SLING_ALLOW_EMPTY=TRUE")
+			err = t.checkDataQuality(cfg, nil, database.Table{}, df.Columns, 0)
 			return
 		}
 
@@ -56,6 +58,12 @@ func (t *TaskExecution) WriteToFile(cfg *Config, df *iop.Dataflow) (cnt uint64,
 			return cnt, err
 		}
 		cnt = df.Count()
+
+		// evaluate expectations from stream stats, and row anomalies
+		df.SyncStats()
+		if err = t.checkDataQuality(cfg, nil, database.Table{}, df.Columns, cnt); err != nil {
+			return cnt, err
+		}
 	} else if cfg.Options.StdOut {
 		// apply column casing
 		applyColumnCasingToDf(df, dbio.TypeFileLocal, t.Config.Target.Options.ColumnCasing)
@@ -237,7 +245,10 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		return
 	}
 
-	_, err = createTableIfNotExists(tgtConn, sampleData, &tableTmp, true)
+	// when swapping, the temp table becomes the final table, so it cannot be temporary
+	swapTable := cfg.Mode == FullRefreshMode && cfg.Target.Options.SwapTable != nil && *cfg.Target.Options.SwapTable
+
+	_, err = createTableIfNotExists(tgtConn, sampleData, &tableTmp, !swapTable)
 	if err != nil {
 		err = g.Error(err, "could not create temp table "+tableTmp.FullName())
 		return
@@ -351,6 +362,27 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		return
 	}
 
+	// dedupe by primary key, keeping the latest record
+	var dedupeDropped uint64
+	if dedupe := cfg.Target.Options.Dedupe; dedupe != nil && *dedupe == DedupeLatest && cnt > 0 {
+		t.SetProgress("deduping records in temp table")
+		dedupeTable, dropped, err := dedupeTempTable(cfg, tgtConn, tableTmp)
+		if dedupeTable.Name != "" {
+			t.AddCleanupTaskFirst(func() {
+				if cast.ToBool(os.Getenv("SLING_KEEP_TEMP")) {
+					return
+				}
+				g.LogError(tgtConn.DropTable(dedupeTable.FullName()))
+			})
+		}
+		if err != nil {
+			return cnt, g.Error(err, "could not dedupe temp table "+tableTmp.FullName())
+		}
+		cfg.Target.Options.TableTmp = dedupeTable.FullName()
+		dedupeDropped = dropped
+		t.SetProgress("dropped %d duplicate records", dropped)
+	}
+
 	// pre SQL
 	if preSQL := cfg.Target.Options.PreSQL; preSQL != nil && *preSQL != "" {
 		t.SetProgress("executing pre-sql")
@@ -381,6 +413,30 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		}
 	}
 
+	// columns of the existing target table, to apply the schema evolution
+	// policy and record the schema changes
+	var preColumns iop.Columns
+	if se := cfg.Target.Options.SchemaEvolution; se != nil && *se != "" {
+		if exists, err := database.TableExists(tgtConn, targetTable.FullName()); err != nil {
+			return cnt, g.Error(err, "could not check existence of table "+targetTable.FullName())
+		} else if exists {
+			preColumns, err = tgtConn.GetColumns(targetTable.FullName())
+			if err != nil {
+				return cnt, g.Error(err, "could not get column list for "+targetTable.FullName())
+			}
+		}
+	}
+
+	// apply schema evolution policy against the existing target table
+	if cnt > 0 && preColumns != nil {
+		setStage("5 - schema-evolution")
+		defer tgtConn.SetProp("ignore_new_columns", "false") // the connection is reused
+		err = t.evolveSchema(cfg, tgtConn, targetTable, preColumns, df)
+		if err != nil {
+			return cnt, err
+		}
+	}
+
 	// need to contain the final write in a transcation after data is loaded
 	txOptions := sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: false}
 	switch tgtConn.GetType() {
@@ -398,7 +454,7 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 	defer tgtConn.Rollback() // rollback in case of error
 	setStage("5 - prepare-final")
 
-	{
+	if !swapTable {
 		if cfg.Mode == FullRefreshMode {
 			// drop, (create if not exists) and insert directly
 			err = tgtConn.DropTable(targetTable.FullName())
@@ -464,17 +520,28 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		}
 	}
 
+	// target count prior to load, to validate appended records
+	loadValidation, _ := cfg.LoadValidation()
+	var preCnt uint64
+	if loadValidation.Count && !swapTable {
+		preCnt, err = tgtConn.GetCount(targetTable.FullName())
+		if err != nil {
+			return cnt, g.Error(err, "could not get count of "+targetTable.FullName())
+		}
+	}
+
 	// Put data from tmp to final
 	setStage("5 - load-into-final")
 	if cnt == 0 {
 		t.SetProgress("0 rows inserted. Nothing to do.")
-	} else if cfg.Mode == "drop (need to optimize temp table in place)" {
-		// use swap
-		err = tgtConn.SwapTable(tableTmp.FullName(), targetTable.FullName())
+	} else if swapTable {
+		// rename temp table into place, previous table is dropped after
+		err = tgtConn.SwapTable(cfg.Target.Options.TableTmp, targetTable.FullName())
 		if err != nil {
-			err = g.Error(err, "could not swap tables %s to %s", tableTmp.FullName(), targetTable.FullName())
+			err = g.Error(err, "could not swap tables %s to %s", cfg.Target.Options.TableTmp, targetTable.FullName())
 			return 0, err
 		}
+		t.SetProgress("swapped table %s into %s", cfg.Target.Options.TableTmp, targetTable.FullName())
 
 	} else if (cfg.Mode == IncrementalMode && len(t.Config.Source.PrimaryKey()) == 0) || cfg.Mode == SnapshotMode || cfg.Mode == FullRefreshMode {
 		// create if not exists and insert directly
@@ -509,7 +576,7 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		// create final if not exists
 		// delete from final and insert
 		// or update (such as merge or ON CONFLICT)
-		rowAffCnt, err := tgtConn.Upsert(tableTmp.FullName(), targetTable.FullName(), cfg.Source.PrimaryKey())
+		rowAffCnt, err := tgtConn.Upsert(cfg.Target.Options.TableTmp, targetTable.FullName(), cfg.Source.PrimaryKey())
 		if err != nil {
 			err = g.Error(err, "Could not incremental from temp")
 			// data is still in temp table at this point
@@ -521,6 +588,35 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 		}
 	}
 
+	// record the schema changes applied to the target table
+	if preColumns != nil {
+		err = t.recordSchemaChanges(tgtConn, targetTable, preColumns)
+		if err != nil {
+			return cnt, err
+		}
+	}
+
+	// reconcile target with source stream
+	if loadValidation.Enabled() && cnt > 0 {
+		setStage("5 - validate-load")
+		t.SetProgress("validating load into %s", targetTable.FullName())
+		err = validateLoad(cfg, tgtConn, targetTable, df.Columns, loadValidation, cnt-dedupeDropped, preCnt)
+		if err != nil && loadValidation.OnFail == "warn" {
+			g.Warn(err.Error())
+			err = nil
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	// evaluate dataset expectations and row anomalies. The stream stats
+	// include the deduplicated rows, so the target is queried instead
+	statsCols := lo.Ternary(dedupeDropped > 0, nil, df.Columns)
+	err = t.checkDataQuality(cfg, tgtConn, targetTable, statsCols, cnt-dedupeDropped)
+	if err != nil {
+		return 0, err
+	}
+
 	// post SQL
 	if postSQL := cfg.Target.Options.PostSQL; postSQL != nil && *postSQL != "" {
 		t.SetProgress("executing post-sql")
@@ -542,3 +638,145 @@ func (t *TaskExecution) WriteToDb(cfg *Config, df *iop.Dataflow, tgtConn databas
 
 	return
 }
+
+// warnCastErrors logs, per column, the number of values which could not
+// be cast into the type forced with the `columns` option
+func (t *TaskExecution) warnCastErrors() {
+	if t.df == nil {
+		return
+	}
+
+	t.df.SyncStats()
+	for _, col := range t.df.Columns {
+		if col.Stats.CastErrCnt == 0 {
+			continue
+		}
+
+		if col.OnCastError == "" {
+			g.Warn("column %s: %d values could not be cast into the provided type", col.Name, col.Stats.CastErrCnt)
+		} else {
+			g.Warn("column %s: %d values could not be cast into %s (on_cast_error: %s)", col.Name, col.Stats.CastErrCnt, col.Type, col.OnCastError)
+		}
+	}
+}
+
+// makeRejectWriter creates the writer of the rows rejected by the stream processors
+// or failing to insert, into the `reject_to` file path / URL or table (in the target
+// database). The rows are written as they are rejected. Returns nil if not set.
+func (t *TaskExecution) makeRejectWriter() (rw *iop.RejectWriter, err error) {
+	rejectTo := ""
+	maxRejects := int64(0)
+	if o := t.Config.Target.Options; o != nil && o.RejectTo != nil {
+		rejectTo = strings.TrimSpace(*o.RejectTo)
+		if o.MaxRejects != nil {
+			maxRejects = *o.MaxRejects
+		}
+	}
+	if rejectTo == "" {
+		return nil, nil
+	}
+
+	if rejectToIsFile(rejectTo) {
+		uri := rejectTo
+		if !strings.Contains(uri, "://") {
+			uri = "file://" + uri
+		}
+
+		// use target connection credentials if same storage
+		props := []string{}
+		if t.Config.TgtConn.Type.Kind() == dbio.KindFile && strings.Split(t.Config.TgtConn.URL(), "://")[0] == strings.Split(uri, "://")[0] {
+			props = g.MapToKVArr(t.Config.TgtConn.DataS())
+		}
+
+		fs, err := filesys.NewFileSysClientFromURLContext(context.Background(), uri, props...)
+		if err != nil {
+			return nil, g.Error(err, "could not obtain client for %s", uri)
+		}
+
+		rw = iop.NewRejectWriter(maxRejects, func(ds *iop.Datastream) error {
+			g.Warn("rows are being rejected, writing to %s", uri)
+
+			df, err := iop.MakeDataFlow(ds)
+			if err != nil {
+				return g.Error(err, "could not make dataflow of rejected rows")
+			}
+
+			_, err = filesys.WriteDataflow(fs, df, uri)
+			if err != nil {
+				return g.Error(err, "could not write rejected rows to %s", uri)
+			}
+			return nil
+		})
+		return rw, nil
+	}
+
+	if t.Config.TgtConn.Type.Kind() != dbio.KindDatabase {
+		return nil, g.Error("reject_to must be a file path or URL when the target is not a database: %s", rejectTo)
+	}
+
+	table, err := database.ParseTableName(rejectTo, t.Config.TgtConn.Type)
+	if err != nil {
+		return nil, g.Error(err, "could not parse reject table name: %s", rejectTo)
+	}
+
+	rw = iop.NewRejectWriter(maxRejects, func(ds *iop.Datastream) error {
+		g.Warn("rows are being rejected, writing to %s", table.FullName())
+
+		// use a separate connection, since the target connection is loading
+		tgtConn, err := database.NewConnContext(context.Background(), t.Config.TgtConn.URL(), g.MapToKVArr(t.Config.TgtConn.DataS())...)
+		if err != nil {
+			return g.Error(err, "could not initialize target connection")
+		} else if err = tgtConn.Connect(); err != nil {
+			return g.Error(err, "could not connect to target connection")
+		}
+		defer tgtConn.Close()
+
+		data := iop.NewDataset(ds.Columns)
+		data.Inferred = true
+		_, err = createTableIfNotExists(tgtConn, data, &table, false)
+		if err != nil {
+			return g.Error(err, "could not create reject table %s", table.FullName())
+		}
+
+		df, err := iop.MakeDataFlow(ds)
+		if err != nil {
+			return g.Error(err, "could not make dataflow of rejected rows")
+		}
+
+		_, err = tgtConn.BulkImportFlow(table.FullName(), df)
+		if err != nil {
+			return g.Error(err, "could not insert rejected rows into %s", table.FullName())
+		}
+		return nil
+	})
+
+	return rw, nil
+}
+
+// closeRejects waits for the rejected rows to be written
+func (t *TaskExecution) closeRejects() (err error) {
+	if t.rejects == nil {
+		return nil
+	}
+
+	err = t.rejects.Close()
+	if cnt := t.rejects.Count(); cnt > 0 {
+		g.Warn("%d rows were rejected", cnt)
+	}
+	return err
+}
+
+// rejectToIsFile returns true if the `reject_to` value is a file path or URL, not a table
+func rejectToIsFile(rejectTo string) bool {
+	switch {
+	case strings.Contains(rejectTo, "://"):
+		return true
+	case strings.HasPrefix(rejectTo, "/"), strings.HasPrefix(rejectTo, "."), strings.HasPrefix(rejectTo, "~"):
+		return true
+	case len(rejectTo) > 2 && rejectTo[1] == ':' && (rejectTo[2] == '\\' || rejectTo[2] == '/'):
+		return true // windows path
+	}
+
+	ext := strings.ToLower(filepath.Ext(rejectTo))
+	return g.In(ext, ".csv", ".json", ".jsonl", ".parquet", ".xlsx", ".tsv", ".gz", ".zst", ".snappy")
+}
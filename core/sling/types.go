@@ -12,6 +12,12 @@ const ConnDiscover JobType = "conn-discover"
 // ConnTest is for a connection exec
 const ConnExec JobType = "conn-exec"
 
+// ConnProfile is for a connection profile
+const ConnProfile JobType = "conn-profile"
+
+// ConnRelations is for a connection relations discovery
+const ConnRelations JobType = "conn-relations"
+
 // DbToDb is from db to db
 const DbToDb JobType = "db-db"
 
@@ -27,6 +33,9 @@ const FileToFile JobType = "file-file"
 // DbSQL is for a db sql query
 const DbSQL JobType = "db-sql"
 
+// DbDiff is for comparing two db tables
+const DbDiff JobType = "db-diff"
+
 var AllJobType = []struct {
 	Value  JobType
 	TSName string
@@ -34,11 +43,35 @@ var AllJobType = []struct {
 	{ConnTest, "ConnTest"},
 	{ConnDiscover, "ConnDiscover"},
 	{ConnExec, "ConnExec"},
+	{ConnProfile, "ConnProfile"},
+	{ConnRelations, "ConnRelations"},
 	{DbToDb, "DbToDb"},
 	{FileToDB, "FileToDB"},
 	{DbToFile, "DbToFile"},
 	{FileToFile, "FileToFile"},
 	{DbSQL, "DbSQL"},
+	{DbDiff, "DbDiff"},
+}
+
+// SchemaChange is a difference detected between the stream and the target table schema
+type SchemaChange struct {
+	Table   string `json:"table"`
+	Column  string `json:"column"`
+	Change  string `json:"change"` // added, changed or dropped
+	OldType string `json:"old_type,omitempty"`
+	NewType string `json:"new_type,omitempty"`
+	Action  string `json:"action"` // applied, ignored or failed
+}
+
+// ExpectationResult is the outcome of a dataset expectation
+type ExpectationResult struct {
+	Table    string `json:"table"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	Status   string `json:"status"` // pass, warn, fail or skipped
+	Observed string `json:"observed,omitempty"`
+	Message  string `json:"message,omitempty"`
 }
 
 // ExecStatus is the status of an execution
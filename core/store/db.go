@@ -55,6 +55,8 @@ func InitDB() {
 		&Task{},
 		&Replication{},
 		&Setting{},
+		&SchemaChange{},
+		&ExpectationResult{},
 	}
 
 	// manual migrations
@@ -26,6 +26,8 @@ func init() {
 		_, err := StoreUpdate(t)
 		return err
 	}
+
+	sling.StoreRowHistory = StoreRowHistory
 }
 
 var syncStatus = func(e *Execution) {
@@ -141,6 +143,42 @@ func (r Replication) Value() (driver.Value, error) {
 	return g.JSONValuer(r, "{}")
 }
 
+// SchemaChange is a schema change detected on a target table during an execution
+type SchemaChange struct {
+	ID int64 `json:"id,omitempty" gorm:"primaryKey"`
+
+	ExecID   string `json:"exec_id,omitempty" gorm:"index"`
+	StreamID string `json:"stream_id,omitempty" gorm:"index"`
+
+	Table   string `json:"table,omitempty" gorm:"index"`
+	Column  string `json:"column,omitempty"`
+	Change  string `json:"change,omitempty"`
+	OldType string `json:"old_type,omitempty"`
+	NewType string `json:"new_type,omitempty"`
+	Action  string `json:"action,omitempty"`
+	Policy  string `json:"policy,omitempty"`
+
+	CreatedDt time.Time `json:"created_dt,omitempty" gorm:"autoCreateTime"`
+}
+
+// ExpectationResult is the outcome of a dataset expectation during an execution
+type ExpectationResult struct {
+	ID int64 `json:"id,omitempty" gorm:"primaryKey"`
+
+	ExecID   string `json:"exec_id,omitempty" gorm:"index"`
+	StreamID string `json:"stream_id,omitempty" gorm:"index"`
+
+	Table    string `json:"table,omitempty" gorm:"index"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Observed string `json:"observed,omitempty"`
+	Message  string `json:"message,omitempty"`
+
+	CreatedDt time.Time `json:"created_dt,omitempty" gorm:"autoCreateTime"`
+}
+
 // Store saves the task into the local sqlite
 func ToExecutionObject(t *sling.TaskExecution) *Execution {
 
@@ -323,8 +361,103 @@ func StoreUpdate(t *sling.TaskExecution) (exec *Execution, err error) {
 		return
 	}
 
+	// schema changes
+	err = storeSchemaChanges(t, exec)
+	if err != nil {
+		g.LogError(err, "could not insert schema changes into local .sling.db")
+		return
+	}
+
+	// expectation results
+	err = storeExpectations(t, exec)
+	if err != nil {
+		g.LogError(err, "could not insert expectation results into local .sling.db")
+		return
+	}
+
 	// sync status
 	syncStatus(exec)
 
 	return
 }
+
+// storeSchemaChanges saves the schema changes detected during the execution
+func storeSchemaChanges(t *sling.TaskExecution, exec *Execution) (err error) {
+	if len(t.SchemaChanges) == 0 {
+		return nil
+	}
+
+	policy := ""
+	if t.Config != nil && t.Config.Target.Options != nil && t.Config.Target.Options.SchemaEvolution != nil {
+		policy = string(*t.Config.Target.Options.SchemaEvolution)
+	}
+
+	// replace any previously saved, in case of multiple updates
+	err = Db.Where("exec_id = ? and stream_id = ?", exec.ExecID, exec.StreamID).Delete(&SchemaChange{}).Error
+	if err != nil {
+		return err
+	}
+
+	changes := lo.Map(t.SchemaChanges, func(c sling.SchemaChange, i int) SchemaChange {
+		return SchemaChange{
+			ExecID:   exec.ExecID,
+			StreamID: exec.StreamID,
+			Table:    c.Table,
+			Column:   c.Column,
+			Change:   c.Change,
+			OldType:  c.OldType,
+			NewType:  c.NewType,
+			Action:   c.Action,
+			Policy:   policy,
+		}
+	})
+
+	return Db.Create(&changes).Error
+}
+
+// storeExpectations saves the expectation results of the execution
+func storeExpectations(t *sling.TaskExecution, exec *Execution) (err error) {
+	if len(t.Expectations) == 0 {
+		return nil
+	}
+
+	// replace any previously saved, in case of multiple updates
+	err = Db.Where("exec_id = ? and stream_id = ?", exec.ExecID, exec.StreamID).Delete(&ExpectationResult{}).Error
+	if err != nil {
+		return err
+	}
+
+	results := lo.Map(t.Expectations, func(r sling.ExpectationResult, i int) ExpectationResult {
+		return ExpectationResult{
+			ExecID:   exec.ExecID,
+			StreamID: exec.StreamID,
+			Table:    r.Table,
+			Name:     r.Name,
+			Type:     r.Type,
+			Severity: r.Severity,
+			Status:   r.Status,
+			Observed: r.Observed,
+			Message:  r.Message,
+		}
+	})
+
+	return Db.Create(&results).Error
+}
+
+// StoreRowHistory returns the row counts of the latest successful executions
+// of the same stream, most recent first
+func StoreRowHistory(t *sling.TaskExecution, limit int) (rows []uint64, err error) {
+	if Db == nil || t.Config == nil {
+		return
+	}
+
+	err = Db.Model(&Execution{}).
+		Where("stream_id = ? and exec_id != ? and status = ?", t.Config.StreamID(), t.ExecID, sling.ExecStatusSuccess).
+		Order("id desc").Limit(limit).
+		Pluck("rows", &rows).Error
+	if err != nil {
+		return nil, g.Error(err, "could not get row history from local .sling.db")
+	}
+
+	return
+}
@@ -63,6 +63,7 @@ require (
 	github.com/xo/dburl v0.3.0
 	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a
 	go.mongodb.org/mongo-driver v1.14.0
+	go.starlark.net v0.0.0-20231121155337-90ade8b19d09
 	golang.org/x/crypto v0.26.0
 	golang.org/x/oauth2 v0.21.0
 	golang.org/x/text v0.17.0